@@ -0,0 +1,458 @@
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/techulus/push-cli/internal/api"
+	"github.com/techulus/push-cli/internal/config"
+	"github.com/techulus/push-cli/internal/quiethours"
+	"github.com/techulus/push-cli/internal/spool"
+	"github.com/techulus/push-cli/internal/throttle"
+)
+
+type batchItem struct {
+	api.NotifyRequest
+	Group string `json:"group,omitempty"`
+	Line  int    `json:"-"`
+
+	titlePrefix string
+	downgrade   bool
+}
+
+// request is what is sent for the item: with the title prefix and after quiet
+// hours. The item itself is left as read, for the failure report.
+func (item batchItem) request() api.NotifyRequest {
+	req := item.NotifyRequest
+	req.Title = item.titlePrefix + req.Title
+	if item.downgrade {
+		downgradeRequest(&req)
+	}
+	return req
+}
+
+// batchFailure is written to the failure report. It keeps every field of the
+// original item so the report can be fed back into --batch as-is.
+type batchFailure struct {
+	batchItem
+	SourceLine int    `json:"line"`
+	Error      string `json:"error"`
+	ExitCode   int    `json:"exitCode"`
+}
+
+type batchSummary struct {
+	Total       int            `json:"total"`
+	Sent        int            `json:"sent"`
+	Failed      int            `json:"failed"`
+	Held        int            `json:"held"`
+	Dropped     int            `json:"dropped"`
+	RateLimited int            `json:"rateLimited"`
+	Failures    []batchFailure `json:"failures"`
+}
+
+func openBatchInput(path string) (io.ReadCloser, error) {
+	if path == "-" {
+		return io.NopCloser(os.Stdin), nil
+	}
+	return os.Open(path)
+}
+
+func batchFormat(path, format string) (string, error) {
+	switch format {
+	case "jsonl", "csv":
+		return format, nil
+	case "", "auto":
+		if strings.EqualFold(filepath.Ext(path), ".csv") {
+			return "csv", nil
+		}
+		return "jsonl", nil
+	}
+	return "", fmt.Errorf("invalid batch format %q, valid formats: auto, jsonl, csv", format)
+}
+
+func parseBatchJSONL(r io.Reader) ([]batchItem, error) {
+	var items []batchItem
+	var errs []error
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+		item := batchItem{Line: line}
+		if err := json.Unmarshal([]byte(text), &item); err != nil {
+			errs = append(errs, fmt.Errorf("line %d: %w", line, err))
+			continue
+		}
+		items = append(items, item)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("reading batch input: %w", err)
+	}
+	return items, errors.Join(errs...)
+}
+
+func parseBatchCSV(r io.Reader) ([]batchItem, error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading CSV header: %w", err)
+	}
+	for i, column := range header {
+		switch normalizeColumn(column) {
+		case "title", "body", "sound", "channel", "link", "image", "timesensitive", "group":
+		default:
+			return nil, fmt.Errorf("unknown CSV column %q", column)
+		}
+		header[i] = normalizeColumn(column)
+	}
+
+	var items []batchItem
+	var errs []error
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		line, _ := reader.FieldPos(0)
+
+		item := batchItem{Line: line}
+		for i, value := range record {
+			switch header[i] {
+			case "title":
+				item.Title = value
+			case "body":
+				item.Body = value
+			case "sound":
+				item.Sound = value
+			case "channel":
+				item.Channel = value
+			case "link":
+				item.Link = value
+			case "image":
+				item.Image = value
+			case "group":
+				item.Group = value
+			case "timesensitive":
+				if value == "" {
+					continue
+				}
+				b, err := strconv.ParseBool(value)
+				if err != nil {
+					errs = append(errs, fmt.Errorf("line %d: invalid timeSensitive value %q", line, value))
+					continue
+				}
+				item.TimeSensitive = b
+			}
+		}
+		items = append(items, item)
+	}
+	return items, errors.Join(errs...)
+}
+
+func normalizeColumn(column string) string {
+	column = strings.ToLower(strings.TrimSpace(column))
+	return strings.NewReplacer("_", "", "-", "").Replace(column)
+}
+
+// applyBatchDefaults fills fields a line left empty from the notify flags and
+// checks the result with the same rules as a single notification.
+func applyBatchDefaults(items []batchItem, defaults api.NotifyRequest) error {
+	var errs []error
+	for i := range items {
+		item := &items[i]
+		if item.Title == "" {
+			item.Title = defaults.Title
+		}
+		if item.Body == "" {
+			item.Body = defaults.Body
+		}
+		if item.Sound == "" {
+			item.Sound = defaults.Sound
+		}
+		if item.Channel == "" {
+			item.Channel = defaults.Channel
+		}
+		if item.Link == "" {
+			item.Link = defaults.Link
+		}
+		if item.Image == "" {
+			item.Image = defaults.Image
+		}
+		if defaults.TimeSensitive {
+			item.TimeSensitive = true
+		}
+
+		switch {
+		case item.Title == "":
+			errs = append(errs, fmt.Errorf("line %d: title is required", item.Line))
+		case strings.TrimSpace(item.Body) == "":
+			errs = append(errs, fmt.Errorf("line %d: body is required", item.Line))
+		default:
+			if err := validateSound(item.Sound); err != nil {
+				errs = append(errs, fmt.Errorf("line %d: %w", item.Line, err))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// sendBatch sends items, concurrency at a time. If limit is set, it is asked
+// before each send; items it refuses are skipped and counted as rate limited,
+// and the release it returns is called if the send fails.
+func sendBatch(ctx context.Context, client *api.Client, items []batchItem, concurrency int, limit func() (release func(), ok bool)) batchSummary {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	errs := make([]error, len(items))
+	limited := make([]bool, len(items))
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < concurrency; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				item := items[i]
+				release := func() {}
+				if limit != nil {
+					var ok bool
+					if release, ok = limit(); !ok {
+						limited[i] = true
+						continue
+					}
+				}
+				if item.Group != "" {
+					_, errs[i] = client.NotifyGroupContext(ctx, item.Group, item.request())
+				} else {
+					_, errs[i] = client.NotifyContext(ctx, item.request())
+				}
+				if errs[i] != nil {
+					release()
+				}
+			}
+		}()
+	}
+
+	for i := range items {
+		if ctx.Err() != nil {
+			errs[i] = ctx.Err()
+			continue
+		}
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	summary := batchSummary{Total: len(items), Failures: []batchFailure{}}
+	for i, err := range errs {
+		if limited[i] {
+			summary.RateLimited++
+			continue
+		}
+		if err == nil {
+			summary.Sent++
+			continue
+		}
+		summary.Failures = append(summary.Failures, batchFailure{
+			batchItem:  items[i],
+			SourceLine: items[i].Line,
+			Error:      err.Error(),
+			ExitCode:   exitCode(err),
+		})
+	}
+	summary.Failed = len(summary.Failures)
+	return summary
+}
+
+func writeBatchFailures(path string, failures []batchFailure) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(f)
+	enc.SetEscapeHTML(false)
+	for _, failure := range failures {
+		if err := enc.Encode(failure); err != nil {
+			f.Close()
+			return err
+		}
+	}
+	return f.Close()
+}
+
+// applyBatchQuietHours applies p to each item and returns the items to send
+// now. Held items are queued until quiet hours end and dropped ones left out.
+func applyBatchQuietHours(p *quiethours.Policy, items []batchItem, now time.Time) (send []batchItem, held, dropped int, err error) {
+	for _, item := range items {
+		action, until := quietHoursAt(p, item.TimeSensitive, now)
+		switch action {
+		case quiethours.Drop:
+			dropped++
+			continue
+		case quiethours.Hold:
+			target := spool.TargetNotify
+			if item.Group != "" {
+				target = spool.TargetGroup
+			}
+			if _, err := holdNotification(target, item.Group, item.request(), until); err != nil {
+				return nil, held, dropped, fmt.Errorf("holding line %d for quiet hours: %w", item.Line, err)
+			}
+			held++
+			continue
+		case quiethours.Downgrade:
+			item.downgrade = true
+		}
+		send = append(send, item)
+	}
+	return send, held, dropped, nil
+}
+
+// batchRateLimit returns a limit for sendBatch that applies the profile's
+// rate limit, or nil if it has none. Like a single notification, a batch is
+// sent unlimited if the state file can't be used.
+func batchRateLimit() (func() (func(), bool), error) {
+	rate, err := rateLimit()
+	if err != nil || rate == nil {
+		return nil, err
+	}
+	store, err := openThrottle()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: skipping rate limit: %v\n", err)
+		return nil, nil
+	}
+	r := throttle.Request{Profile: config.ActiveProfile(), Rate: rate}
+	return func() (func(), bool) {
+		d, err := store.Allow(r, time.Now())
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: skipping rate limit: %v\n", err)
+			return func() {}, true
+		}
+		if !d.Allowed {
+			return nil, false
+		}
+		return func() {
+			if err := store.Release(d); err != nil {
+				fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+			}
+		}, true
+	}, nil
+}
+
+// batchUnsupportedFlags only apply to single notifications.
+var batchUnsupportedFlags = []string{"spool", "dedup-key", "dedup-window", "report-suppressed", "at", "in", "wait", "var", "data", "template-file"}
+
+func runBatch(cmd *cobra.Command, path string) {
+	for _, name := range batchUnsupportedFlags {
+		if cmd.Flags().Changed(name) {
+			fail(exitValidation, fmt.Errorf("--%s can't be used with --batch", name))
+		}
+	}
+
+	format, _ := cmd.Flags().GetString("batch-format")
+	concurrency, _ := cmd.Flags().GetInt("concurrency")
+	failuresPath, _ := cmd.Flags().GetString("failures-out")
+
+	format, err := batchFormat(path, format)
+	if err != nil {
+		fail(exitValidation, err)
+	}
+	// Flags act as defaults for every line, so check them up front.
+	body, _ := cmd.Flags().GetString("body")
+	if body == "-" {
+		fail(exitValidation, errors.New("--body - can't be used with --batch, give the body on each line or with --body"))
+	}
+	defaults, err := notifyRequestFromFlags(cmd, body)
+	if err != nil {
+		fail(exitValidation, err)
+	}
+	limit, err := batchRateLimit()
+	if err != nil {
+		fail(exitValidation, err)
+	}
+
+	input, err := openBatchInput(path)
+	if err != nil {
+		fail(exitError, fmt.Errorf("opening batch input: %w", err))
+	}
+	var items []batchItem
+	if format == "csv" {
+		items, err = parseBatchCSV(input)
+	} else {
+		items, err = parseBatchJSONL(input)
+	}
+	input.Close()
+	if err == nil {
+		err = applyBatchDefaults(items, defaults)
+	}
+	prefix := titlePrefix()
+	for i := range items {
+		items[i].titlePrefix = prefix
+	}
+	if err != nil {
+		fail(exitValidation, fmt.Errorf("invalid batch input, nothing was sent:\n%w", err))
+	}
+
+	client := newAPIClient(cmd)
+	send, held, dropped, err := applyBatchQuietHours(activeQuietHours(cmd), items, time.Now())
+	if err != nil {
+		fail(exitError, err)
+	}
+	summary := sendBatch(cmd.Context(), client, send, concurrency, limit)
+	summary.Total, summary.Held, summary.Dropped = len(items), held, dropped
+
+	if failuresPath != "" && summary.Failed > 0 {
+		if err := writeBatchFailures(failuresPath, summary.Failures); err != nil {
+			fmt.Fprintf(os.Stderr, "Error writing failure report: %v\n", err)
+		}
+	}
+
+	switch outputFormat {
+	case outputJSON:
+		printJSON(summary)
+	case outputText:
+		for _, f := range summary.Failures {
+			fmt.Fprintf(os.Stderr, "line %d: %s\n", f.SourceLine, f.Error)
+		}
+		fmt.Printf("Sent %d of %d notifications (%d failed)\n", summary.Sent, summary.Total, summary.Failed)
+		if summary.RateLimited > 0 {
+			fmt.Printf("%d skipped by the rate limit\n", summary.RateLimited)
+		}
+		if summary.Held > 0 || summary.Dropped > 0 {
+			fmt.Printf("Quiet hours: %d held, %d dropped\n", summary.Held, summary.Dropped)
+		}
+		if failuresPath != "" && summary.Failed > 0 {
+			fmt.Printf("Failed notifications written to %s\n", failuresPath)
+		}
+	}
+
+	if summary.Failed > 0 {
+		os.Exit(summary.Failures[0].ExitCode)
+	}
+}
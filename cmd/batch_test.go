@@ -0,0 +1,222 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/techulus/push-cli/internal/api"
+	"github.com/techulus/push-cli/internal/quiethours"
+)
+
+func TestParseBatchJSONL(t *testing.T) {
+	input := `{"title":"A","body":"one","sound":"pop"}
+
+{"body":"two","group":"ops","timeSensitive":true}
+`
+	items, err := parseBatchJSONL(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(items) != 2 {
+		t.Fatalf("expected 2 items, got %d", len(items))
+	}
+	if items[0].Title != "A" || items[0].Sound != "pop" || items[0].Line != 1 {
+		t.Errorf("unexpected first item: %+v", items[0])
+	}
+	if items[1].Group != "ops" || !items[1].TimeSensitive || items[1].Line != 3 {
+		t.Errorf("unexpected second item: %+v", items[1])
+	}
+}
+
+func TestParseBatchJSONL_InvalidLine(t *testing.T) {
+	_, err := parseBatchJSONL(strings.NewReader("{\"body\":\"ok\"}\nnot json\n"))
+	if err == nil || !strings.Contains(err.Error(), "line 2") {
+		t.Fatalf("expected error mentioning line 2, got %v", err)
+	}
+}
+
+func TestParseBatchCSV(t *testing.T) {
+	input := "Title,Body,time_sensitive,Group\nA,\"hello, world\",true,\nB,second,,ops\n"
+	items, err := parseBatchCSV(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(items) != 2 {
+		t.Fatalf("expected 2 items, got %d", len(items))
+	}
+	if items[0].Body != "hello, world" || !items[0].TimeSensitive || items[0].Line != 2 {
+		t.Errorf("unexpected first item: %+v", items[0])
+	}
+	if items[1].Group != "ops" || items[1].TimeSensitive {
+		t.Errorf("unexpected second item: %+v", items[1])
+	}
+}
+
+func TestParseBatchCSV_UnknownColumn(t *testing.T) {
+	if _, err := parseBatchCSV(strings.NewReader("title,bdy\nA,b\n")); err == nil {
+		t.Fatal("expected error for unknown column")
+	}
+}
+
+func TestApplyBatchDefaults(t *testing.T) {
+	items := []batchItem{
+		{NotifyRequest: api.NotifyRequest{Body: "one"}, Line: 1},
+		{NotifyRequest: api.NotifyRequest{Title: "Own", Body: "two", Sound: "harp"}, Line: 2},
+	}
+	err := applyBatchDefaults(items, api.NotifyRequest{Title: "Default", Sound: "pop", Channel: "ops"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if items[0].Title != "Default" || items[0].Sound != "pop" || items[0].Channel != "ops" {
+		t.Errorf("expected defaults applied, got %+v", items[0])
+	}
+	if items[1].Title != "Own" || items[1].Sound != "harp" {
+		t.Errorf("expected line values kept, got %+v", items[1])
+	}
+
+	bad := []batchItem{
+		{NotifyRequest: api.NotifyRequest{Title: "T"}, Line: 1},
+		{NotifyRequest: api.NotifyRequest{Title: "T", Body: "b", Sound: "nope"}, Line: 2},
+	}
+	err = applyBatchDefaults(bad, api.NotifyRequest{})
+	if err == nil || !strings.Contains(err.Error(), "line 1: body is required") || !strings.Contains(err.Error(), "line 2: invalid sound") {
+		t.Errorf("expected errors for both lines, got %v", err)
+	}
+}
+
+func TestBatchFormat(t *testing.T) {
+	tests := []struct{ path, format, want string }{
+		{"alerts.csv", "auto", "csv"},
+		{"alerts.CSV", "", "csv"},
+		{"alerts.jsonl", "auto", "jsonl"},
+		{"-", "auto", "jsonl"},
+		{"-", "csv", "csv"},
+	}
+	for _, tt := range tests {
+		got, err := batchFormat(tt.path, tt.format)
+		if err != nil || got != tt.want {
+			t.Errorf("batchFormat(%q, %q) = %q, %v, want %q", tt.path, tt.format, got, err, tt.want)
+		}
+	}
+	if _, err := batchFormat("x", "xml"); err == nil {
+		t.Error("expected error for unknown format")
+	}
+}
+
+func TestSendBatch(t *testing.T) {
+	var mu sync.Mutex
+	paths, titles := map[string]int{}, map[string]int{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req api.NotifyRequest
+		json.NewDecoder(r.Body).Decode(&req)
+
+		mu.Lock()
+		paths[r.URL.Path]++
+		titles[req.Title]++
+		mu.Unlock()
+
+		if req.Body == "fail" {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"success":false,"message":"bad"}`))
+			return
+		}
+		w.Write([]byte(`{"success":true}`))
+	}))
+	defer server.Close()
+
+	items := []batchItem{
+		{NotifyRequest: api.NotifyRequest{Title: "T", Body: "one"}, Line: 1},
+		{NotifyRequest: api.NotifyRequest{Title: "T", Body: "fail"}, Line: 2},
+		{NotifyRequest: api.NotifyRequest{Title: "T", Body: "three"}, Group: "ops", Line: 3},
+	}
+	for i := range items {
+		items[i].titlePrefix = "[prod] "
+	}
+	client := api.NewClient("test-key", api.WithBaseURL(server.URL))
+	summary := sendBatch(context.Background(), client, items, 2, nil)
+
+	if summary.Total != 3 || summary.Sent != 2 || summary.Failed != 1 {
+		t.Fatalf("unexpected summary: %+v", summary)
+	}
+	if summary.Failures[0].SourceLine != 2 || summary.Failures[0].ExitCode != exitValidation {
+		t.Errorf("unexpected failure: %+v", summary.Failures[0])
+	}
+	if paths["/notify"] != 2 || paths["/notify/group/ops"] != 1 {
+		t.Errorf("unexpected request paths: %v", paths)
+	}
+	if titles["[prod] T"] != 3 {
+		t.Errorf("expected every title to be sent with the prefix, got %v", titles)
+	}
+
+	// The report holds the item as read, so a re-run prefixes it only once.
+	out, _ := json.Marshal(summary.Failures[0])
+	reparsed, err := parseBatchJSONL(strings.NewReader(string(out)))
+	if err != nil || len(reparsed) != 1 || reparsed[0].Body != "fail" || reparsed[0].Title != "T" {
+		t.Errorf("expected failure report to be valid batch input, got %v, %v", reparsed, err)
+	}
+
+	// Items refused by the rate limit are skipped; failed sends give back their token.
+	var allowed, released int
+	limit := func() (func(), bool) {
+		mu.Lock()
+		defer mu.Unlock()
+		if allowed == 2 {
+			return nil, false
+		}
+		allowed++
+		return func() { mu.Lock(); released++; mu.Unlock() }, true
+	}
+	summary = sendBatch(context.Background(), client, items, 1, limit)
+	if summary.Sent+summary.Failed != 2 || summary.RateLimited != 1 {
+		t.Errorf("unexpected rate limited summary: %+v", summary)
+	}
+	if released != summary.Failed {
+		t.Errorf("expected %d released tokens, got %d", summary.Failed, released)
+	}
+}
+
+func TestApplyBatchQuietHours(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("XDG_CONFIG_HOME", "")
+	items := []batchItem{
+		{NotifyRequest: api.NotifyRequest{Title: "a", Body: "b", Sound: "ping"}, Line: 1},
+		{NotifyRequest: api.NotifyRequest{Title: "urgent", Body: "b", TimeSensitive: true}, Line: 2},
+	}
+	now := time.Date(2024, 3, 4, 23, 0, 0, 0, time.UTC)
+	policy := func(action quiethours.Action) *quiethours.Policy {
+		p := &quiethours.Policy{Timezone: "UTC", Action: action, Windows: []quiethours.Window{{Start: "22:00", End: "07:00"}}}
+		if err := p.Prepare(); err != nil {
+			t.Fatal(err)
+		}
+		return p
+	}
+
+	send, held, dropped, err := applyBatchQuietHours(policy(quiethours.Downgrade), items, now)
+	if err != nil || held != 0 || dropped != 0 || len(send) != 2 {
+		t.Fatalf("downgrade: got %d to send, %d held, %d dropped, %v", len(send), held, dropped, err)
+	}
+	if req := send[0].request(); req.Sound != "" || send[0].Sound != "ping" {
+		t.Errorf("expected the sent request to be downgraded and the item kept, got %+v", send[0])
+	}
+
+	send, held, dropped, err = applyBatchQuietHours(policy(quiethours.Drop), items, now)
+	if err != nil || dropped != 1 || len(send) != 1 || send[0].Title != "urgent" {
+		t.Errorf("drop: got %+v, %d dropped, %v", send, dropped, err)
+	}
+
+	send, held, _, err = applyBatchQuietHours(policy(quiethours.Hold), items, now)
+	if err != nil || held != 1 || len(send) != 1 {
+		t.Fatalf("hold: got %d to send, %d held, %v", len(send), held, err)
+	}
+	q, _ := openSpool()
+	entries, _ := q.List()
+	if len(entries) != 1 || entries[0].Request.Title != "a" || !entries[0].NotBefore.Equal(now.Add(8*time.Hour)) {
+		t.Errorf("expected the held item in the queue until 07:00, got %+v", entries)
+	}
+}
@@ -2,13 +2,39 @@ package cmd
 
 import (
 	"fmt"
+	"io"
 	"os"
 	"strings"
 
 	"github.com/spf13/cobra"
+	"github.com/techulus/push-cli/internal/api"
 	"github.com/techulus/push-cli/internal/config"
+	"github.com/techulus/push-cli/internal/secret"
+	"github.com/techulus/push-cli/internal/throttle"
 )
 
+type configOutput struct {
+	Profile       string          `json:"profile"`
+	APIKey        string          `json:"apiKey,omitempty"`
+	APIKeySource  string          `json:"apiKeySource,omitempty"`
+	BaseURL       string          `json:"baseUrl"`
+	BaseURLSource string          `json:"baseUrlSource"`
+	RateLimit     string          `json:"rateLimit,omitempty"`
+	Settings      []settingOutput `json:"settings"`
+}
+
+func describeSource(src config.Source, flag, env string) string {
+	switch src {
+	case config.SourceFlag:
+		return flag + " flag"
+	case config.SourceEnv:
+		return env + " environment variable"
+	case config.SourceProfile:
+		return fmt.Sprintf("profile %q", config.ActiveProfile())
+	}
+	return src.String()
+}
+
 var configCmd = &cobra.Command{
 	Use:   "config",
 	Short: "Manage CLI configuration",
@@ -17,18 +43,107 @@ var configCmd = &cobra.Command{
 var setKeyCmd = &cobra.Command{
 	Use:   "set-key <api-key>",
 	Short: "Save your Push API key",
-	Args:  cobra.ExactArgs(1),
+	Long: `Save your Push API key for the active profile.
+
+--store picks where it is kept:
+
+  file       in plaintext in the config file, readable only by you (default)
+  keyring    in the OS keyring: the macOS keychain, or the Secret Service
+             (GNOME Keyring, KWallet) through secret-tool elsewhere
+  encrypted  in an age file in the config directory, encrypted with a
+             passphrase that is asked for on the terminal or read from
+             PUSH_KEY_PASSPHRASE
+
+Pass "-" to read the key from stdin and keep it out of your shell history.
+To read the key from a password manager instead, set api_key_command in the
+config file.`,
+	Args: cobra.ExactArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
-		key := strings.TrimSpace(args[0])
+		key := args[0]
+		if key == "-" {
+			data, err := io.ReadAll(os.Stdin)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error reading API key: %v\n", err)
+				os.Exit(1)
+			}
+			key = string(data)
+		}
+		key = strings.TrimSpace(key)
 		if key == "" {
 			fmt.Fprintln(os.Stderr, "API key cannot be empty")
 			os.Exit(1)
 		}
-		if err := config.SetAPIKey(key); err != nil {
+
+		store, _ := cmd.Flags().GetString("store")
+		var passphrase string
+		switch store {
+		case config.StoreFile, config.StoreKeyring:
+		case config.StoreEncrypted:
+			var err error
+			if passphrase, err = secret.ReadPassphrase(true); err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				os.Exit(1)
+			}
+		default:
+			fmt.Fprintf(os.Stderr, "Error: invalid --store %q, valid stores: file, keyring, encrypted\n", store)
+			os.Exit(1)
+		}
+		if err := config.StoreAPIKey(store, key, passphrase); err != nil {
 			fmt.Fprintf(os.Stderr, "Error saving API key: %v\n", err)
 			os.Exit(1)
 		}
-		fmt.Println("API key saved successfully")
+
+		switch store {
+		case config.StoreKeyring:
+			fmt.Printf("API key for profile %q saved to the keyring\n", config.ActiveProfile())
+		case config.StoreEncrypted:
+			path, _ := config.EncryptedKeyPath(config.ActiveProfile())
+			fmt.Printf("API key for profile %q saved to %s\n", config.ActiveProfile(), path)
+		default:
+			fmt.Printf("API key saved to profile %q\n", config.ActiveProfile())
+		}
+	},
+}
+
+var setBaseURLCmd = &cobra.Command{
+	Use:   "set-base-url <url>",
+	Short: "Save the Push API base URL for a self-hosted or staging server",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		baseURL := strings.TrimSpace(args[0])
+		if err := api.ValidateBaseURL(baseURL); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		if err := config.SetBaseURL(baseURL); err != nil {
+			fmt.Fprintf(os.Stderr, "Error saving base URL: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Base URL saved to profile %q\n", config.ActiveProfile())
+	},
+}
+
+var setRateLimitCmd = &cobra.Command{
+	Use:   "set-rate-limit <rate>",
+	Short: "Limit how many notifications the profile sends, e.g. 30/m (\"off\" to remove)",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		limit := strings.TrimSpace(args[0])
+		if limit == "off" {
+			limit = ""
+		} else if _, err := throttle.ParseRate(limit); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		if err := config.SetRateLimit(limit); err != nil {
+			fmt.Fprintf(os.Stderr, "Error saving rate limit: %v\n", err)
+			os.Exit(1)
+		}
+		if limit == "" {
+			fmt.Printf("Rate limit removed from profile %q\n", config.ActiveProfile())
+			return
+		}
+		fmt.Printf("Rate limit saved to profile %q\n", config.ActiveProfile())
 	},
 }
 
@@ -36,17 +151,108 @@ var showCmd = &cobra.Command{
 	Use:   "show",
 	Short: "Display current configuration",
 	Run: func(cmd *cobra.Command, args []string) {
-		key := config.GetAPIKey()
-		if key == "" {
-			fmt.Println("No API key configured. Run: push config set-key <api-key>")
+		key, keySrc, err := config.ResolveAPIKey()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		baseURL, urlSrc := config.LookupBaseURL()
+		rateLimit, _ := config.LookupRateLimit()
+		// Everything but the base URL, which is always shown.
+		var extra []settingOutput
+		for _, s := range effectiveSettings() {
+			if s.Key != "base_url" {
+				extra = append(extra, s)
+			}
+		}
+		if baseURL == "" {
+			baseURL, urlSrc = api.DefaultBaseURL, config.SourceDefault
+		}
+
+		switch outputFormat {
+		case outputJSON:
+			out := configOutput{
+				Profile:       config.ActiveProfile(),
+				BaseURL:       baseURL,
+				BaseURLSource: describeSource(urlSrc, "--base-url", config.BaseURLEnv),
+				RateLimit:     rateLimit,
+				Settings:      effectiveSettings(),
+			}
+			if key != "" {
+				out.APIKey = config.MaskedAPIKey()
+				out.APIKeySource = describeSource(keySrc, "--api-key", config.APIKeyEnv)
+			}
+			printJSON(out)
+		case outputText:
+			if key == "" {
+				fmt.Println("No API key configured. Run: push config set-key <api-key>")
+				return
+			}
+			fmt.Printf("Profile: %s\n", config.ActiveProfile())
+			fmt.Printf("API Key: %s (%s)\n", config.MaskedAPIKey(), describeSource(keySrc, "--api-key", config.APIKeyEnv))
+			fmt.Printf("Base URL: %s (%s)\n", baseURL, describeSource(urlSrc, "--base-url", config.BaseURLEnv))
+			for _, s := range extra {
+				fmt.Printf("%s: %s (%s)\n", s.Key, s.Value, s.Source)
+			}
+		}
+	},
+}
+
+var useCmd = &cobra.Command{
+	Use:   "use <profile>",
+	Short: "Set the active profile",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := config.UseProfile(args[0]); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Switched to profile %q\n", strings.ToLower(args[0]))
+	},
+}
+
+var listCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List configured profiles",
+	Run: func(cmd *cobra.Command, args []string) {
+		profiles := config.Profiles()
+		if len(profiles) == 0 {
+			fmt.Println("No profiles configured. Run: push config set-key <api-key>")
 			return
 		}
-		fmt.Printf("API Key: %s\n", config.MaskedAPIKey())
+		active := config.ActiveProfile()
+		for _, name := range profiles {
+			marker := " "
+			if name == active {
+				marker = "*"
+			}
+			fmt.Printf("%s %s\n", marker, name)
+		}
+	},
+}
+
+var deleteCmd = &cobra.Command{
+	Use:   "delete <profile>",
+	Short: "Delete a profile and its API key",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := config.DeleteProfile(args[0]); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Profile %q deleted\n", strings.ToLower(args[0]))
 	},
 }
 
 func init() {
+	setKeyCmd.Flags().String("store", config.StoreFile, "Where to keep the key: file, keyring or encrypted")
+
 	configCmd.AddCommand(setKeyCmd)
+	configCmd.AddCommand(setBaseURLCmd)
+	configCmd.AddCommand(setRateLimitCmd)
 	configCmd.AddCommand(showCmd)
+	configCmd.AddCommand(useCmd)
+	configCmd.AddCommand(listCmd)
+	configCmd.AddCommand(deleteCmd)
 	rootCmd.AddCommand(configCmd)
 }
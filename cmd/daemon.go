@@ -0,0 +1,209 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/techulus/push-cli/internal/api"
+	"github.com/techulus/push-cli/internal/daemon"
+	"github.com/techulus/push-cli/internal/spool"
+)
+
+func newLogger(cmd *cobra.Command) (*slog.Logger, error) {
+	format, _ := cmd.Flags().GetString("log-format")
+	switch format {
+	case "text":
+		return slog.New(slog.NewTextHandler(os.Stderr, nil)), nil
+	case "json":
+		return slog.New(slog.NewJSONHandler(os.Stderr, nil)), nil
+	}
+	return nil, fmt.Errorf("invalid log format %q, valid formats: text, json", format)
+}
+
+func validateNotifyRequest(req api.NotifyRequest) error {
+	return validateSound(req.Sound)
+}
+
+// listen opens a Unix socket when socketPath is set, otherwise a TCP
+// listener. TCP addresses must be loopback unless allowRemote is set, since
+// anyone who can reach the daemon can send notifications with its key.
+func listen(addr, socketPath string, allowRemote bool) (net.Listener, error) {
+	if socketPath != "" {
+		// Only a leftover socket is removed, never a file that happens to
+		// be at the path.
+		if info, err := os.Lstat(socketPath); err == nil {
+			if info.Mode()&os.ModeSocket == 0 {
+				return nil, fmt.Errorf("%s exists and is not a socket", socketPath)
+			}
+			if err := os.Remove(socketPath); err != nil {
+				return nil, fmt.Errorf("removing stale socket: %w", err)
+			}
+		} else if !errors.Is(err, os.ErrNotExist) {
+			return nil, err
+		}
+		return listenUnix(socketPath)
+	}
+
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid listen address %q: %w", addr, err)
+	}
+	if !allowRemote {
+		ip := net.ParseIP(host)
+		if host != "localhost" && (ip == nil || !ip.IsLoopback()) {
+			return nil, fmt.Errorf("refusing to listen on non-loopback address %q without --allow-remote", addr)
+		}
+	}
+	return net.Listen("tcp", addr)
+}
+
+var daemonCmd = &cobra.Command{
+	Use:   "daemon",
+	Short: "Run a local relay that forwards notifications to Push",
+	Long: `Run a local relay that forwards notifications to Push.
+
+The daemon accepts the same JSON as the Push API on POST /notify,
+/notify-async and /notify/group/<group-id>, queues it in memory and forwards
+it with a single API client, so the API key stays in one process:
+
+  curl localhost:8787/notify -H 'Content-Type: application/json' \
+    -d '{"title":"Hi","body":"From cron"}'
+
+Requests must be sent as application/json. With --token (or
+PUSH_DAEMON_TOKEN) they also need an "Authorization: Bearer <token>" header;
+listening on a non-loopback address with --allow-remote requires one.
+
+GET /healthz reports queue depth and delivery counters.
+
+Every --digest-interval the daemon also flushes any digest that reached its
+--max-items or --max-age (see push digest), and every --queue-interval it
+sends queued notifications that are due, including those scheduled with
+--at or --in.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		addr, _ := cmd.Flags().GetString("listen")
+		socketPath, _ := cmd.Flags().GetString("socket")
+		allowRemote, _ := cmd.Flags().GetBool("allow-remote")
+		workers, _ := cmd.Flags().GetInt("workers")
+		queueSize, _ := cmd.Flags().GetInt("queue-size")
+		shutdownTimeout, _ := cmd.Flags().GetDuration("shutdown-timeout")
+		useSpool, _ := cmd.Flags().GetBool("spool")
+		digestInterval, _ := cmd.Flags().GetDuration("digest-interval")
+		queueInterval, _ := cmd.Flags().GetDuration("queue-interval")
+		token, _ := cmd.Flags().GetString("token")
+		if token == "" {
+			token = os.Getenv("PUSH_DAEMON_TOKEN")
+		}
+		if allowRemote && token == "" {
+			fail(exitValidation, fmt.Errorf("--allow-remote needs a bearer token, pass --token or set PUSH_DAEMON_TOKEN"))
+		}
+
+		logger, err := newLogger(cmd)
+		if err != nil {
+			fail(exitValidation, err)
+		}
+		client := newAPIClient(cmd)
+
+		var q *spool.Queue
+		if useSpool {
+			if q, err = openSpool(); err != nil {
+				fail(exitError, err)
+			}
+		}
+
+		relay := daemon.New(func(ctx context.Context, job daemon.Job) error {
+			_, err := sendToTarget(ctx, client, job.Target, job.GroupID, job.Request)
+			return err
+		}, daemon.Options{
+			Workers:   workers,
+			QueueSize: queueSize,
+			Validate:  validateNotifyRequest,
+			Token:     token,
+			Logger:    logger,
+			OnFailure: func(job daemon.Job, err error) {
+				if q == nil || !api.IsNetworkError(err) {
+					return
+				}
+				e := newSpoolEntry(job.Target, job.GroupID, job.Request)
+				e.IgnoreQuietHours = skipsQuietHours(cmd)
+				e.Attempts, e.LastError = 1, err.Error()
+				entry, qErr := q.Add(e)
+				if qErr != nil {
+					logger.Error("spooling notification failed", "id", job.ID, "error", qErr)
+					return
+				}
+				logger.Info("notification spooled", "id", job.ID, "spool_id", entry.ID)
+			},
+		})
+
+		ln, err := listen(addr, socketPath, allowRemote)
+		if err != nil {
+			fail(exitError, err)
+		}
+
+		// Deliveries keep going after a shutdown signal so the queue can
+		// drain; they are only cut off once the shutdown timeout expires.
+		sendCtx, cancelSends := context.WithCancel(context.WithoutCancel(cmd.Context()))
+		defer cancelSends()
+		relay.Start(sendCtx)
+		if queueInterval > 0 {
+			queue, err := openSpool()
+			if err != nil {
+				fail(exitError, err)
+			}
+			go runQueueFlusher(sendCtx, queue, newQueueClients(cmd), queueInterval, logger)
+		}
+		if digestInterval > 0 {
+			store, err := openDigests()
+			if err != nil {
+				fail(exitError, err)
+			}
+			go runDigestFlusher(sendCtx, store, client, digestInterval, logger)
+		}
+
+		server := &http.Server{Handler: relay.Handler(), ReadHeaderTimeout: 10 * time.Second}
+		serveErr := make(chan error, 1)
+		go func() { serveErr <- server.Serve(ln) }()
+		logger.Info("daemon listening", "address", ln.Addr().String(), "workers", workers, "queue_size", queueSize)
+
+		select {
+		case err := <-serveErr:
+			fail(exitError, err)
+		case <-cmd.Context().Done():
+		}
+
+		logger.Info("shutting down", "timeout", shutdownTimeout.String())
+		ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+		server.Shutdown(ctx)
+		if err := relay.Shutdown(ctx); err != nil {
+			cancelSends()
+			logger.Error("shutdown incomplete", "error", err)
+			os.Exit(exitError)
+		}
+		logger.Info("daemon stopped")
+	},
+}
+
+func init() {
+	addClientFlags(daemonCmd)
+	addSpoolFlag(daemonCmd)
+	daemonCmd.Flags().String("listen", "127.0.0.1:8787", "TCP address to listen on")
+	daemonCmd.Flags().String("socket", "", "Unix socket path to listen on instead of TCP")
+	daemonCmd.Flags().Bool("allow-remote", false, "Allow listening on a non-loopback address (needs --token)")
+	daemonCmd.Flags().String("token", "", "Require this bearer token on requests (or set PUSH_DAEMON_TOKEN)")
+	daemonCmd.Flags().Int("workers", 2, "Number of notifications forwarded at once")
+	daemonCmd.Flags().Int("queue-size", 100, "Maximum number of notifications waiting to be forwarded")
+	daemonCmd.Flags().Duration("shutdown-timeout", 30*time.Second, "How long to wait for queued notifications on shutdown")
+	daemonCmd.Flags().String("log-format", "text", "Log format: text or json")
+	daemonCmd.Flags().Duration("queue-interval", 15*time.Second, "How often to send due queued and scheduled notifications (0 to disable)")
+	daemonCmd.Flags().Duration("digest-interval", 10*time.Second, "How often to flush due digests (0 to disable)")
+	rootCmd.AddCommand(daemonCmd)
+}
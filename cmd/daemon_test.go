@@ -0,0 +1,38 @@
+package cmd
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestListenSocket(t *testing.T) {
+	dir := t.TempDir()
+
+	file := filepath.Join(dir, "file")
+	os.WriteFile(file, []byte("keep"), 0600)
+	if _, err := listen("", file, false); err == nil {
+		t.Fatal("expected listen to refuse a path that is not a socket")
+	}
+	if data, _ := os.ReadFile(file); string(data) != "keep" {
+		t.Error("expected the file to be left alone")
+	}
+
+	sock := filepath.Join(dir, "push.sock")
+	ln, err := listen("", sock, false)
+	if err != nil {
+		t.Fatalf("listen() error: %v", err)
+	}
+	if info, _ := os.Stat(sock); info.Mode().Perm() != 0600 {
+		t.Errorf("expected socket mode 0600, got %v", info.Mode().Perm())
+	}
+	// A socket left behind by an earlier run is replaced.
+	ln.(*net.UnixListener).SetUnlinkOnClose(false)
+	ln.Close()
+	ln, err = listen("", sock, false)
+	if err != nil {
+		t.Fatalf("listen() on a stale socket error: %v", err)
+	}
+	ln.Close()
+}
@@ -0,0 +1,344 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/techulus/push-cli/internal/api"
+	"github.com/techulus/push-cli/internal/config"
+	"github.com/techulus/push-cli/internal/digest"
+)
+
+func openDigests() (*digest.Store, error) {
+	dir, err := config.Dir()
+	if err != nil {
+		return nil, err
+	}
+	return digest.Open(filepath.Join(dir, "digests.json")), nil
+}
+
+func digestNotification(d digest.Digest) (api.NotifyRequest, error) {
+	title, body, err := d.Render(templateFuncs)
+	if err != nil {
+		return api.NotifyRequest{}, err
+	}
+	return api.NotifyRequest{Title: title, Body: body, Sound: d.Settings.Sound, Channel: d.Settings.Channel}, nil
+}
+
+type digestFlushResult struct {
+	Key    string `json:"key"`
+	Items  int    `json:"items"`
+	Error  string `json:"error,omitempty"`
+	Parked bool   `json:"parked,omitempty"`
+}
+
+// flushDigests sends one notification for each digest taken from store.
+// The items of any that fail are put back. Like the offline queue, a digest
+// that failed for a reason retrying won't fix, such as a template error or
+// a rejected request, is parked instead of being retried on every flush.
+func flushDigests(ctx context.Context, store *digest.Store, client *api.Client, taken []digest.Digest) ([]digestFlushResult, error) {
+	results := make([]digestFlushResult, len(taken))
+	var lastErr error
+	for i, d := range taken {
+		results[i] = digestFlushResult{Key: d.Key, Items: len(d.Items)}
+		req, err := digestNotification(d)
+		if err == nil {
+			_, err = client.NotifyContext(ctx, req)
+		}
+		if err == nil {
+			continue
+		}
+		results[i].Error = err.Error()
+		lastErr = err
+
+		retry := api.IsNetworkError(err) || api.IsRateLimited(err) || api.IsServerError(err) || ctx.Err() != nil
+		if retry {
+			err = store.Restore(d)
+		} else {
+			results[i].Parked = true
+			err = store.Park(d, results[i].Error)
+		}
+		if err != nil {
+			return results, err
+		}
+	}
+	return results, lastErr
+}
+
+// runDigestFlusher flushes due digests every interval until ctx is done.
+func runDigestFlusher(ctx context.Context, store *digest.Store, client *api.Client, interval time.Duration, logger *slog.Logger) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		now := time.Now()
+		taken, err := store.Take(func(d digest.Digest) bool { return d.Due(now) })
+		if err != nil {
+			logger.Error("reading digests failed", "error", err)
+			continue
+		}
+		results, _ := flushDigests(ctx, store, client, taken)
+		for _, r := range results {
+			if r.Error != "" {
+				logger.Error("digest flush failed", "key", r.Key, "items", r.Items, "parked", r.Parked, "error", r.Error)
+			} else {
+				logger.Info("digest flushed", "key", r.Key, "items", r.Items)
+			}
+		}
+	}
+}
+
+var digestCmd = &cobra.Command{
+	Use:   "digest",
+	Short: "Collect events and send them as one summary notification",
+	Long: `Collect events and send them as one summary notification.
+
+"push digest add" only appends the body to a local buffer named by --key.
+"push digest flush" sends one notification per buffer, with the number of
+items in the title and the first --show bodies followed by "+K more".
+
+While "push daemon" runs, a buffer is also flushed once it holds --max-items
+items or its oldest item is --max-age old.
+
+  push digest add --key deploys --body "api v1.4.2 deployed" --max-items 20 --max-age 15m
+  push digest flush --key deploys
+
+The title and body are Go templates with .Key, .Count, .Items, .Shown, .More,
+.First and .Last, where each item has .Body and .Time.`,
+}
+
+var digestAddCmd = &cobra.Command{
+	Use:   "add",
+	Short: "Append an event to a digest",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		key, _ := cmd.Flags().GetString("key")
+		if err := validateStateName("digest key", key); err != nil {
+			fail(exitValidation, err)
+		}
+		body, err := readBodyFromStdinOrFlag(cmd)
+		if err != nil {
+			fail(exitValidation, err)
+		}
+
+		flags := cmd.Flags()
+		var settings digest.Settings
+		configure := func(s *digest.Settings) {
+			if flags.Changed("title-template") {
+				s.TitleTemplate, _ = flags.GetString("title-template")
+			}
+			if flags.Changed("body-template") {
+				s.BodyTemplate, _ = flags.GetString("body-template")
+			}
+			if flags.Changed("show") {
+				s.Show, _ = flags.GetInt("show")
+			}
+			if flags.Changed("sound") {
+				s.Sound, _ = flags.GetString("sound")
+			}
+			if flags.Changed("channel") {
+				s.Channel, _ = flags.GetString("channel")
+			}
+			if flags.Changed("max-items") {
+				s.MaxItems, _ = flags.GetInt("max-items")
+			}
+			if flags.Changed("max-age") {
+				s.MaxAge, _ = flags.GetDuration("max-age")
+			}
+		}
+		configure(&settings)
+		if err := validateSound(settings.Sound); err != nil {
+			fail(exitValidation, err)
+		}
+		if settings.Show < 0 || settings.MaxItems < 0 || settings.MaxAge < 0 {
+			fail(exitValidation, errors.New("--show, --max-items and --max-age can't be negative"))
+		}
+		if err := settings.Validate(templateFuncs); err != nil {
+			fail(exitValidation, err)
+		}
+
+		store, err := openDigests()
+		if err != nil {
+			fail(exitError, err)
+		}
+		d, err := store.Add(key, digest.Item{Body: body, Time: time.Now()}, configure)
+		if err != nil {
+			fail(exitError, err)
+		}
+
+		switch outputFormat {
+		case outputJSON:
+			printJSON(struct {
+				Key     string `json:"key"`
+				Pending int    `json:"pending"`
+			}{d.Key, len(d.Items)})
+		case outputText:
+			fmt.Printf("Added to digest %s (%d pending)\n", d.Key, len(d.Items))
+		}
+	},
+}
+
+var digestFlushCmd = &cobra.Command{
+	Use:   "flush",
+	Short: "Send pending digests as summary notifications",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		key, _ := cmd.Flags().GetString("key")
+		due, _ := cmd.Flags().GetBool("due")
+
+		store, err := openDigests()
+		if err != nil {
+			fail(exitError, err)
+		}
+		client := newAPIClient(cmd)
+
+		var taken []digest.Digest
+		if key != "" {
+			d, err := store.TakeKey(key)
+			if errors.Is(err, digest.ErrUnknown) {
+				fail(exitValidation, err)
+			}
+			if err != nil {
+				fail(exitError, err)
+			}
+			if len(d.Items) > 0 {
+				taken = []digest.Digest{d}
+			}
+		} else {
+			now := time.Now()
+			taken, err = store.Take(func(d digest.Digest) bool { return !due || d.Due(now) })
+			if err != nil {
+				fail(exitError, err)
+			}
+		}
+
+		results, err := flushDigests(cmd.Context(), store, client, taken)
+		switch outputFormat {
+		case outputJSON:
+			printJSON(results)
+		case outputText:
+			if len(results) == 0 {
+				fmt.Println("No pending digests")
+			}
+			for _, r := range results {
+				if r.Error == "" {
+					fmt.Printf("Sent digest %s (%d items)\n", r.Key, r.Items)
+				}
+			}
+		}
+		for _, r := range results {
+			if r.Error == "" {
+				continue
+			}
+			fmt.Fprintf(os.Stderr, "Error: digest %s: %s\n", r.Key, r.Error)
+			if r.Parked {
+				fmt.Fprintf(os.Stderr, "Digest %s is parked until its settings change or it is flushed again without --due\n", r.Key)
+			}
+		}
+		if err != nil {
+			os.Exit(exitCode(err))
+		}
+	},
+}
+
+var digestListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List digests and their pending items",
+	Run: func(cmd *cobra.Command, args []string) {
+		store, err := openDigests()
+		if err != nil {
+			fail(exitError, err)
+		}
+		list, err := store.List()
+		if err != nil {
+			fail(exitError, err)
+		}
+
+		switch outputFormat {
+		case outputJSON:
+			printJSON(list)
+		case outputText:
+			if len(list) == 0 {
+				fmt.Println("No digests")
+				return
+			}
+			now := time.Now()
+			w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(w, "KEY\tPENDING\tOLDEST\tMAX ITEMS\tMAX AGE\tSTATUS")
+			for _, d := range list {
+				oldest, maxItems, maxAge, status := "-", "-", "-", "-"
+				if len(d.Items) > 0 {
+					oldest = formatDuration(now.Sub(d.Items[0].Time)) + " ago"
+				}
+				if d.Settings.MaxItems > 0 {
+					maxItems = fmt.Sprint(d.Settings.MaxItems)
+				}
+				if d.Settings.MaxAge > 0 {
+					maxAge = d.Settings.MaxAge.String()
+				}
+				if d.Error != "" {
+					status = "parked: " + d.Error
+				}
+				fmt.Fprintf(w, "%s\t%d\t%s\t%s\t%s\t%s\n", d.Key, len(d.Items), oldest, maxItems, maxAge, status)
+			}
+			w.Flush()
+		}
+	},
+}
+
+var digestRemoveCmd = &cobra.Command{
+	Use:   "remove <key>",
+	Short: "Delete a digest and its pending items without sending them",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		store, err := openDigests()
+		if err != nil {
+			fail(exitError, err)
+		}
+		err = store.Remove(args[0])
+		if errors.Is(err, digest.ErrUnknown) {
+			fail(exitValidation, err)
+		}
+		if err != nil {
+			fail(exitError, err)
+		}
+		if outputFormat == outputText {
+			fmt.Printf("Digest %s removed\n", args[0])
+		}
+	},
+}
+
+func init() {
+	digestAddCmd.Flags().String("key", "", "Name of the digest (required)")
+	digestAddCmd.Flags().String("body", "", "Event text (use '-' to read from stdin)")
+	digestAddCmd.Flags().String("title-template", "", "Summary title template (default \""+digest.DefaultTitleTemplate+"\")")
+	digestAddCmd.Flags().String("body-template", "", "Summary body template (default: one line per shown item, then \"+K more\")")
+	digestAddCmd.Flags().Int("show", digest.DefaultShow, "Number of items listed in the summary")
+	digestAddCmd.Flags().String("sound", "", "Summary notification sound")
+	digestAddCmd.Flags().String("channel", "", "Summary notification channel")
+	digestAddCmd.Flags().Int("max-items", 0, "Flush from push daemon once this many items are pending")
+	digestAddCmd.Flags().Duration("max-age", 0, "Flush from push daemon once the oldest item is this old")
+	digestAddCmd.MarkFlagRequired("key")
+
+	addClientFlags(digestFlushCmd)
+	digestFlushCmd.Flags().String("key", "", "Digest to flush (default: all)")
+	digestFlushCmd.Flags().Bool("due", false, "Only flush digests that reached --max-items or --max-age")
+
+	digestCmd.AddCommand(digestAddCmd)
+	digestCmd.AddCommand(digestFlushCmd)
+	digestCmd.AddCommand(digestListCmd)
+	digestCmd.AddCommand(digestRemoveCmd)
+	rootCmd.AddCommand(digestCmd)
+}
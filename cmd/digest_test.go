@@ -0,0 +1,64 @@
+package cmd
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/techulus/push-cli/internal/api"
+	"github.com/techulus/push-cli/internal/digest"
+)
+
+func TestDigestNotification(t *testing.T) {
+	d := digest.Digest{
+		Key:      "deploys",
+		Items:    []digest.Item{{Body: "api v1.4.2", Time: time.Now()}, {Body: "web v2.0.0", Time: time.Now()}},
+		Settings: digest.Settings{TitleTemplate: `{{upper .Key}}: {{.Count}}`, Show: 1, Sound: "pop", Channel: "ops"},
+	}
+	req, err := digestNotification(d)
+	if err != nil {
+		t.Fatalf("digestNotification() error: %v", err)
+	}
+	if req.Title != "DEPLOYS: 2" || req.Body != "api v1.4.2\n+1 more" {
+		t.Errorf("unexpected notification %q / %q", req.Title, req.Body)
+	}
+	if req.Sound != "pop" || req.Channel != "ops" {
+		t.Errorf("expected sound and channel from the settings, got %+v", req)
+	}
+}
+
+func TestFlushDigests_ParksPermanentFailures(t *testing.T) {
+	status := http.StatusBadRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+		w.Write([]byte(`{"success":false,"message":"nope"}`))
+	}))
+	defer server.Close()
+	client := api.NewClient("test-key", api.WithBaseURL(server.URL), api.WithRetryPolicy(api.RetryPolicy{}))
+	store := digest.Open(filepath.Join(t.TempDir(), "digests.json"))
+	now := time.Now()
+
+	store.Add("bad", digest.Item{Body: "a", Time: now}, func(s *digest.Settings) { s.TitleTemplate = `{{.Nope}}` })
+	store.Add("rejected", digest.Item{Body: "b", Time: now}, nil)
+	taken, _ := store.Take(func(digest.Digest) bool { return true })
+	results, err := flushDigests(context.Background(), store, client, taken)
+	if err == nil || !results[0].Parked || !results[1].Parked {
+		t.Fatalf("expected both digests to be parked, got %+v, %v", results, err)
+	}
+	if strings.HasPrefix(results[0].Error, "digest") {
+		t.Errorf("expected the error without the digest prefix, got %q", results[0].Error)
+	}
+
+	status = http.StatusServiceUnavailable
+	store.Add("later", digest.Item{Body: "c", Time: now}, nil)
+	later, _ := store.TakeKey("later")
+	results, _ = flushDigests(context.Background(), store, client, []digest.Digest{later})
+	list, _ := store.List()
+	if results[0].Parked || list[1].Error != "" || len(list[1].Items) != 1 {
+		t.Errorf("expected a server error to restore the digest without parking it, got %+v", list[1])
+	}
+}
@@ -0,0 +1,312 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/techulus/push-cli/internal/api"
+	"github.com/techulus/push-cli/internal/config"
+)
+
+const (
+	checkPass = "pass"
+	checkFail = "fail"
+	checkSkip = "skip"
+)
+
+type checkResult struct {
+	Name   string `json:"name"`
+	Status string `json:"status"`
+	Detail string `json:"detail"`
+}
+
+type doctorOutput struct {
+	Success bool          `json:"success"`
+	Checks  []checkResult `json:"checks"`
+}
+
+func passed(name, format string, a ...interface{}) checkResult {
+	return checkResult{Name: name, Status: checkPass, Detail: fmt.Sprintf(format, a...)}
+}
+
+func failed(name, format string, a ...interface{}) checkResult {
+	return checkResult{Name: name, Status: checkFail, Detail: fmt.Sprintf(format, a...)}
+}
+
+func skipped(name, format string, a ...interface{}) checkResult {
+	return checkResult{Name: name, Status: checkSkip, Detail: fmt.Sprintf(format, a...)}
+}
+
+func checkConfigFile() checkResult {
+	path := config.File()
+	if path == "" {
+		return skipped("config file", "no config file, using flags and environment variables only")
+	}
+	if problems := config.Warnings(); len(problems) > 0 {
+		return failed("config file", "%s:\n%s", path, strings.Join(problems, "\n"))
+	}
+	return passed("config file", "%s", path)
+}
+
+// checkSettings validates the effective settings with the rules their flags
+// use.
+func checkSettings() checkResult {
+	var problems []string
+	for _, s := range settings {
+		value, src := config.LookupSetting(s.Key, s.Env)
+		if value == "" {
+			continue
+		}
+		if _, err := s.parse(value); err != nil {
+			problems = append(problems, fmt.Sprintf("%s (from %s): %v", s.Key, describeSource(src, s.Key, s.Env), err))
+		}
+	}
+	if store, _ := config.LookupSetting("api_key_store", ""); store != "" {
+		switch store {
+		case config.StoreFile, config.StoreKeyring, config.StoreEncrypted:
+		default:
+			problems = append(problems, fmt.Sprintf("api_key_store: invalid store %q, valid stores: file, keyring, encrypted", store))
+		}
+	}
+	if _, err := quietHoursPolicy(); err != nil {
+		problems = append(problems, err.Error())
+	}
+	if len(problems) > 0 {
+		return failed("settings", "%s", strings.Join(problems, "\n"))
+	}
+	return passed("settings", "all values valid")
+}
+
+// checkPermissions makes sure files holding secrets aren't readable by
+// other users.
+func checkPermissions() checkResult {
+	if runtime.GOOS == "windows" {
+		return skipped("permissions", "not checked on Windows")
+	}
+	dir, err := config.Dir()
+	if err != nil {
+		return failed("permissions", "%v", err)
+	}
+
+	var problems []string
+	var checked int
+	check := func(path string, want fs.FileMode) {
+		info, err := os.Stat(path)
+		if errors.Is(err, fs.ErrNotExist) {
+			return
+		}
+		if err != nil {
+			problems = append(problems, err.Error())
+			return
+		}
+		checked++
+		if perm := info.Mode().Perm(); perm&0077 != 0 {
+			problems = append(problems, fmt.Sprintf("%s is %04o, run: chmod %o %s", path, perm, want, path))
+		}
+	}
+	check(dir, 0700)
+	check(filepath.Join(dir, "config.yaml"), 0600)
+	check(filepath.Join(dir, "keys"), 0700)
+	keys, _ := filepath.Glob(filepath.Join(dir, "keys", "*.age"))
+	for _, path := range keys {
+		check(path, 0600)
+	}
+
+	if len(problems) > 0 {
+		return failed("permissions", "%s", strings.Join(problems, "\n"))
+	}
+	if checked == 0 {
+		return skipped("permissions", "%s doesn't exist yet", dir)
+	}
+	return passed("permissions", "only readable by you")
+}
+
+// checkAPIKey catches missing keys and the usual copy and paste mistakes.
+// It doesn't send anything, so it can't tell whether the key is accepted.
+func checkAPIKey() checkResult {
+	key, src, err := config.ResolveAPIKey()
+	if err != nil {
+		return failed("api key", "%v", err)
+	}
+	if key == "" {
+		return failed("api key", "not configured, run: push config set-key <api-key> (or set %s)", config.APIKeyEnv)
+	}
+	from := describeSource(src, "--api-key", config.APIKeyEnv)
+	switch {
+	case strings.ContainsAny(key, " \t\r\n"):
+		return failed("api key", "from %s contains whitespace", from)
+	case strings.ContainsAny(key, `"'`):
+		return failed("api key", "from %s contains quotes", from)
+	case strings.ContainsFunc(key, func(r rune) bool { return r < 0x21 || r > 0x7e }):
+		return failed("api key", "from %s contains non-printable or non-ASCII characters", from)
+	case len(key) < 16:
+		return failed("api key", "from %s is only %d characters, it may be truncated", from, len(key))
+	}
+	return passed("api key", "%s from %s", config.MaskedAPIKey(), from)
+}
+
+// doctorEndpoint returns the URL to probe: the base URL, or endpoint
+// resolved against it.
+func doctorEndpoint(endpoint string) (string, error) {
+	baseURL, _ := config.LookupBaseURL()
+	if baseURL == "" {
+		baseURL = api.DefaultBaseURL
+	}
+	if err := api.ValidateBaseURL(baseURL); err != nil {
+		return "", err
+	}
+	switch {
+	case endpoint == "":
+		return baseURL, nil
+	case strings.HasPrefix(endpoint, "http://"), strings.HasPrefix(endpoint, "https://"):
+		if _, err := url.Parse(endpoint); err != nil {
+			return "", fmt.Errorf("invalid --endpoint %q: %w", endpoint, err)
+		}
+		return endpoint, nil
+	}
+	return strings.TrimSuffix(baseURL, "/") + "/" + strings.TrimPrefix(endpoint, "/"), nil
+}
+
+func checkProxy(target string) checkResult {
+	req, err := http.NewRequest(http.MethodGet, target, nil)
+	if err != nil {
+		return failed("proxy", "%v", err)
+	}
+	proxy, err := http.ProxyFromEnvironment(req)
+	if err != nil {
+		return failed("proxy", "invalid proxy setting: %v", err)
+	}
+	if proxy == nil {
+		return passed("proxy", "not used for %s", req.URL.Host)
+	}
+	return passed("proxy", "%s for %s", proxy.Redacted(), req.URL.Host)
+}
+
+// checkReachable requests target and returns the response's Date header
+// for the clock check. Any response means the server is up, except a 5xx
+// other than 501, which only says GET isn't supported there.
+func checkReachable(target string, timeout time.Duration) (checkResult, string) {
+	client := &http.Client{Timeout: timeout}
+	start := time.Now()
+	resp, err := client.Get(target)
+	if err != nil {
+		return failed("base url", "%v", err), ""
+	}
+	resp.Body.Close()
+	elapsed := time.Since(start).Round(time.Millisecond)
+	if resp.StatusCode >= 500 && resp.StatusCode != http.StatusNotImplemented {
+		return failed("base url", "%s answered %s", target, resp.Status), resp.Header.Get("Date")
+	}
+	return passed("base url", "%s answered %s in %s", target, resp.Status, elapsed), resp.Header.Get("Date")
+}
+
+// checkClock compares the local clock with the server's Date header.
+// Schedules, quiet hours and heartbeats all rely on it.
+func checkClock(date string, maxSkew time.Duration) checkResult {
+	if date == "" {
+		return skipped("clock", "the server didn't send a Date header")
+	}
+	serverTime, err := http.ParseTime(date)
+	if err != nil {
+		return skipped("clock", "unreadable Date header %q", date)
+	}
+	// The header is truncated to the second, so compare against the middle
+	// of that second.
+	skew := time.Since(serverTime.Add(500 * time.Millisecond)).Round(time.Second)
+	abs := skew
+	if abs < 0 {
+		abs = -abs
+	}
+	direction := "ahead of"
+	if skew < 0 {
+		direction = "behind"
+	}
+	if abs > maxSkew {
+		return failed("clock", "%s %s the server, more than %s", formatDuration(abs), direction, maxSkew)
+	}
+	if abs == 0 {
+		return passed("clock", "in sync with the server")
+	}
+	return passed("clock", "%s %s the server", formatDuration(abs), direction)
+}
+
+var configDoctorCmd = &cobra.Command{
+	Use:   "doctor",
+	Short: "Check the configuration and the connection to the Push API",
+	Long: `Check the configuration and the connection to the Push API.
+
+The doctor checks the config file for unknown keys and invalid values, the
+permissions of the files holding the API key, that an API key is configured
+and looks valid, the proxy used to reach the base URL, that the base URL (or
+--endpoint) answers, and that the local clock agrees with the server's.
+
+It exits with 1 if any check fails. No notification is sent.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		endpoint, _ := cmd.Flags().GetString("endpoint")
+		timeout, _ := cmd.Flags().GetDuration("timeout")
+		maxSkew, _ := cmd.Flags().GetDuration("max-clock-skew")
+
+		checks := []checkResult{checkConfigFile(), checkSettings(), checkPermissions(), checkAPIKey()}
+		target, err := doctorEndpoint(endpoint)
+		if err != nil {
+			checks = append(checks,
+				failed("proxy", "%v", err),
+				failed("base url", "%v", err),
+				skipped("clock", "no server to compare with"))
+		} else {
+			checks = append(checks, checkProxy(target))
+			reachable, date := checkReachable(target, timeout)
+			checks = append(checks, reachable, checkClock(date, maxSkew))
+		}
+
+		out := doctorOutput{Success: true, Checks: checks}
+		failures := 0
+		for _, c := range checks {
+			if c.Status == checkFail {
+				out.Success = false
+				failures++
+			}
+		}
+
+		switch outputFormat {
+		case outputJSON:
+			printJSON(out)
+		case outputText:
+			w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+			for _, c := range checks {
+				lines := strings.Split(c.Detail, "\n")
+				fmt.Fprintf(w, "%s\t%s\t%s\n", strings.ToUpper(c.Status), c.Name, lines[0])
+				for _, line := range lines[1:] {
+					fmt.Fprintf(w, "\t\t  %s\n", line)
+				}
+			}
+			w.Flush()
+			if failures > 0 {
+				fmt.Printf("\n%d of %d checks failed\n", failures, len(checks))
+			} else {
+				fmt.Println("\nAll checks passed")
+			}
+		}
+		if failures > 0 {
+			os.Exit(exitError)
+		}
+	},
+}
+
+func init() {
+	configDoctorCmd.Flags().String("endpoint", "", "URL or path under the base URL to check instead of the base URL, e.g. /health")
+	configDoctorCmd.Flags().Duration("timeout", 10*time.Second, "Timeout for the connection check")
+	configDoctorCmd.Flags().Duration("max-clock-skew", time.Minute, "Largest difference from the server's clock that passes")
+	configCmd.AddCommand(configDoctorCmd)
+}
@@ -0,0 +1,75 @@
+package cmd
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/techulus/push-cli/internal/config"
+)
+
+func TestCheckClock(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		date   string
+		status string
+	}{
+		{now.UTC().Format(http.TimeFormat), checkPass},
+		{now.Add(-10 * time.Minute).UTC().Format(http.TimeFormat), checkFail},
+		{now.Add(10 * time.Minute).UTC().Format(http.TimeFormat), checkFail},
+		{"", checkSkip},
+		{"yesterday", checkSkip},
+	}
+	for _, tt := range tests {
+		if got := checkClock(tt.date, time.Minute); got.Status != tt.status {
+			t.Errorf("checkClock(%q) = %+v, want %s", tt.date, got, tt.status)
+		}
+	}
+}
+
+func TestCheckReachable(t *testing.T) {
+	status := http.StatusNotFound
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+	}))
+	defer srv.Close()
+
+	result, date := checkReachable(srv.URL, time.Second)
+	if result.Status != checkPass || date == "" {
+		t.Errorf("checkReachable() = %+v, %q, want a pass with the Date header", result, date)
+	}
+	status = http.StatusServiceUnavailable
+	if result, _ := checkReachable(srv.URL, time.Second); result.Status != checkFail {
+		t.Errorf("checkReachable() on a 503 = %+v, want a failure", result)
+	}
+}
+
+func TestDoctorEndpoint(t *testing.T) {
+	t.Setenv(config.BaseURLEnv, "https://push.example.com/api/v1/")
+	tests := map[string]string{
+		"":                           "https://push.example.com/api/v1/",
+		"/health":                    "https://push.example.com/api/v1/health",
+		"https://status.example.com": "https://status.example.com",
+	}
+	for endpoint, want := range tests {
+		if got, err := doctorEndpoint(endpoint); err != nil || got != want {
+			t.Errorf("doctorEndpoint(%q) = %q, %v, want %q", endpoint, got, err, want)
+		}
+	}
+}
+
+func TestCheckAPIKey(t *testing.T) {
+	tests := map[string]string{
+		"abcd1234efgh5678":   checkPass,
+		"abcd1234 efgh5678":  checkFail,
+		`"abcd1234efgh5678"`: checkFail,
+		"abcd1234":           checkFail,
+	}
+	for key, want := range tests {
+		t.Setenv(config.APIKeyEnv, key)
+		if got := checkAPIKey(); got.Status != want {
+			t.Errorf("checkAPIKey() with %q = %+v, want %s", key, got, want)
+		}
+	}
+}
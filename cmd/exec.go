@@ -0,0 +1,228 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+const (
+	exitCommandNotRun = 127
+	maxTailLineLength = 200
+)
+
+// tailBuffer keeps the last n lines written to it. It is safe for concurrent
+// use so stdout and stderr of a child process can share one buffer.
+type tailBuffer struct {
+	mu      sync.Mutex
+	n       int
+	lines   []string
+	partial bytes.Buffer
+}
+
+func newTailBuffer(n int) *tailBuffer {
+	return &tailBuffer{n: n}
+}
+
+func (t *tailBuffer) Write(p []byte) (int, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.partial.Write(p)
+	for {
+		line, err := t.partial.ReadString('\n')
+		if err != nil {
+			// Keep the unterminated remainder for the next write.
+			t.partial.Reset()
+			t.partial.WriteString(line)
+			break
+		}
+		t.add(strings.TrimRight(line, "\r\n"))
+	}
+	return len(p), nil
+}
+
+func (t *tailBuffer) add(line string) {
+	if t.n <= 0 {
+		return
+	}
+	if len(line) > maxTailLineLength {
+		line = line[:maxTailLineLength] + "..."
+	}
+	t.lines = append(t.lines, line)
+	if len(t.lines) > t.n {
+		t.lines = t.lines[len(t.lines)-t.n:]
+	}
+}
+
+func (t *tailBuffer) Lines() []string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.partial.Len() > 0 {
+		t.add(t.partial.String())
+		t.partial.Reset()
+	}
+	return append([]string(nil), t.lines...)
+}
+
+type execResult struct {
+	command  string
+	exitCode int
+	duration time.Duration
+	tail     []string
+}
+
+func (r execResult) body(prefix string) string {
+	var b strings.Builder
+	if prefix != "" {
+		b.WriteString(prefix)
+		b.WriteString("\n\n")
+	}
+	fmt.Fprintf(&b, "$ %s\n", r.command)
+	fmt.Fprintf(&b, "Exit code %d after %s", r.exitCode, formatDuration(r.duration))
+	if len(r.tail) > 0 {
+		b.WriteString("\n\n")
+		b.WriteString(strings.Join(r.tail, "\n"))
+	}
+	return b.String()
+}
+
+func formatDuration(d time.Duration) string {
+	if d < time.Second {
+		return d.Round(time.Millisecond).String()
+	}
+	return d.Round(time.Second).String()
+}
+
+func runCommand(args []string, tail *tailBuffer) execResult {
+	child := exec.Command(args[0], args[1:]...)
+	child.Stdin = os.Stdin
+	child.Stdout = io.MultiWriter(os.Stdout, tail)
+	child.Stderr = io.MultiWriter(os.Stderr, tail)
+
+	start := time.Now()
+	result := execResult{command: strings.Join(args, " ")}
+	if err := child.Start(); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		fmt.Fprintln(tail, err.Error())
+		result.exitCode = exitCommandNotRun
+		result.tail = tail.Lines()
+		return result
+	}
+
+	// Ctrl-C already reaches the child through the terminal's process group,
+	// but SIGTERM sent to push alone has to be passed on. Either way the
+	// notification goes out once the child exits.
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, syscall.SIGTERM)
+	defer signal.Stop(sigs)
+	done := make(chan struct{})
+	go func() {
+		select {
+		case sig := <-sigs:
+			child.Process.Signal(sig)
+		case <-done:
+		}
+	}()
+
+	err := child.Wait()
+	close(done)
+	result.duration = time.Since(start)
+	result.tail = tail.Lines()
+
+	var exitErr *exec.ExitError
+	switch {
+	case err == nil:
+		result.exitCode = 0
+	case errors.As(err, &exitErr):
+		result.exitCode = exitErr.ExitCode()
+		if status, ok := exitErr.Sys().(syscall.WaitStatus); ok && status.Signaled() {
+			result.exitCode = 128 + int(status.Signal())
+		}
+	default:
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		result.exitCode = exitError
+	}
+	return result
+}
+
+var execCmd = &cobra.Command{
+	Use:   "exec [flags] -- <command> [args...]",
+	Short: "Run a command and send a notification when it finishes",
+	Long: `Run a command and send a notification when it finishes.
+
+The command's output is streamed through unchanged. The notification contains
+the exit code, how long the command ran and the last lines of its output.
+Any --body is placed above that summary.`,
+	Args: cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		lines, _ := cmd.Flags().GetInt("lines")
+		failureOnly, _ := cmd.Flags().GetBool("failure-only")
+		failureSound, _ := cmd.Flags().GetString("failure-sound")
+		propagate, _ := cmd.Flags().GetBool("propagate-exit-code")
+		prefix, _ := cmd.Flags().GetString("body")
+
+		if err := validateSound(failureSound); err != nil {
+			fail(exitValidation, err)
+		}
+		// Check the notify flags before running anything.
+		req, err := notifyRequestWithBody(cmd, prefix)
+		if err != nil {
+			fail(exitValidation, err)
+		}
+		client := newAPIClient(cmd)
+
+		result := runCommand(args, newTailBuffer(lines))
+		failed := result.exitCode != 0
+
+		exitStatus := 0
+		if propagate {
+			exitStatus = result.exitCode
+		}
+		if failureOnly && !failed {
+			os.Exit(exitStatus)
+		}
+
+		req.Body = result.body(prefix)
+		if failed && failureSound != "" {
+			req.Sound = failureSound
+		}
+
+		// The signal context may already be canceled if the child was
+		// interrupted; the notification should still be delivered.
+		resp, err := client.NotifyContext(context.WithoutCancel(cmd.Context()), req)
+		if err != nil {
+			printError(exitCode(err), err)
+			if exitStatus == 0 {
+				exitStatus = exitCode(err)
+			}
+			os.Exit(exitStatus)
+		}
+		printResponse(resp)
+		os.Exit(exitStatus)
+	},
+}
+
+func init() {
+	addNotifyFlags(execCmd)
+	addClientFlags(execCmd)
+	execCmd.Flags().Lookup("body").Usage = "Text placed above the command summary"
+	execCmd.Flags().Int("lines", 10, "Number of trailing output lines to include")
+	execCmd.Flags().Bool("failure-only", false, "Only notify when the command exits non-zero")
+	execCmd.Flags().String("failure-sound", "", "Sound to use when the command fails")
+	execCmd.Flags().Bool("propagate-exit-code", true, "Exit with the command's exit code")
+	execCmd.Flags().SetInterspersed(false)
+	rootCmd.AddCommand(execCmd)
+}
@@ -0,0 +1,75 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTailBuffer_KeepsLastLines(t *testing.T) {
+	tail := newTailBuffer(2)
+	fmt.Fprint(tail, "one\ntwo\nthr")
+	fmt.Fprint(tail, "ee\nfour")
+
+	got := tail.Lines()
+	want := []string{"three", "four"}
+	if strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Errorf("Lines() = %v, want %v", got, want)
+	}
+}
+
+func TestTailBuffer_TruncatesLongLines(t *testing.T) {
+	tail := newTailBuffer(1)
+	fmt.Fprintln(tail, strings.Repeat("x", maxTailLineLength+50))
+
+	got := tail.Lines()
+	if len(got) != 1 || len(got[0]) != maxTailLineLength+3 {
+		t.Errorf("expected one truncated line, got %v", got)
+	}
+}
+
+func TestTailBuffer_Zero(t *testing.T) {
+	tail := newTailBuffer(0)
+	fmt.Fprintln(tail, "ignored")
+	if got := tail.Lines(); len(got) != 0 {
+		t.Errorf("expected no lines, got %v", got)
+	}
+}
+
+func TestExecResultBody(t *testing.T) {
+	r := execResult{
+		command:  "make deploy",
+		exitCode: 2,
+		duration: 3*time.Minute + 12*time.Second + 400*time.Millisecond,
+		tail:     []string{"step 1", "boom"},
+	}
+	want := "Deploying prod\n\n$ make deploy\nExit code 2 after 3m12s\n\nstep 1\nboom"
+	if got := r.body("Deploying prod"); got != want {
+		t.Errorf("body() = %q, want %q", got, want)
+	}
+
+	r.tail = nil
+	want = "$ make deploy\nExit code 2 after 3m12s"
+	if got := r.body(""); got != want {
+		t.Errorf("body() = %q, want %q", got, want)
+	}
+}
+
+func TestRunCommand_ExitCodeAndOutput(t *testing.T) {
+	result := runCommand([]string{"sh", "-c", "echo out; echo err >&2; exit 3"}, newTailBuffer(5))
+	if result.exitCode != 3 {
+		t.Errorf("expected exit code 3, got %d", result.exitCode)
+	}
+	joined := strings.Join(result.tail, "\n")
+	if !strings.Contains(joined, "out") || !strings.Contains(joined, "err") {
+		t.Errorf("expected stdout and stderr in tail, got %v", result.tail)
+	}
+}
+
+func TestRunCommand_NotFound(t *testing.T) {
+	result := runCommand([]string{"push-cli-test-no-such-command"}, newTailBuffer(5))
+	if result.exitCode != exitCommandNotRun {
+		t.Errorf("expected exit code %d, got %d", exitCommandNotRun, result.exitCode)
+	}
+}
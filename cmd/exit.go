@@ -0,0 +1,48 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"os"
+
+	"github.com/techulus/push-cli/internal/api"
+)
+
+// Process exit codes. Scripts can rely on these; keep them stable and in
+// sync with the README.
+const (
+	exitError       = 1
+	exitValidation  = 2
+	exitAuth        = 3
+	exitRateLimited = 4
+	exitNetwork     = 5
+	exitServer      = 6
+	exitInterrupted = 130
+)
+
+func exitCode(err error) int {
+	switch {
+	case errors.Is(err, context.Canceled):
+		return exitInterrupted
+	case api.IsUnauthorized(err):
+		return exitAuth
+	case api.IsRateLimited(err):
+		return exitRateLimited
+	case api.IsValidation(err):
+		return exitValidation
+	case api.IsServerError(err):
+		return exitServer
+	case api.IsNetworkError(err):
+		return exitNetwork
+	}
+	return exitError
+}
+
+func fail(code int, err error) {
+	printError(code, err)
+	os.Exit(code)
+}
+
+func exitWithError(err error) {
+	fail(exitCode(err), err)
+}
@@ -0,0 +1,69 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/techulus/push-cli/internal/api"
+)
+
+func apiErrorFor(t *testing.T, status int) error {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+		w.Write([]byte(`{"success":false,"message":"nope"}`))
+	}))
+	defer server.Close()
+
+	client := api.NewClient("test-key", api.WithBaseURL(server.URL))
+	_, err := client.Notify(api.NotifyRequest{Title: "Test", Body: "Hello"})
+	if err == nil {
+		t.Fatalf("expected error for HTTP %d", status)
+	}
+	return err
+}
+
+func TestExitCode_APIErrors(t *testing.T) {
+	tests := []struct {
+		status int
+		want   int
+	}{
+		{http.StatusUnauthorized, exitAuth},
+		{http.StatusForbidden, exitAuth},
+		{http.StatusBadRequest, exitValidation},
+		{http.StatusUnprocessableEntity, exitValidation},
+		{http.StatusTooManyRequests, exitRateLimited},
+		{http.StatusBadGateway, exitServer},
+		{http.StatusNotFound, exitError},
+	}
+	for _, tt := range tests {
+		if got := exitCode(apiErrorFor(t, tt.status)); got != tt.want {
+			t.Errorf("exitCode(HTTP %d) = %d, want %d", tt.status, got, tt.want)
+		}
+	}
+}
+
+func TestExitCode_NetworkError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	serverURL := server.URL
+	server.Close()
+
+	client := api.NewClient("test-key", api.WithBaseURL(serverURL))
+	_, err := client.Notify(api.NotifyRequest{Title: "Test", Body: "Hello"})
+	if got := exitCode(err); got != exitNetwork {
+		t.Errorf("exitCode(network error) = %d, want %d", got, exitNetwork)
+	}
+}
+
+func TestExitCode_Other(t *testing.T) {
+	if got := exitCode(fmt.Errorf("sending request: %w", context.Canceled)); got != exitInterrupted {
+		t.Errorf("exitCode(canceled) = %d, want %d", got, exitInterrupted)
+	}
+	if got := exitCode(errors.New("boom")); got != exitError {
+		t.Errorf("exitCode(generic) = %d, want %d", got, exitError)
+	}
+}
@@ -0,0 +1,247 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/techulus/push-cli/internal/api"
+	"github.com/techulus/push-cli/internal/config"
+	"github.com/techulus/push-cli/internal/heartbeat"
+)
+
+func openHeartbeats() (*heartbeat.Store, error) {
+	dir, err := config.Dir()
+	if err != nil {
+		return nil, err
+	}
+	return heartbeat.Open(filepath.Join(dir, "heartbeats.json")), nil
+}
+
+var validStateName = regexp.MustCompile(`^[a-zA-Z0-9_-]+$`)
+
+// validateStateName checks the name of something kept in a state file, such
+// as a heartbeat or a digest. kind names it in the error.
+func validateStateName(kind, name string) error {
+	if !validStateName.MatchString(name) {
+		return fmt.Errorf("invalid %s %q: use letters, digits, '-' and '_'", kind, name)
+	}
+	return nil
+}
+
+func heartbeatNotification(c heartbeat.Change, now time.Time) api.NotifyRequest {
+	if c.Recovered {
+		return api.NotifyRequest{
+			Title: "Heartbeat recovered: " + c.Name,
+			Body:  fmt.Sprintf("Pinged %s ago after being down for %s", formatDuration(now.Sub(c.LastPing)), formatDuration(c.LastPing.Sub(c.DownAt))),
+		}
+	}
+	body := fmt.Sprintf("No ping for %s (expected every %s", formatDuration(now.Sub(c.LastPing)), formatDuration(c.Period))
+	if c.Grace > 0 {
+		body += fmt.Sprintf(" plus %s grace", formatDuration(c.Grace))
+	}
+	return api.NotifyRequest{Title: "Heartbeat missed: " + c.Name, Body: body + ")"}
+}
+
+// checkHeartbeats reports every heartbeat that became late or recovered.
+// A change is only marked as reported once its notification is sent.
+func checkHeartbeats(cmd *cobra.Command, store *heartbeat.Store, client *api.Client) error {
+	downSound, _ := cmd.Flags().GetString("down-sound")
+	upSound, _ := cmd.Flags().GetString("up-sound")
+
+	now := time.Now()
+	changes, err := store.Changes(now)
+	if err != nil {
+		return err
+	}
+
+	var sendErr error
+	for _, c := range changes {
+		req := heartbeatNotification(c, now)
+		req.Sound = downSound
+		if c.Recovered {
+			req.Sound = upSound
+		}
+		resp, err := client.NotifyContext(cmd.Context(), req)
+		if err != nil {
+			printError(exitCode(err), err)
+			sendErr = err
+			continue
+		}
+		printResponse(resp)
+		if err := store.Mark(c, now); err != nil {
+			return err
+		}
+	}
+	return sendErr
+}
+
+var heartbeatCmd = &cobra.Command{
+	Use:   "heartbeat",
+	Short: "Get notified when scheduled jobs stop running",
+	Long: `Get notified when scheduled jobs stop running.
+
+Jobs call "push heartbeat ping <name>" when they finish, which only records
+the time locally. "push heartbeat check" sends a notification for every
+heartbeat that hasn't pinged within its period plus grace, and another once
+it pings again. Run the check from cron, or keep it running with --watch.
+
+  # crontab
+  0 3 * * *   /usr/local/bin/backup && push heartbeat ping backup --period 24h --grace 1h
+  */5 * * * * push heartbeat check`,
+}
+
+var heartbeatPingCmd = &cobra.Command{
+	Use:   "ping <name>",
+	Short: "Record that a job has run",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		period, _ := cmd.Flags().GetDuration("period")
+		grace, _ := cmd.Flags().GetDuration("grace")
+		if err := validateStateName("heartbeat name", args[0]); err != nil {
+			fail(exitValidation, err)
+		}
+
+		store, err := openHeartbeats()
+		if err != nil {
+			fail(exitError, err)
+		}
+		h, err := store.Ping(args[0], period, grace, time.Now())
+		if errors.Is(err, heartbeat.ErrUnknown) {
+			fail(exitValidation, err)
+		}
+		if err != nil {
+			fail(exitError, err)
+		}
+
+		switch outputFormat {
+		case outputJSON:
+			printJSON(h)
+		case outputText:
+			fmt.Printf("Heartbeat %s recorded, next expected by %s\n", h.Name, h.Deadline().Local().Format("2006-01-02 15:04:05"))
+		}
+	},
+}
+
+var heartbeatCheckCmd = &cobra.Command{
+	Use:   "check",
+	Short: "Notify about heartbeats that are late or have recovered",
+	Run: func(cmd *cobra.Command, args []string) {
+		watch, _ := cmd.Flags().GetBool("watch")
+		interval, _ := cmd.Flags().GetDuration("interval")
+		if watch && interval <= 0 {
+			fail(exitValidation, errors.New("--interval must be positive"))
+		}
+		for _, name := range []string{"down-sound", "up-sound"} {
+			sound, _ := cmd.Flags().GetString(name)
+			if err := validateSound(sound); err != nil {
+				fail(exitValidation, err)
+			}
+		}
+
+		store, err := openHeartbeats()
+		if err != nil {
+			fail(exitError, err)
+		}
+		client := newAPIClient(cmd)
+
+		if !watch {
+			if err := checkHeartbeats(cmd, store, client); err != nil {
+				exitWithError(err)
+			}
+			return
+		}
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			// Errors were already printed; try again on the next tick.
+			checkHeartbeats(cmd, store, client)
+			select {
+			case <-cmd.Context().Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	},
+}
+
+var heartbeatListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List heartbeats and when they last pinged",
+	Run: func(cmd *cobra.Command, args []string) {
+		store, err := openHeartbeats()
+		if err != nil {
+			fail(exitError, err)
+		}
+		list, err := store.List()
+		if err != nil {
+			fail(exitError, err)
+		}
+
+		switch outputFormat {
+		case outputJSON:
+			printJSON(list)
+		case outputText:
+			if len(list) == 0 {
+				fmt.Println("No heartbeats")
+				return
+			}
+			now := time.Now()
+			w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(w, "NAME\tSTATE\tLAST PING\tPERIOD\tGRACE")
+			for _, h := range list {
+				state := "ok"
+				if h.Late(now) {
+					state = "late"
+				}
+				fmt.Fprintf(w, "%s\t%s\t%s ago\t%s\t%s\n", h.Name, state, formatDuration(now.Sub(h.LastPing)), h.Period, h.Grace)
+			}
+			w.Flush()
+		}
+	},
+}
+
+var heartbeatRemoveCmd = &cobra.Command{
+	Use:   "remove <name>",
+	Short: "Stop tracking a heartbeat",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		store, err := openHeartbeats()
+		if err != nil {
+			fail(exitError, err)
+		}
+		err = store.Remove(args[0])
+		if errors.Is(err, heartbeat.ErrUnknown) {
+			fail(exitValidation, err)
+		}
+		if err != nil {
+			fail(exitError, err)
+		}
+		if outputFormat == outputText {
+			fmt.Printf("Heartbeat %s removed\n", args[0])
+		}
+	},
+}
+
+func init() {
+	heartbeatPingCmd.Flags().Duration("period", 0, "How often the job runs (required on the first ping)")
+	heartbeatPingCmd.Flags().Duration("grace", 0, "Extra time allowed before the heartbeat counts as late")
+
+	addClientFlags(heartbeatCheckCmd)
+	heartbeatCheckCmd.Flags().Bool("watch", false, "Keep checking instead of exiting")
+	heartbeatCheckCmd.Flags().Duration("interval", time.Minute, "Time between checks with --watch")
+	heartbeatCheckCmd.Flags().String("down-sound", "fail", "Sound when a heartbeat is missed")
+	heartbeatCheckCmd.Flags().String("up-sound", "correct", "Sound when a heartbeat recovers")
+
+	heartbeatCmd.AddCommand(heartbeatPingCmd)
+	heartbeatCmd.AddCommand(heartbeatCheckCmd)
+	heartbeatCmd.AddCommand(heartbeatListCmd)
+	heartbeatCmd.AddCommand(heartbeatRemoveCmd)
+	rootCmd.AddCommand(heartbeatCmd)
+}
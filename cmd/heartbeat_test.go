@@ -0,0 +1,40 @@
+package cmd
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/techulus/push-cli/internal/heartbeat"
+)
+
+func TestHeartbeatNotification(t *testing.T) {
+	now := time.Date(2024, 5, 2, 6, 0, 0, 0, time.UTC)
+	h := heartbeat.Heartbeat{Name: "backup", Period: 24 * time.Hour, Grace: time.Hour, LastPing: now.Add(-27 * time.Hour)}
+
+	late := heartbeatNotification(heartbeat.Change{Heartbeat: h}, now)
+	if late.Title != "Heartbeat missed: backup" || late.Body != "No ping for 27h0m0s (expected every 24h0m0s plus 1h0m0s grace)" {
+		t.Errorf("unexpected late notification: %+v", late)
+	}
+
+	h.DownAt = now.Add(-2 * time.Hour)
+	h.LastPing = now.Add(-5 * time.Minute)
+	recovered := heartbeatNotification(heartbeat.Change{Heartbeat: h, Recovered: true}, now)
+	if recovered.Title != "Heartbeat recovered: backup" || recovered.Body != "Pinged 5m0s ago after being down for 1h55m0s" {
+		t.Errorf("unexpected recovery notification: %+v", recovered)
+	}
+}
+
+func TestValidateStateName(t *testing.T) {
+	for _, name := range []string{"backup", "db-dump_2"} {
+		if err := validateStateName("heartbeat name", name); err != nil {
+			t.Errorf("validateStateName(%q) error: %v", name, err)
+		}
+	}
+	for _, name := range []string{"", "a b", "../x"} {
+		err := validateStateName("heartbeat name", name)
+		if err == nil || !strings.HasPrefix(err.Error(), "invalid heartbeat name") {
+			t.Errorf("validateStateName(%q) = %v, want an invalid heartbeat name error", name, err)
+		}
+	}
+}
@@ -0,0 +1,208 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/techulus/push-cli/internal/api"
+	"github.com/techulus/push-cli/internal/monitor"
+)
+
+func monitorChecks(cmd *cobra.Command) ([]*monitor.Check, error) {
+	path, _ := cmd.Flags().GetString("config")
+	url, _ := cmd.Flags().GetString("url")
+	tcp, _ := cmd.Flags().GetString("tcp")
+	if path != "" {
+		if url != "" || tcp != "" {
+			return nil, fmt.Errorf("--config can't be combined with --url or --tcp")
+		}
+		return monitor.Load(path)
+	}
+
+	c := &monitor.Check{URL: url, TCP: tcp}
+	c.Name, _ = cmd.Flags().GetString("name")
+	c.Interval, _ = cmd.Flags().GetDuration("interval")
+	c.Timeout, _ = cmd.Flags().GetDuration("check-timeout")
+	c.ExpectStatus, _ = cmd.Flags().GetInt("expect-status")
+	c.ExpectBody, _ = cmd.Flags().GetString("expect-body")
+	c.Threshold, _ = cmd.Flags().GetInt("threshold")
+	if url == "" && tcp == "" {
+		return nil, fmt.Errorf("pass --url, --tcp or --config")
+	}
+	if err := c.Prepare(); err != nil {
+		return nil, err
+	}
+	return []*monitor.Check{c}, nil
+}
+
+func monitorNotification(e monitor.Event) api.NotifyRequest {
+	req := api.NotifyRequest{Link: e.Check.URL}
+	latency := formatDuration(e.Result.Latency)
+	switch e.Transition {
+	case monitor.WentDown:
+		req.Title = e.Check.Name + " is down"
+		req.Body = fmt.Sprintf("%v (after %s)\nFailed %d check(s) in a row", e.Result.Err, latency, e.Failures)
+	case monitor.WentUp:
+		req.Title = e.Check.Name + " is back up"
+		req.Body = fmt.Sprintf("Down for %s\nResponded in %s", formatDuration(e.Downtime), latency)
+		if e.Result.Status != 0 {
+			req.Body += fmt.Sprintf(" with status %d", e.Result.Status)
+		}
+	}
+	return req
+}
+
+type monitorResult struct {
+	Name      string `json:"name"`
+	Target    string `json:"target"`
+	Up        bool   `json:"up"`
+	Status    int    `json:"status,omitempty"`
+	LatencyMS int64  `json:"latency_ms"`
+	Error     string `json:"error,omitempty"`
+}
+
+// runChecksOnce runs every check once and prints the results, exiting
+// non-zero if any target is down.
+func runChecksOnce(cmd *cobra.Command, checks []*monitor.Check, client *http.Client) {
+	results := make([]monitorResult, len(checks))
+	allUp := true
+	for i, c := range checks {
+		r := c.Run(cmd.Context(), client)
+		results[i] = monitorResult{Name: c.Name, Target: c.URL + c.TCP, Up: r.Up(), Status: r.Status, LatencyMS: r.Latency.Milliseconds()}
+		if r.Err != nil {
+			results[i].Error = r.Err.Error()
+			allUp = false
+		}
+	}
+
+	switch outputFormat {
+	case outputJSON:
+		printJSON(results)
+	case outputText:
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "NAME\tSTATE\tLATENCY\tERROR")
+		for _, r := range results {
+			state := "up"
+			if !r.Up {
+				state = "down"
+			}
+			fmt.Fprintf(w, "%s\t%s\t%dms\t%s\n", r.Name, state, r.LatencyMS, r.Error)
+		}
+		w.Flush()
+	}
+	if !allUp {
+		os.Exit(exitError)
+	}
+}
+
+var monitorCmd = &cobra.Command{
+	Use:   "monitor",
+	Short: "Check HTTP endpoints and TCP ports and notify when they go down or come back",
+	Long: `Check HTTP endpoints and TCP ports on an interval and notify when they go
+down or come back up. Nothing is sent while the state stays the same.
+
+A target is reported down after --threshold failed checks in a row. HTTP
+checks fail on connection errors, on a status other than --expect-status
+(any 2xx by default) and when the body doesn't match --expect-body.
+
+  push monitor --url https://example.com/health --interval 30s --expect-body ok
+  push monitor --tcp db.internal:5432
+  push monitor --config checks.yaml
+
+A config file lists many checks, with optional defaults:
+
+  defaults:
+    interval: 1m
+    threshold: 3
+  checks:
+    - name: api
+      url: https://api.example.com/health
+      expect_status: 200
+      expect_body: '"ok"'
+    - name: postgres
+      tcp: db.internal:5432
+      interval: 15s`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		once, _ := cmd.Flags().GetBool("once")
+		downSound, _ := cmd.Flags().GetString("down-sound")
+		upSound, _ := cmd.Flags().GetString("up-sound")
+		timeSensitive, _ := cmd.Flags().GetBool("time-sensitive")
+
+		checks, err := monitorChecks(cmd)
+		if err != nil {
+			fail(exitValidation, err)
+		}
+		for _, sound := range []string{downSound, upSound} {
+			if err := validateSound(sound); err != nil {
+				fail(exitValidation, err)
+			}
+		}
+
+		// Checks use their own timeouts; redirects are followed.
+		httpClient := &http.Client{}
+		if once {
+			runChecksOnce(cmd, checks, httpClient)
+			return
+		}
+
+		logger, err := newLogger(cmd)
+		if err != nil {
+			fail(exitValidation, err)
+		}
+		client := newAPIClient(cmd)
+
+		names := make([]string, len(checks))
+		for i, c := range checks {
+			names[i] = c.Name
+		}
+		logger.Info("monitoring", "checks", strings.Join(names, ", "))
+
+		onResult := func(c *monitor.Check, r monitor.Result) {
+			if r.Err != nil {
+				logger.Warn("check failed", "check", c.Name, "latency_ms", r.Latency.Milliseconds(), "error", r.Err)
+			}
+		}
+		onEvent := func(e monitor.Event) error {
+			req := monitorNotification(e)
+			if e.Transition == monitor.WentDown {
+				req.Sound, req.TimeSensitive = downSound, timeSensitive
+				logger.Warn("target down", "check", e.Check.Name, "failures", e.Failures)
+			} else {
+				req.Sound = upSound
+				logger.Info("target up", "check", e.Check.Name, "downtime", formatDuration(e.Downtime))
+			}
+			_, err := client.NotifyContext(context.WithoutCancel(cmd.Context()), req)
+			if err != nil {
+				logger.Error("notification failed, retrying after the next check", "check", e.Check.Name, "error", err)
+			}
+			return err
+		}
+		monitor.Run(cmd.Context(), checks, httpClient, onResult, onEvent)
+	},
+}
+
+func init() {
+	addClientFlags(monitorCmd)
+	monitorCmd.Flags().String("config", "", "YAML file listing checks")
+	monitorCmd.Flags().String("url", "", "HTTP or HTTPS URL to check")
+	monitorCmd.Flags().String("tcp", "", "host:port to check with a TCP connection")
+	monitorCmd.Flags().String("name", "", "Name used in notifications (default: the URL or address)")
+	monitorCmd.Flags().Duration("interval", 30*time.Second, "Time between checks")
+	monitorCmd.Flags().Duration("check-timeout", 10*time.Second, "Timeout for each check")
+	monitorCmd.Flags().Int("expect-status", 0, "Expected HTTP status (default: any 2xx)")
+	monitorCmd.Flags().String("expect-body", "", "Regular expression the HTTP response body must match")
+	monitorCmd.Flags().Int("threshold", 3, "Failed checks in a row before notifying")
+	monitorCmd.Flags().String("down-sound", "fail", "Sound when a target goes down")
+	monitorCmd.Flags().String("up-sound", "correct", "Sound when a target comes back up")
+	monitorCmd.Flags().Bool("time-sensitive", false, "Send down notifications as time-sensitive")
+	monitorCmd.Flags().Bool("once", false, "Run every check once, print the results and exit non-zero if any is down")
+	monitorCmd.Flags().String("log-format", "text", "Log format: text or json")
+	rootCmd.AddCommand(monitorCmd)
+}
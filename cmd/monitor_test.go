@@ -0,0 +1,33 @@
+package cmd
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/techulus/push-cli/internal/monitor"
+)
+
+func TestMonitorNotification(t *testing.T) {
+	check := &monitor.Check{Name: "api", URL: "https://api.example.com/health"}
+
+	down := monitorNotification(monitor.Event{
+		Check:      check,
+		Transition: monitor.WentDown,
+		Result:     monitor.Result{Latency: 1500 * time.Millisecond, Err: errors.New("status 503")},
+		Failures:   3,
+	})
+	if down.Title != "api is down" || down.Body != "status 503 (after 2s)\nFailed 3 check(s) in a row" || down.Link != check.URL {
+		t.Errorf("unexpected down notification: %+v", down)
+	}
+
+	up := monitorNotification(monitor.Event{
+		Check:      check,
+		Transition: monitor.WentUp,
+		Result:     monitor.Result{Latency: 120 * time.Millisecond, Status: 200},
+		Downtime:   5 * time.Minute,
+	})
+	if up.Title != "api is back up" || up.Body != "Down for 5m0s\nResponded in 120ms with status 200" {
+		t.Errorf("unexpected up notification: %+v", up)
+	}
+}
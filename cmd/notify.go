@@ -1,14 +1,18 @@
 package cmd
 
 import (
+	"errors"
 	"fmt"
 	"io"
 	"os"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/spf13/cobra"
 	"github.com/techulus/push-cli/internal/api"
 	"github.com/techulus/push-cli/internal/config"
+	"github.com/techulus/push-cli/internal/spool"
 )
 
 var validSounds = []string{
@@ -42,31 +46,91 @@ func readBodyFromStdinOrFlag(cmd *cobra.Command) (string, error) {
 	return body, nil
 }
 
+func validateSound(sound string) error {
+	if sound == "" {
+		return nil
+	}
+	for _, s := range validSounds {
+		if s == sound {
+			return nil
+		}
+	}
+	return fmt.Errorf("invalid sound %q, valid sounds: %s", sound, strings.Join(validSounds, ", "))
+}
+
+// buildNotifyRequest renders --title, --body and --template-file as Go
+// templates. A body piped through stdin is sent as-is.
 func buildNotifyRequest(cmd *cobra.Command) (api.NotifyRequest, error) {
-	title, _ := cmd.Flags().GetString("title")
-	body, err := readBodyFromStdinOrFlag(cmd)
+	data, err := templateDataFromFlags(cmd)
 	if err != nil {
 		return api.NotifyRequest{}, err
 	}
 
-	sound, _ := cmd.Flags().GetString("sound")
-	if sound != "" {
-		valid := false
-		for _, s := range validSounds {
-			if s == sound {
-				valid = true
-				break
-			}
+	body, err := readTemplatedBody(cmd, data)
+	if err != nil {
+		return api.NotifyRequest{}, err
+	}
+
+	req, err := notifyRequestFromFlags(cmd, body)
+	if err != nil {
+		return api.NotifyRequest{}, err
+	}
+	// The prefix is added after rendering, so it is never run as a template.
+	title, err := renderTemplate("title", req.Title, data)
+	req.Title = withTitlePrefix(title)
+	return req, err
+}
+
+func readTemplatedBody(cmd *cobra.Command, data templateData) (string, error) {
+	flagBody, _ := cmd.Flags().GetString("body")
+	templateFile, _ := cmd.Flags().GetString("template-file")
+
+	if templateFile != "" {
+		if flagBody != "" {
+			return "", fmt.Errorf("use either --body or --template-file, not both")
 		}
-		if !valid {
-			return api.NotifyRequest{}, fmt.Errorf("invalid sound %q, valid sounds: %s", sound, strings.Join(validSounds, ", "))
+		text, err := os.ReadFile(templateFile)
+		if err != nil {
+			return "", fmt.Errorf("reading template file: %w", err)
+		}
+		body, err := renderTemplate("body", string(text), data)
+		if err != nil {
+			return "", err
 		}
+		if strings.TrimSpace(body) == "" {
+			return "", fmt.Errorf("template file %s rendered an empty body", templateFile)
+		}
+		return strings.TrimSpace(body), nil
+	}
+
+	body, err := readBodyFromStdinOrFlag(cmd)
+	if err != nil || flagBody == "" || flagBody == "-" {
+		return body, err
 	}
+	return renderTemplate("body", body, data)
+}
 
-	channel, _ := cmd.Flags().GetString("channel")
-	link, _ := cmd.Flags().GetString("link")
-	image, _ := cmd.Flags().GetString("image")
-	timeSensitive, _ := cmd.Flags().GetBool("time-sensitive")
+// notifyRequestWithBody builds a request from the notify flags for commands
+// that produce the body themselves instead of reading --body or stdin. Flags
+// that aren't given fall back to the notify.* settings.
+func notifyRequestWithBody(cmd *cobra.Command, body string) (api.NotifyRequest, error) {
+	req, err := notifyRequestFromFlags(cmd, body)
+	req.Title = withTitlePrefix(req.Title)
+	return req, err
+}
+
+// notifyRequestFromFlags is notifyRequestWithBody without the title prefix.
+func notifyRequestFromFlags(cmd *cobra.Command, body string) (api.NotifyRequest, error) {
+	title, _ := cmd.Flags().GetString("title")
+	sound := notifyFlag(cmd, "sound")
+	if err := validateSound(sound); err != nil {
+		return api.NotifyRequest{}, err
+	}
+
+	channel := notifyFlag(cmd, "channel")
+	link := notifyFlag(cmd, "link")
+	image := notifyFlag(cmd, "image")
+	timeSensitive, _ := strconv.ParseBool(notifyFlag(cmd, "time-sensitive"))
 
 	return api.NotifyRequest{
 		Title:         title,
@@ -79,13 +143,61 @@ func buildNotifyRequest(cmd *cobra.Command) (api.NotifyRequest, error) {
 	}, nil
 }
 
-func newAPIClient() *api.Client {
-	key := config.GetAPIKey()
+var errNoAPIKey = fmt.Errorf("no API key configured. Run: push config set-key <api-key> (or set %s)", config.APIKeyEnv)
+
+func newAPIClient(cmd *cobra.Command) *api.Client {
+	client, code, err := apiClientFor(cmd, config.ActiveProfile(), "")
+	if errors.Is(err, errNoAPIKey) {
+		fmt.Fprintf(os.Stderr, "No API key configured. Run: push config set-key <api-key> (or set %s)\n", config.APIKeyEnv)
+		os.Exit(code)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(code)
+	}
+	return client
+}
+
+// apiClientFor builds a client with profile's API key that sends to baseURL,
+// or to the profile's base URL if baseURL is empty. On failure it also
+// returns the exit code to use.
+func apiClientFor(cmd *cobra.Command, profile, baseURL string) (*api.Client, int, error) {
+	key, _, err := config.ResolveAPIKeyFor(profile)
+	if err != nil {
+		return nil, exitAuth, err
+	}
 	if key == "" {
-		fmt.Fprintln(os.Stderr, "No API key configured. Run: push config set-key <api-key>")
-		os.Exit(1)
+		return nil, exitAuth, errNoAPIKey
 	}
-	return api.NewClient(key)
+
+	var opts []api.Option
+	src := config.SourceNone
+	if baseURL == "" {
+		baseURL, src = config.LookupBaseURLFor(profile)
+	}
+	if baseURL != "" {
+		if err := api.ValidateBaseURL(baseURL); err != nil {
+			switch src {
+			case config.SourceNone:
+			case config.SourceProfile:
+				err = fmt.Errorf("%w (from profile %q)", err, profile)
+			default:
+				err = fmt.Errorf("%w (from %s)", err, describeSource(src, "--base-url", config.BaseURLEnv))
+			}
+			return nil, exitError, err
+		}
+		opts = append(opts, api.WithBaseURL(baseURL))
+	}
+	if cmd.Flags().Lookup("retries") != nil {
+		retries, _ := cmd.Flags().GetInt("retries")
+		maxWait, _ := cmd.Flags().GetDuration("retry-max-wait")
+		timeout, _ := cmd.Flags().GetDuration("timeout")
+		opts = append(opts,
+			api.WithRetryPolicy(api.RetryPolicy{MaxRetries: retries, MaxWait: maxWait}),
+			api.WithTimeout(timeout),
+		)
+	}
+	return api.NewClient(key, opts...), 0, nil
 }
 
 func addNotifyFlags(cmd *cobra.Command) {
@@ -99,28 +211,48 @@ func addNotifyFlags(cmd *cobra.Command) {
 	cmd.MarkFlagRequired("title")
 }
 
+func addClientFlags(cmd *cobra.Command) {
+	cmd.Flags().Duration("timeout", 30*time.Second, "Timeout for each API request")
+	cmd.Flags().Int("retries", 2, "Times to retry after connection errors and 429, 502, 503 and 504 responses")
+	cmd.Flags().Duration("retry-max-wait", 30*time.Second, "Longest wait between retries")
+}
+
 var notifyCmd = &cobra.Command{
 	Use:   "notify",
 	Short: "Send a push notification",
+	PreRun: func(cmd *cobra.Command, args []string) {
+		// With --batch the title may come from each line instead.
+		if batch, _ := cmd.Flags().GetString("batch"); batch != "" {
+			cmd.Flags().SetAnnotation("title", cobra.BashCompOneRequiredFlag, []string{"false"})
+		}
+	},
 	Run: func(cmd *cobra.Command, args []string) {
-		req, err := buildNotifyRequest(cmd)
-		if err != nil {
-			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
-			os.Exit(1)
+		if batch, _ := cmd.Flags().GetString("batch"); batch != "" {
+			runBatch(cmd, batch)
+			return
 		}
 
-		client := newAPIClient()
-		resp, err := client.Notify(req)
+		req, err := buildNotifyRequest(cmd)
 		if err != nil {
-			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
-			os.Exit(1)
+			fail(exitValidation, err)
 		}
 
-		fmt.Println(resp)
+		client := newAPIClient(cmd)
+		deliver(cmd, client, spool.TargetNotify, "", req)
 	},
 }
 
 func init() {
 	addNotifyFlags(notifyCmd)
+	addClientFlags(notifyCmd)
+	addTemplateFlags(notifyCmd)
+	addThrottleFlags(notifyCmd)
+	addQuietHoursFlag(notifyCmd)
+	addScheduleFlags(notifyCmd)
+	addSpoolFlag(notifyCmd)
+	notifyCmd.Flags().String("batch", "", "Send every notification in a JSON Lines or CSV file ('-' for stdin)")
+	notifyCmd.Flags().String("batch-format", "auto", "Batch input format: auto, jsonl or csv")
+	notifyCmd.Flags().Int("concurrency", 4, "Number of batch notifications sent at once")
+	notifyCmd.Flags().String("failures-out", "", "Write failed batch notifications to this file as JSON Lines")
 	rootCmd.AddCommand(notifyCmd)
 }
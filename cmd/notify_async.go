@@ -1,10 +1,8 @@
 package cmd
 
 import (
-	"fmt"
-	"os"
-
 	"github.com/spf13/cobra"
+	"github.com/techulus/push-cli/internal/spool"
 )
 
 var notifyAsyncCmd = &cobra.Command{
@@ -13,22 +11,21 @@ var notifyAsyncCmd = &cobra.Command{
 	Run: func(cmd *cobra.Command, args []string) {
 		req, err := buildNotifyRequest(cmd)
 		if err != nil {
-			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
-			os.Exit(1)
-		}
-
-		client := newAPIClient()
-		resp, err := client.NotifyAsync(req)
-		if err != nil {
-			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
-			os.Exit(1)
+			fail(exitValidation, err)
 		}
 
-		fmt.Println(resp)
+		client := newAPIClient(cmd)
+		deliver(cmd, client, spool.TargetAsync, "", req)
 	},
 }
 
 func init() {
 	addNotifyFlags(notifyAsyncCmd)
+	addClientFlags(notifyAsyncCmd)
+	addTemplateFlags(notifyAsyncCmd)
+	addThrottleFlags(notifyAsyncCmd)
+	addQuietHoursFlag(notifyAsyncCmd)
+	addScheduleFlags(notifyAsyncCmd)
+	addSpoolFlag(notifyAsyncCmd)
 	rootCmd.AddCommand(notifyAsyncCmd)
 }
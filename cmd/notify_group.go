@@ -1,10 +1,8 @@
 package cmd
 
 import (
-	"fmt"
-	"os"
-
 	"github.com/spf13/cobra"
+	"github.com/techulus/push-cli/internal/spool"
 )
 
 var notifyGroupCmd = &cobra.Command{
@@ -16,22 +14,21 @@ var notifyGroupCmd = &cobra.Command{
 
 		req, err := buildNotifyRequest(cmd)
 		if err != nil {
-			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
-			os.Exit(1)
-		}
-
-		client := newAPIClient()
-		resp, err := client.NotifyGroup(groupID, req)
-		if err != nil {
-			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
-			os.Exit(1)
+			fail(exitValidation, err)
 		}
 
-		fmt.Println(resp)
+		client := newAPIClient(cmd)
+		deliver(cmd, client, spool.TargetGroup, groupID, req)
 	},
 }
 
 func init() {
 	addNotifyFlags(notifyGroupCmd)
+	addClientFlags(notifyGroupCmd)
+	addTemplateFlags(notifyGroupCmd)
+	addThrottleFlags(notifyGroupCmd)
+	addQuietHoursFlag(notifyGroupCmd)
+	addScheduleFlags(notifyGroupCmd)
+	addSpoolFlag(notifyGroupCmd)
 	rootCmd.AddCommand(notifyGroupCmd)
 }
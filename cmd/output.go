@@ -0,0 +1,70 @@
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/techulus/push-cli/internal/api"
+)
+
+const (
+	outputText  = "text"
+	outputJSON  = "json"
+	outputQuiet = "quiet"
+)
+
+var outputFormat = outputText
+
+func setOutputFormat(format string) error {
+	switch format {
+	case outputText, outputJSON, outputQuiet:
+		outputFormat = format
+		return nil
+	}
+	return fmt.Errorf("invalid output format %q, valid formats: %s, %s, %s", format, outputText, outputJSON, outputQuiet)
+}
+
+func printJSON(v interface{}) {
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetEscapeHTML(false)
+	enc.Encode(v)
+}
+
+func printResponse(resp *api.NotifyResponse) {
+	switch outputFormat {
+	case outputJSON:
+		printJSON(resp)
+	case outputText:
+		msg := resp.Message
+		if msg == "" {
+			msg = "Notification sent"
+		}
+		if resp.ID != "" {
+			msg += " (ID: " + resp.ID + ")"
+		}
+		fmt.Println(msg)
+	}
+}
+
+type errorOutput struct {
+	Success    bool   `json:"success"`
+	Error      string `json:"error"`
+	ExitCode   int    `json:"exitCode"`
+	StatusCode int    `json:"statusCode,omitempty"`
+}
+
+func printError(code int, err error) {
+	fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+	if outputFormat != outputJSON {
+		return
+	}
+
+	out := errorOutput{Error: err.Error(), ExitCode: code}
+	var apiErr *api.APIError
+	if errors.As(err, &apiErr) {
+		out.StatusCode = apiErr.StatusCode
+	}
+	printJSON(out)
+}
@@ -0,0 +1,360 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/techulus/push-cli/internal/api"
+	"github.com/techulus/push-cli/internal/config"
+	"github.com/techulus/push-cli/internal/quiethours"
+	"github.com/techulus/push-cli/internal/spool"
+)
+
+func openSpool() (*spool.Queue, error) {
+	dir, err := config.Dir()
+	if err != nil {
+		return nil, err
+	}
+	return spool.Open(filepath.Join(dir, "spool"))
+}
+
+func sendToTarget(ctx context.Context, client *api.Client, target, groupID string, req api.NotifyRequest) (*api.NotifyResponse, error) {
+	switch target {
+	case spool.TargetAsync:
+		return client.NotifyAsyncContext(ctx, req)
+	case spool.TargetGroup:
+		return client.NotifyGroupContext(ctx, groupID, req)
+	}
+	return client.NotifyContext(ctx, req)
+}
+
+// newSpoolEntry returns an entry for req that remembers the active profile
+// and base URL, so it is sent with them when the queue is flushed.
+func newSpoolEntry(target, groupID string, req api.NotifyRequest) spool.Entry {
+	baseURL, _ := config.LookupBaseURL()
+	return spool.Entry{Target: target, GroupID: groupID, Request: req, Profile: config.ActiveProfile(), BaseURL: baseURL}
+}
+
+// queueClients hands out a client for the profile and base URL recorded on
+// each queued entry, and that profile's quiet hours.
+type queueClients struct {
+	cmd        *cobra.Command
+	clients    map[[2]string]*api.Client
+	quietHours map[string]*quiethours.Policy
+}
+
+func newQueueClients(cmd *cobra.Command) *queueClients {
+	return &queueClients{cmd: cmd, clients: map[[2]string]*api.Client{}, quietHours: map[string]*quiethours.Policy{}}
+}
+
+func entryProfile(e spool.Entry) string {
+	if e.Profile == "" {
+		// Queued before entries recorded their profile.
+		return config.ActiveProfile()
+	}
+	return e.Profile
+}
+
+func (c *queueClients) get(e spool.Entry) (*api.Client, error) {
+	profile := entryProfile(e)
+	key := [2]string{profile, e.BaseURL}
+	if client, ok := c.clients[key]; ok {
+		return client, nil
+	}
+	client, _, err := apiClientFor(c.cmd, profile, e.BaseURL)
+	if err != nil {
+		return nil, fmt.Errorf("profile %q: %w", profile, err)
+	}
+	c.clients[key] = client
+	return client, nil
+}
+
+func (c *queueClients) policy(e spool.Entry) (*quiethours.Policy, error) {
+	profile := entryProfile(e)
+	if p, ok := c.quietHours[profile]; ok {
+		return p, nil
+	}
+	p, err := quietHoursPolicyFor(profile)
+	if err != nil {
+		return nil, fmt.Errorf("profile %q: %w", profile, err)
+	}
+	c.quietHours[profile] = p
+	return p, nil
+}
+
+func addSpoolFlag(cmd *cobra.Command) {
+	cmd.Flags().Bool("spool", false, "Queue the notification on disk if the network is down (replay with: push queue flush)")
+}
+
+// deliver sends req and prints the result, unless quiet hours hold or drop
+// it or it is suppressed as a repeat or by the rate limit. With --at or --in
+// it is queued for later instead. With --spool, a notification that fails
+// because of a network error is queued instead of lost.
+func deliver(cmd *cobra.Command, client *api.Client, target, groupID string, req api.NotifyRequest) {
+	at, err := sendAt(cmd, time.Now())
+	if err != nil {
+		fail(exitValidation, err)
+	}
+	if !at.IsZero() {
+		if wait, _ := cmd.Flags().GetBool("wait"); !wait {
+			scheduleNotification(target, groupID, req, at, skipsQuietHours(cmd))
+			return
+		}
+		if err := waitUntil(cmd.Context(), at); err != nil {
+			exitWithError(err)
+		}
+	}
+
+	if !applyQuietHours(cmd, target, groupID, &req) {
+		return
+	}
+	release, ok := throttleNotification(cmd, &req)
+	if !ok {
+		return
+	}
+
+	resp, err := sendToTarget(cmd.Context(), client, target, groupID, req)
+	if err == nil {
+		printResponse(resp)
+		return
+	}
+
+	useSpool, _ := cmd.Flags().GetBool("spool")
+	if !useSpool || !api.IsNetworkError(err) || cmd.Context().Err() != nil {
+		release()
+		exitWithError(err)
+	}
+
+	q, qErr := openSpool()
+	var entry spool.Entry
+	if qErr == nil {
+		e := newSpoolEntry(target, groupID, req)
+		e.Attempts, e.LastError = 1, err.Error()
+		e.IgnoreQuietHours = skipsQuietHours(cmd)
+		entry, qErr = q.Add(e)
+	}
+	if qErr != nil {
+		fmt.Fprintf(os.Stderr, "Error queueing notification: %v\n", qErr)
+		release()
+		exitWithError(err)
+	}
+
+	fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+	switch outputFormat {
+	case outputJSON:
+		printJSON(queuedOutput{Success: true, Queued: true, ID: entry.ID})
+	case outputText:
+		fmt.Printf("Notification queued as %s. Run: push queue flush\n", entry.ID)
+	}
+}
+
+// flushQueue sends every entry in q that isn't held, calling onError for
+// each one that fails. Entries the API rejects for a reason other than load
+// are marked failed and skipped until flushed with retryFailed. The quiet
+// hours of an entry's profile apply when it is sent, unless it was queued
+// with --ignore-quiet-hours.
+func flushQueue(ctx context.Context, q *spool.Queue, clients *queueClients, retryFailed bool, onError func(spool.Entry, error)) (int, []spool.Entry, error) {
+	return q.Flush(retryFailed, func(e *spool.Entry) (spool.Result, error) {
+		client, err := clients.get(*e)
+		var policy *quiethours.Policy
+		if err == nil && !e.IgnoreQuietHours {
+			policy, err = clients.policy(*e)
+		}
+		if err != nil {
+			onError(*e, err)
+			return spool.Retry, err
+		}
+
+		req := e.Request
+		action, until := quietHoursAt(policy, req.TimeSensitive, time.Now())
+		switch action {
+		case quiethours.Hold:
+			e.NotBefore = until
+			return spool.Postpone, nil
+		case quiethours.Drop:
+			return spool.Drop, nil
+		case quiethours.Downgrade:
+			downgradeRequest(&req)
+		}
+
+		_, err = sendToTarget(ctx, client, e.Target, e.GroupID, req)
+		switch {
+		case err == nil:
+			return spool.Sent, nil
+		case api.IsNetworkError(err) || ctx.Err() != nil:
+			// Still offline (or interrupted): leave the rest for next time.
+			onError(*e, err)
+			return spool.Stop, err
+		case api.IsRateLimited(err) || api.IsServerError(err):
+			onError(*e, err)
+			return spool.Retry, err
+		}
+		onError(*e, err)
+		return spool.Reject, err
+	})
+}
+
+// runQueueFlusher flushes q every interval until ctx is done, so held and
+// scheduled notifications go out once they are due.
+func runQueueFlusher(ctx context.Context, q *spool.Queue, clients *queueClients, interval time.Duration, logger *slog.Logger) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		sent, _, err := flushQueue(ctx, q, clients, false, func(e spool.Entry, err error) {
+			logger.Error("sending queued notification failed", "id", e.ID, "error", err)
+		})
+		if err != nil {
+			logger.Error("flushing queue failed", "error", err)
+		} else if sent > 0 {
+			logger.Info("queued notifications sent", "count", sent)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+type queuedOutput struct {
+	Success bool   `json:"success"`
+	Queued  bool   `json:"queued"`
+	ID      string `json:"id"`
+}
+
+type flushOutput struct {
+	Sent    int           `json:"sent"`
+	Pending []spool.Entry `json:"pending"`
+}
+
+var queueCmd = &cobra.Command{
+	Use:   "queue",
+	Short: "Inspect and replay notifications queued with --spool",
+}
+
+var queueListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List queued notifications",
+	Run: func(cmd *cobra.Command, args []string) {
+		q, err := openSpool()
+		if err != nil {
+			fail(exitError, err)
+		}
+		entries, err := q.List()
+		if err != nil {
+			fail(exitError, err)
+		}
+
+		switch outputFormat {
+		case outputJSON:
+			if entries == nil {
+				entries = []spool.Entry{}
+			}
+			printJSON(entries)
+		case outputText:
+			if len(entries) == 0 {
+				fmt.Println("No queued notifications")
+				return
+			}
+			w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(w, "ID\tQUEUED\tHELD UNTIL\tTARGET\tTITLE\tATTEMPTS\tLAST ERROR")
+			now := time.Now()
+			for _, e := range entries {
+				target := e.Target
+				if e.GroupID != "" {
+					target += " " + e.GroupID
+				}
+				held := "-"
+				if e.Held(now) {
+					held = e.NotBefore.Local().Format("2006-01-02 15:04:05")
+				}
+				lastError := e.LastError
+				if e.Failed {
+					lastError = "rejected: " + lastError
+				}
+				fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%d\t%s\n", e.ID, e.CreatedAt.Local().Format("2006-01-02 15:04:05"), held, target, e.Request.Title, e.Attempts, lastError)
+			}
+			w.Flush()
+		}
+	},
+}
+
+var queueFlushCmd = &cobra.Command{
+	Use:   "flush",
+	Short: "Send all queued notifications",
+	Run: func(cmd *cobra.Command, args []string) {
+		q, err := openSpool()
+		if err != nil {
+			fail(exitError, err)
+		}
+
+		retryFailed, _ := cmd.Flags().GetBool("retry-failed")
+		var lastErr error
+		sent, pending, err := flushQueue(cmd.Context(), q, newQueueClients(cmd), retryFailed, func(e spool.Entry, err error) {
+			lastErr = err
+			fmt.Fprintf(os.Stderr, "%s: %v\n", e.ID, err)
+		})
+		if err != nil {
+			fail(exitError, err)
+		}
+
+		switch outputFormat {
+		case outputJSON:
+			if pending == nil {
+				pending = []spool.Entry{}
+			}
+			printJSON(flushOutput{Sent: sent, Pending: pending})
+		case outputText:
+			fmt.Printf("Sent %d queued notification(s), %d still queued\n", sent, len(pending))
+		}
+		if lastErr != nil {
+			os.Exit(exitCode(lastErr))
+		}
+	},
+}
+
+var queueDropCmd = &cobra.Command{
+	Use:   "drop [id...]",
+	Short: "Delete queued notifications without sending them",
+	Run: func(cmd *cobra.Command, args []string) {
+		all, _ := cmd.Flags().GetBool("all")
+		if all == (len(args) > 0) {
+			fail(exitValidation, fmt.Errorf("pass notification IDs or --all"))
+		}
+
+		q, err := openSpool()
+		if err != nil {
+			fail(exitError, err)
+		}
+
+		var dropped int
+		if all {
+			dropped, err = q.DropAll()
+		} else {
+			dropped, err = q.Drop(args...)
+		}
+		if err != nil {
+			fail(exitError, err)
+		}
+		if outputFormat == outputText {
+			fmt.Printf("Dropped %d queued notification(s)\n", dropped)
+		}
+	},
+}
+
+func init() {
+	addClientFlags(queueFlushCmd)
+	queueFlushCmd.Flags().Bool("retry-failed", false, "Also send notifications the API rejected before")
+	queueDropCmd.Flags().Bool("all", false, "Drop every queued notification")
+	queueCmd.AddCommand(queueListCmd)
+	queueCmd.AddCommand(queueFlushCmd)
+	queueCmd.AddCommand(queueDropCmd)
+	rootCmd.AddCommand(queueCmd)
+}
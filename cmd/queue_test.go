@@ -0,0 +1,137 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"github.com/techulus/push-cli/internal/api"
+	"github.com/techulus/push-cli/internal/spool"
+)
+
+func TestFlushQueue_UsesEntryProfile(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("PUSH_API_KEY", "")
+	t.Setenv("PUSH_BASE_URL", "")
+	viper.Reset()
+	t.Cleanup(viper.Reset)
+
+	keys := make(chan string, 2)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		keys <- r.Header.Get("x-api-key")
+		w.Write([]byte(`{"success":true}`))
+	}))
+	defer srv.Close()
+	viper.Set("profiles.default.api_key", "default-key")
+	viper.Set("profiles.team.api_key", "team-key")
+	viper.Set("profiles.team.base_url", "http://127.0.0.1:1")
+
+	q, err := spool.Open(filepath.Join(t.TempDir(), "spool"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req := api.NotifyRequest{Title: "T", Body: "b"}
+	if _, err := q.Add(spool.Entry{Target: spool.TargetNotify, Request: req, Profile: "team", BaseURL: srv.URL}); err != nil {
+		t.Fatal(err)
+	}
+
+	sent, _, err := flushQueue(context.Background(), q, newQueueClients(&cobra.Command{}), false, func(e spool.Entry, err error) {
+		t.Errorf("sending %s: %v", e.ID, err)
+	})
+	if err != nil || sent != 1 {
+		t.Fatalf("flushQueue() = %d, %v", sent, err)
+	}
+	if key := <-keys; key != "team-key" {
+		t.Errorf("sent with key %q, want the team profile's key", key)
+	}
+}
+
+func TestFlushQueue_ParksRejected(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("PUSH_API_KEY", "")
+	viper.Reset()
+	t.Cleanup(viper.Reset)
+
+	status := http.StatusUnauthorized
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+		w.Write([]byte(`{"success":false,"message":"nope"}`))
+	}))
+	defer srv.Close()
+	viper.Set("api_key", "key")
+	t.Setenv("PUSH_BASE_URL", srv.URL)
+
+	q, err := spool.Open(filepath.Join(t.TempDir(), "spool"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, body := range []string{"a", "b"} {
+		if _, err := q.Add(spool.Entry{Target: spool.TargetNotify, Request: api.NotifyRequest{Title: "T", Body: body}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	failures := 0
+	_, pending, err := flushQueue(context.Background(), q, newQueueClients(&cobra.Command{}), false, func(spool.Entry, error) { failures++ })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if failures != 2 || len(pending) != 2 || !pending[0].Failed || !pending[1].Failed {
+		t.Fatalf("expected both entries to be marked failed, got %d failures, %+v", failures, pending)
+	}
+
+	// Server errors are retried and don't mark the entry failed.
+	status = http.StatusServiceUnavailable
+	_, pending, _ = flushQueue(context.Background(), q, newQueueClients(&cobra.Command{}), true, func(spool.Entry, error) {})
+	if len(pending) != 2 || pending[0].Failed || pending[0].Attempts != 2 {
+		t.Errorf("expected retried entries to stay queued, got %+v", pending)
+	}
+}
+
+func TestFlushQueue_AppliesQuietHours(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("PUSH_API_KEY", "")
+	viper.Reset()
+	t.Cleanup(viper.Reset)
+
+	bodies := make(chan api.NotifyRequest, 4)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req api.NotifyRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		bodies <- req
+		w.Write([]byte(`{"success":true}`))
+	}))
+	defer srv.Close()
+	viper.Set("api_key", "key")
+	t.Setenv("PUSH_BASE_URL", srv.URL)
+	viper.Set("quiet_hours", map[string]interface{}{
+		"action":  "hold",
+		"windows": []map[string]interface{}{{"start": "00:00", "end": "24:00"}},
+	})
+
+	q, err := spool.Open(filepath.Join(t.TempDir(), "spool"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	q.Add(spool.Entry{Target: spool.TargetNotify, Request: api.NotifyRequest{Title: "T", Body: "held"}})
+	q.Add(spool.Entry{Target: spool.TargetNotify, Request: api.NotifyRequest{Title: "T", Body: "ignored"}, IgnoreQuietHours: true})
+
+	sent, pending, err := flushQueue(context.Background(), q, newQueueClients(&cobra.Command{}), false, func(e spool.Entry, err error) {
+		t.Errorf("sending %s: %v", e.ID, err)
+	})
+	if err != nil || sent != 1 {
+		t.Fatalf("flushQueue() = %d, %v", sent, err)
+	}
+	if req := <-bodies; req.Body != "ignored" {
+		t.Errorf("sent %q, want only the entry that ignores quiet hours", req.Body)
+	}
+	if len(pending) != 1 || pending[0].Request.Body != "held" || !pending[0].Held(time.Now()) || pending[0].Attempts != 0 {
+		t.Errorf("expected the other entry to be held until quiet hours end, got %+v", pending)
+	}
+}
@@ -0,0 +1,127 @@
+package cmd
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/techulus/push-cli/internal/api"
+	"github.com/techulus/push-cli/internal/config"
+	"github.com/techulus/push-cli/internal/quiethours"
+	"github.com/techulus/push-cli/internal/spool"
+)
+
+func addQuietHoursFlag(cmd *cobra.Command) {
+	cmd.Flags().Bool("ignore-quiet-hours", false, "Send right away even during quiet hours")
+}
+
+// quietHoursPolicy returns the active profile's quiet hours, or nil if it has
+// none.
+func quietHoursPolicy() (*quiethours.Policy, error) {
+	return quietHoursPolicyFor(config.ActiveProfile())
+}
+
+func quietHoursPolicyFor(profile string) (*quiethours.Policy, error) {
+	var p quiethours.Policy
+	ok, err := config.LookupSectionFor(profile, "quiet_hours", &p)
+	if err != nil || !ok {
+		return nil, err
+	}
+	if err := p.Prepare(); err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+type quietHoursOutput struct {
+	Success    bool      `json:"success"`
+	QuietHours string    `json:"quietHours"`
+	Until      time.Time `json:"until"`
+	ID         string    `json:"id,omitempty"`
+}
+
+// skipsQuietHours reports whether cmd sends regardless of quiet hours,
+// because it has no --ignore-quiet-hours flag or runs with it. Entries it
+// queues are then sent regardless too.
+func skipsQuietHours(cmd *cobra.Command) bool {
+	if cmd.Flags().Lookup("ignore-quiet-hours") == nil {
+		return true
+	}
+	ignore, _ := cmd.Flags().GetBool("ignore-quiet-hours")
+	return ignore
+}
+
+// activeQuietHours returns the quiet hours that apply to cmd, or nil if it
+// has none or skips them.
+func activeQuietHours(cmd *cobra.Command) *quiethours.Policy {
+	if skipsQuietHours(cmd) {
+		return nil
+	}
+	p, err := quietHoursPolicy()
+	if err != nil {
+		fail(exitValidation, err)
+	}
+	return p
+}
+
+// quietHoursAt returns what p does to a notification at now and when quiet
+// hours end, or an empty action if it can be sent as is.
+func quietHoursAt(p *quiethours.Policy, timeSensitive bool, now time.Time) (quiethours.Action, time.Time) {
+	if p == nil || !p.Applies(timeSensitive) {
+		return "", time.Time{}
+	}
+	until, quiet := p.Until(now)
+	if !quiet {
+		return "", time.Time{}
+	}
+	return p.Action, until
+}
+
+func downgradeRequest(req *api.NotifyRequest) {
+	req.Sound, req.TimeSensitive = "", false
+}
+
+// holdNotification queues req until quiet hours end.
+func holdNotification(target, groupID string, req api.NotifyRequest, until time.Time) (spool.Entry, error) {
+	q, err := openSpool()
+	if err != nil {
+		return spool.Entry{}, err
+	}
+	e := newSpoolEntry(target, groupID, req)
+	e.NotBefore, e.Kind = until, spool.KindHeld
+	return q.Add(e)
+}
+
+// applyQuietHours holds, downgrades or drops req during quiet hours. It
+// returns false if req must not be sent now, after printing why.
+func applyQuietHours(cmd *cobra.Command, target, groupID string, req *api.NotifyRequest) bool {
+	action, until := quietHoursAt(activeQuietHours(cmd), req.TimeSensitive, time.Now())
+	if action == "" {
+		return true
+	}
+
+	out := quietHoursOutput{Success: true, QuietHours: string(action), Until: until}
+	var message string
+	switch action {
+	case quiethours.Downgrade:
+		downgradeRequest(req)
+		return true
+	case quiethours.Drop:
+		message = fmt.Sprintf("Notification dropped: quiet hours until %s", until.Local().Format("2006-01-02 15:04"))
+	case quiethours.Hold:
+		entry, err := holdNotification(target, groupID, *req, until)
+		if err != nil {
+			fail(exitError, fmt.Errorf("holding notification for quiet hours: %w", err))
+		}
+		out.ID = entry.ID
+		message = fmt.Sprintf("Notification held as %s until quiet hours end at %s. Run: push queue flush", entry.ID, until.Local().Format("2006-01-02 15:04"))
+	}
+
+	switch outputFormat {
+	case outputJSON:
+		printJSON(out)
+	case outputText:
+		fmt.Println(message)
+	}
+	return false
+}
@@ -1,10 +1,14 @@
 package cmd
 
 import (
+	"context"
 	"fmt"
 	"os"
+	"os/signal"
+	"syscall"
 
 	"github.com/spf13/cobra"
+	"github.com/techulus/push-cli/internal/api"
 	"github.com/techulus/push-cli/internal/config"
 )
 
@@ -18,12 +22,67 @@ var rootCmd = &cobra.Command{
 	Use:     "push",
 	Short:   "Push by Techulus - Send push notifications from the command line",
 	Version: Version,
+	// Execute prints the error itself.
+	SilenceErrors: true,
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		if err := applyGlobalFlags(cmd); err != nil {
+			// Bad global flags are configuration errors, not usage errors.
+			cmd.SilenceUsage = true
+			return err
+		}
+		// The doctor reports these itself.
+		if cmd != configDoctorCmd {
+			for _, w := range config.Warnings() {
+				fmt.Fprintf(os.Stderr, "Warning: %s: %s\n", config.File(), w)
+			}
+		}
+		return nil
+	},
+}
+
+func applyGlobalFlags(cmd *cobra.Command) error {
+	format, _ := cmd.Flags().GetString("output")
+	if quiet, _ := cmd.Flags().GetBool("quiet"); quiet {
+		format = outputQuiet
+	}
+	if err := setOutputFormat(format); err != nil {
+		return err
+	}
+
+	if profile, _ := cmd.Flags().GetString("profile"); profile != "" {
+		if err := config.ValidateProfileName(profile); err != nil {
+			return err
+		}
+		config.SetProfile(profile)
+	}
+	if apiKey, _ := cmd.Flags().GetString("api-key"); apiKey != "" {
+		config.SetOverride("api_key", apiKey)
+	}
+	if baseURL, _ := cmd.Flags().GetString("base-url"); baseURL != "" {
+		if err := api.ValidateBaseURL(baseURL); err != nil {
+			return err
+		}
+		config.SetOverride("base_url", baseURL)
+	}
+	return nil
+}
+
+func init() {
+	rootCmd.PersistentFlags().StringP("output", "o", outputText, "Output format: text, json or quiet")
+	rootCmd.PersistentFlags().BoolP("quiet", "q", false, "Print nothing on success (same as --output quiet)")
+	rootCmd.PersistentFlags().String("profile", "", "Configuration profile to use (overrides the active profile)")
+	rootCmd.PersistentFlags().String("api-key", "", "API key to use (overrides "+config.APIKeyEnv+" and the config file)")
+	rootCmd.PersistentFlags().String("base-url", "", "Push API base URL (overrides "+config.BaseURLEnv+" and the config file)")
 }
 
 func Execute() {
 	config.Init()
-	if err := rootCmd.Execute(); err != nil {
-		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
-		os.Exit(1)
+
+	// Cancel in-flight requests on Ctrl-C or when the process is asked to stop.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if err := rootCmd.ExecuteContext(ctx); err != nil {
+		fail(exitError, err)
 	}
 }
@@ -0,0 +1,254 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/techulus/push-cli/internal/api"
+	"github.com/techulus/push-cli/internal/spool"
+)
+
+var atLayouts = []string{
+	"2006-01-02T15:04:05",
+	"2006-01-02T15:04",
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+}
+
+func addScheduleFlags(cmd *cobra.Command) {
+	cmd.Flags().String("at", "", "Send at this time, e.g. 2026-11-01T09:00, 09:00 or an RFC 3339 timestamp")
+	cmd.Flags().Duration("in", 0, "Send after this long, e.g. 25m")
+	cmd.Flags().Bool("wait", false, "With --at or --in, wait in the foreground instead of scheduling")
+}
+
+// parseAt parses --at. Times without a date are the next occurrence of that
+// time of day, and times without an offset are local.
+func parseAt(s string, now time.Time) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	for _, layout := range atLayouts {
+		if t, err := time.ParseInLocation(layout, s, now.Location()); err == nil {
+			return t, nil
+		}
+	}
+	for _, layout := range []string{"15:04", "15:04:05"} {
+		clock, err := time.Parse(layout, s)
+		if err != nil {
+			continue
+		}
+		t := time.Date(now.Year(), now.Month(), now.Day(), clock.Hour(), clock.Minute(), clock.Second(), 0, now.Location())
+		if !t.After(now) {
+			t = time.Date(now.Year(), now.Month(), now.Day()+1, clock.Hour(), clock.Minute(), clock.Second(), 0, now.Location())
+		}
+		return t, nil
+	}
+	return time.Time{}, fmt.Errorf("invalid --at %q, use a form like 2026-11-01T09:00, 09:00 or 2026-11-01T09:00:00+01:00", s)
+}
+
+// sendAt returns when the notification should be sent, or the zero time to
+// send it now.
+func sendAt(cmd *cobra.Command, now time.Time) (time.Time, error) {
+	if cmd.Flags().Lookup("at") == nil {
+		return time.Time{}, nil
+	}
+	at, _ := cmd.Flags().GetString("at")
+	in, _ := cmd.Flags().GetDuration("in")
+	switch {
+	case at != "" && in != 0:
+		return time.Time{}, errors.New("use either --at or --in, not both")
+	case in < 0:
+		return time.Time{}, errors.New("--in can't be negative")
+	case in > 0:
+		return now.Add(in), nil
+	case at == "":
+		return time.Time{}, nil
+	}
+
+	t, err := parseAt(at, now)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if !t.After(now) {
+		return time.Time{}, fmt.Errorf("--at %s is in the past", at)
+	}
+	return t, nil
+}
+
+// waitUntil blocks until t or until ctx is done.
+func waitUntil(ctx context.Context, t time.Time) error {
+	timer := time.NewTimer(time.Until(t))
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+type scheduledOutput struct {
+	Success   bool      `json:"success"`
+	Scheduled bool      `json:"scheduled"`
+	ID        string    `json:"id"`
+	At        time.Time `json:"at"`
+}
+
+func scheduleNotification(target, groupID string, req api.NotifyRequest, at time.Time, ignoreQuietHours bool) {
+	q, err := openSpool()
+	var entry spool.Entry
+	if err == nil {
+		e := newSpoolEntry(target, groupID, req)
+		e.NotBefore, e.Kind, e.IgnoreQuietHours = at, spool.KindScheduled, ignoreQuietHours
+		entry, err = q.Add(e)
+	}
+	if err != nil {
+		fail(exitError, fmt.Errorf("scheduling notification: %w", err))
+	}
+
+	switch outputFormat {
+	case outputJSON:
+		printJSON(scheduledOutput{Success: true, Scheduled: true, ID: entry.ID, At: at})
+	case outputText:
+		fmt.Printf("Notification %s scheduled for %s\n", entry.ID, at.Local().Format("2006-01-02 15:04:05"))
+	}
+}
+
+func scheduledEntries(q *spool.Queue) ([]spool.Entry, error) {
+	entries, err := q.List()
+	if err != nil {
+		return nil, err
+	}
+	scheduled := []spool.Entry{}
+	for _, e := range entries {
+		if e.Kind == spool.KindScheduled {
+			scheduled = append(scheduled, e)
+		}
+	}
+	return scheduled, nil
+}
+
+var scheduleCmd = &cobra.Command{
+	Use:   "schedule",
+	Short: "Manage notifications scheduled with --at or --in",
+	Long: `Manage notifications scheduled with --at or --in.
+
+"push notify --in 25m" and "push notify --at 2026-11-01T09:00" store the
+notification in the offline queue and exit. It is sent by whatever flushes
+the queue once it is due: "push schedule run" in the foreground, "push daemon"
+in the background, or "push queue flush" from cron. Add --wait to notify to
+wait in the foreground instead.`,
+}
+
+var scheduleListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List scheduled notifications",
+	Run: func(cmd *cobra.Command, args []string) {
+		q, err := openSpool()
+		if err != nil {
+			fail(exitError, err)
+		}
+		entries, err := scheduledEntries(q)
+		if err != nil {
+			fail(exitError, err)
+		}
+
+		switch outputFormat {
+		case outputJSON:
+			printJSON(entries)
+		case outputText:
+			if len(entries) == 0 {
+				fmt.Println("No scheduled notifications")
+				return
+			}
+			now := time.Now()
+			w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(w, "ID\tSEND AT\tSTATE\tTARGET\tTITLE")
+			for _, e := range entries {
+				target := e.Target
+				if e.GroupID != "" {
+					target += " " + e.GroupID
+				}
+				state := "pending"
+				if e.LastError != "" {
+					state = "failed: " + e.LastError
+				} else if !e.Held(now) {
+					state = "due"
+				}
+				fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", e.ID, e.NotBefore.Local().Format("2006-01-02 15:04:05"), state, target, e.Request.Title)
+			}
+			w.Flush()
+		}
+	},
+}
+
+var scheduleCancelCmd = &cobra.Command{
+	Use:   "cancel <id> [id...]",
+	Short: "Cancel scheduled notifications",
+	Args:  cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		q, err := openSpool()
+		if err != nil {
+			fail(exitError, err)
+		}
+		entries, err := scheduledEntries(q)
+		if err != nil {
+			fail(exitError, err)
+		}
+		scheduled := map[string]bool{}
+		for _, e := range entries {
+			scheduled[e.ID] = true
+		}
+		for _, id := range args {
+			if !scheduled[id] {
+				fail(exitValidation, fmt.Errorf("no scheduled notification with ID %q", id))
+			}
+		}
+
+		if _, err := q.Drop(args...); err != nil {
+			fail(exitError, err)
+		}
+		if outputFormat == outputText {
+			fmt.Printf("Cancelled %s\n", strings.Join(args, ", "))
+		}
+	},
+}
+
+var scheduleRunCmd = &cobra.Command{
+	Use:   "run",
+	Short: "Send scheduled and queued notifications as they become due, until interrupted",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		interval, _ := cmd.Flags().GetDuration("interval")
+		if interval <= 0 {
+			fail(exitValidation, errors.New("--interval must be positive"))
+		}
+		logger, err := newLogger(cmd)
+		if err != nil {
+			fail(exitValidation, err)
+		}
+		q, err := openSpool()
+		if err != nil {
+			fail(exitError, err)
+		}
+		logger.Info("sending scheduled notifications", "interval", interval.String())
+		runQueueFlusher(cmd.Context(), q, newQueueClients(cmd), interval, logger)
+	},
+}
+
+func init() {
+	addClientFlags(scheduleRunCmd)
+	scheduleRunCmd.Flags().Duration("interval", 15*time.Second, "How often to check for due notifications")
+	scheduleRunCmd.Flags().String("log-format", "text", "Log format: text or json")
+
+	scheduleCmd.AddCommand(scheduleListCmd)
+	scheduleCmd.AddCommand(scheduleCancelCmd)
+	scheduleCmd.AddCommand(scheduleRunCmd)
+	rootCmd.AddCommand(scheduleCmd)
+}
@@ -0,0 +1,88 @@
+package cmd
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/techulus/push-cli/internal/api"
+	"github.com/techulus/push-cli/internal/spool"
+)
+
+func TestParseAt(t *testing.T) {
+	loc := time.FixedZone("CET", 3600)
+	now := time.Date(2026, 10, 18, 12, 0, 0, 0, loc)
+
+	tests := []struct {
+		in   string
+		want time.Time
+	}{
+		{"2026-11-01T09:00", time.Date(2026, 11, 1, 9, 0, 0, 0, loc)},
+		{"2026-11-01 09:00:30", time.Date(2026, 11, 1, 9, 0, 30, 0, loc)},
+		{"2026-11-01T09:00:00Z", time.Date(2026, 11, 1, 9, 0, 0, 0, time.UTC)},
+		{"13:30", time.Date(2026, 10, 18, 13, 30, 0, 0, loc)},
+		{"09:00", time.Date(2026, 10, 19, 9, 0, 0, 0, loc)},
+		{"12:00", time.Date(2026, 10, 19, 12, 0, 0, 0, loc)},
+	}
+	for _, tt := range tests {
+		got, err := parseAt(tt.in, now)
+		if err != nil {
+			t.Errorf("parseAt(%q) error: %v", tt.in, err)
+			continue
+		}
+		if !got.Equal(tt.want) {
+			t.Errorf("parseAt(%q) = %v, want %v", tt.in, got, tt.want)
+		}
+	}
+
+	for _, in := range []string{"tomorrow", "25:00", "2026-13-01T09:00"} {
+		if _, err := parseAt(in, now); err == nil {
+			t.Errorf("parseAt(%q) expected an error", in)
+		}
+	}
+}
+
+func TestSendAt(t *testing.T) {
+	now := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+	newCmd := func(args ...string) *cobra.Command {
+		cmd := &cobra.Command{Use: "test", Run: func(*cobra.Command, []string) {}}
+		addScheduleFlags(cmd)
+		cmd.SetArgs(args)
+		cmd.Execute()
+		return cmd
+	}
+
+	if at, err := sendAt(newCmd(), now); err != nil || !at.IsZero() {
+		t.Errorf("expected no schedule without flags, got %v, %v", at, err)
+	}
+	if at, err := sendAt(newCmd("--in", "25m"), now); err != nil || !at.Equal(now.Add(25*time.Minute)) {
+		t.Errorf("sendAt(--in 25m) = %v, %v", at, err)
+	}
+	if _, err := sendAt(newCmd("--in", "25m", "--at", "13:00"), now); err == nil {
+		t.Error("expected an error for --at with --in")
+	}
+	if _, err := sendAt(newCmd("--at", "2026-10-18T11:00:00Z"), now); err == nil {
+		t.Error("expected an error for a time in the past")
+	}
+}
+
+func TestScheduledEntries(t *testing.T) {
+	q, err := spool.Open(filepath.Join(t.TempDir(), "spool"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	later := time.Now().Add(time.Hour)
+	req := api.NotifyRequest{Title: "T", Body: "b"}
+	q.Add(spool.Entry{Target: spool.TargetNotify, Request: req, NotBefore: later, Kind: spool.KindScheduled})
+	q.Add(spool.Entry{Target: spool.TargetNotify, Request: req, NotBefore: later, Kind: spool.KindHeld})
+	q.Add(spool.Entry{Target: spool.TargetNotify, Request: req})
+
+	entries, err := scheduledEntries(q)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].Kind != spool.KindScheduled {
+		t.Errorf("expected only the scheduled entry, got %+v", entries)
+	}
+}
@@ -0,0 +1,316 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/techulus/push-cli/internal/alertmanager"
+	"github.com/techulus/push-cli/internal/api"
+	"github.com/techulus/push-cli/internal/spool"
+	"github.com/techulus/push-cli/internal/webhook"
+)
+
+const (
+	defaultAlertTitle = `[{{.Status | upper}}{{if eq .Status "firing"}}:{{len .Alerts}}{{end}}] {{.CommonLabels.alertname}}`
+	defaultAlertBody  = `{{range .Alerts}}{{or .Annotations.summary .Annotations.description .Labels.alertname}}
+{{end}}`
+)
+
+// serveHTTP runs handler on ln until the command's context is canceled,
+// then gives in-flight requests shutdownTimeout to finish.
+func serveHTTP(cmd *cobra.Command, ln net.Listener, handler http.Handler, logger *slog.Logger) error {
+	shutdownTimeout, _ := cmd.Flags().GetDuration("shutdown-timeout")
+
+	server := &http.Server{Handler: handler, ReadHeaderTimeout: 10 * time.Second}
+	serveErr := make(chan error, 1)
+	go func() { serveErr <- server.Serve(ln) }()
+	logger.Info("listening", "address", ln.Addr().String())
+
+	select {
+	case err := <-serveErr:
+		return err
+	case <-cmd.Context().Done():
+	}
+
+	logger.Info("shutting down", "timeout", shutdownTimeout.String())
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	return server.Shutdown(ctx)
+}
+
+func addServeFlags(cmd *cobra.Command, listen string) {
+	addClientFlags(cmd)
+	cmd.Flags().String("listen", listen, "Address to listen on")
+	cmd.Flags().Duration("shutdown-timeout", 30*time.Second, "How long to wait for requests in flight on shutdown")
+	cmd.Flags().String("log-format", "text", "Log format: text or json")
+}
+
+func parseTemplateFlag(cmd *cobra.Command, name string) (*template.Template, error) {
+	text, _ := cmd.Flags().GetString(name)
+	if path, _ := cmd.Flags().GetString(name + "-file"); path != "" {
+		raw, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("reading template file: %w", err)
+		}
+		text = string(raw)
+	}
+	tmpl, err := template.New(name).Funcs(templateFuncs).Option("missingkey=zero").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", name, err)
+	}
+	return tmpl, nil
+}
+
+func alertmanagerOptions(cmd *cobra.Command) (alertmanager.Options, error) {
+	var opts alertmanager.Options
+	var err error
+	if opts.Title, err = parseTemplateFlag(cmd, "title-template"); err != nil {
+		return opts, err
+	}
+	if opts.Body, err = parseTemplateFlag(cmd, "body-template"); err != nil {
+		return opts, err
+	}
+
+	opts.SeverityLabel, _ = cmd.Flags().GetString("severity-label")
+	opts.GroupLabel, _ = cmd.Flags().GetString("group-label")
+	opts.Group, _ = cmd.Flags().GetString("group")
+	opts.ResolvedSound, _ = cmd.Flags().GetString("resolved-sound")
+	if err := validateSound(opts.ResolvedSound); err != nil {
+		return opts, err
+	}
+
+	sounds, _ := cmd.Flags().GetStringToString("severity-sound")
+	timeSensitive, _ := cmd.Flags().GetStringSlice("time-sensitive")
+	opts.Severities = map[string]alertmanager.Severity{}
+	for severity, sound := range sounds {
+		if err := validateSound(sound); err != nil {
+			return opts, fmt.Errorf("--severity-sound %s: %w", severity, err)
+		}
+		opts.Severities[severity] = alertmanager.Severity{Sound: sound}
+	}
+	for _, severity := range timeSensitive {
+		severity = strings.TrimSpace(severity)
+		sev := opts.Severities[severity]
+		sev.TimeSensitive = true
+		opts.Severities[severity] = sev
+	}
+	return opts, nil
+}
+
+// webhookOverrides collects the per-event --filter and --*-template flags,
+// each given as event=template.
+func webhookOverrides(cmd *cobra.Command) (map[string]webhook.EventTemplate, error) {
+	overrides := map[string]webhook.EventTemplate{}
+	for _, flag := range []string{"filter", "title-template", "body-template", "link-template", "sound-template"} {
+		values, _ := cmd.Flags().GetStringArray(flag)
+		for _, v := range values {
+			event, text, ok := strings.Cut(v, "=")
+			if !ok || event == "" || text == "" {
+				return nil, fmt.Errorf("invalid --%s %q, expected event=template", flag, v)
+			}
+			o := overrides[event]
+			switch flag {
+			case "filter":
+				o.Filter = text
+			case "title-template":
+				o.Title = text
+			case "body-template":
+				o.Body = text
+			case "link-template":
+				o.Link = text
+			case "sound-template":
+				o.Sound = text
+			}
+			overrides[event] = o
+		}
+	}
+	return overrides, nil
+}
+
+var serveCmd = &cobra.Command{
+	Use:   "serve",
+	Short: "Receive webhooks from other tools and turn them into notifications",
+}
+
+var serveAlertmanagerCmd = &cobra.Command{
+	Use:   "alertmanager",
+	Short: "Receive Prometheus Alertmanager webhooks",
+	Long: `Receive Prometheus Alertmanager webhooks and send each alert group as a
+notification: one for its firing alerts and one for its resolved alerts.
+
+Point an Alertmanager webhook receiver at this server:
+
+  receivers:
+    - name: push
+      webhook_configs:
+        - url: http://push-host:9094/
+          send_resolved: true
+
+Title and body templates receive the webhook payload, so they can use
+.Status, .Alerts, .GroupLabels, .CommonLabels, .CommonAnnotations and
+.ExternalURL. The severity label picks the sound and whether the
+notification is time-sensitive. With --group-label, alerts carrying that
+label are sent to the Push group it names.
+
+The server listens on 127.0.0.1:9094. To accept webhooks from another host,
+pass --listen :9094 --allow-remote together with a --token that the receiver
+sends as its bearer credentials.
+
+Alertmanager retries a webhook that fails, so the response is only sent once
+the notifications have been delivered. Notifications that went out before a
+failure are skipped when the webhook is retried.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		addr, _ := cmd.Flags().GetString("listen")
+		allowRemote, _ := cmd.Flags().GetBool("allow-remote")
+		token, _ := cmd.Flags().GetString("token")
+		if token == "" {
+			token = os.Getenv("PUSH_WEBHOOK_TOKEN")
+		}
+		// Anyone who can reach a remote listener could send notifications.
+		if allowRemote && token == "" {
+			fail(exitValidation, fmt.Errorf("--allow-remote needs a bearer token, pass --token or set PUSH_WEBHOOK_TOKEN"))
+		}
+
+		opts, err := alertmanagerOptions(cmd)
+		if err != nil {
+			fail(exitValidation, err)
+		}
+		logger, err := newLogger(cmd)
+		if err != nil {
+			fail(exitValidation, err)
+		}
+		client := newAPIClient(cmd)
+
+		handler := alertmanager.Handler(func(ctx context.Context, n alertmanager.Notification) error {
+			target := spool.TargetNotify
+			if n.GroupID != "" {
+				target = spool.TargetGroup
+			}
+			_, err := sendToTarget(ctx, client, target, n.GroupID, n.Request)
+			return err
+		}, opts, token, logger)
+
+		ln, err := listen(addr, "", allowRemote)
+		if err != nil {
+			fail(exitError, err)
+		}
+		if err := serveHTTP(cmd, ln, handler, logger); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			fail(exitError, err)
+		}
+	},
+}
+
+var serveWebhookCmd = &cobra.Command{
+	Use:   "webhook",
+	Short: "Receive GitHub or GitLab repository webhooks",
+	Long: `Receive GitHub or GitLab repository webhooks and send selected events as
+notifications.
+
+Every delivery is verified before it is used: GitHub's X-Hub-Signature-256
+HMAC, or GitLab's X-Gitlab-Token, against --secret (or PUSH_WEBHOOK_SECRET).
+
+Supported events:
+  github: push, pull_request, workflow_run, release
+  gitlab: push, tag_push, merge_request, pipeline
+
+Each event has a default filter and templates, e.g. workflow_run is only
+sent once completed and plays the fail sound on failure. Override them per
+event with event=template; templates receive the event payload:
+
+  push serve webhook --provider github --events workflow_run \
+    --filter 'workflow_run={{eq .workflow_run.conclusion "failure"}}' \
+    --title-template 'workflow_run=CI broke on {{.workflow_run.head_branch}}'`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		addr, _ := cmd.Flags().GetString("listen")
+		providerName, _ := cmd.Flags().GetString("provider")
+		events, _ := cmd.Flags().GetStringSlice("events")
+		groupID, _ := cmd.Flags().GetString("group")
+		secret, _ := cmd.Flags().GetString("secret")
+		if secret == "" {
+			secret = os.Getenv("PUSH_WEBHOOK_SECRET")
+		}
+		if secret == "" {
+			fail(exitValidation, fmt.Errorf("a webhook secret is required, pass --secret or set PUSH_WEBHOOK_SECRET"))
+		}
+
+		provider, err := webhook.ProviderByName(providerName)
+		if err != nil {
+			fail(exitValidation, err)
+		}
+		if len(events) == 0 {
+			events = provider.EventNames()
+		}
+		overrides, err := webhookOverrides(cmd)
+		if err != nil {
+			fail(exitValidation, err)
+		}
+		rules, err := webhook.Compile(provider, events, overrides, templateFuncs)
+		if err != nil {
+			fail(exitValidation, err)
+		}
+		logger, err := newLogger(cmd)
+		if err != nil {
+			fail(exitValidation, err)
+		}
+		client := newAPIClient(cmd)
+
+		handler := webhook.Handler(provider, func(ctx context.Context, req api.NotifyRequest) error {
+			target := spool.TargetNotify
+			if groupID != "" {
+				target = spool.TargetGroup
+			}
+			_, err := sendToTarget(ctx, client, target, groupID, req)
+			return err
+		}, webhook.Options{Secret: secret, Rules: rules, Validate: validateNotifyRequest, Logger: logger})
+
+		ln, err := net.Listen("tcp", addr)
+		if err != nil {
+			fail(exitError, err)
+		}
+		if err := serveHTTP(cmd, ln, handler, logger); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			fail(exitError, err)
+		}
+	},
+}
+
+func init() {
+	addServeFlags(serveAlertmanagerCmd, "127.0.0.1:9094")
+	serveAlertmanagerCmd.Flags().Bool("allow-remote", false, "Allow listening on a non-loopback address (needs --token)")
+	serveAlertmanagerCmd.Flags().String("token", "", "Require this bearer token on requests (or set PUSH_WEBHOOK_TOKEN)")
+	serveAlertmanagerCmd.Flags().String("title-template", defaultAlertTitle, "Notification title template")
+	serveAlertmanagerCmd.Flags().String("body-template", defaultAlertBody, "Notification body template")
+	serveAlertmanagerCmd.Flags().String("title-template-file", "", "File containing the title template")
+	serveAlertmanagerCmd.Flags().String("body-template-file", "", "File containing the body template")
+	serveAlertmanagerCmd.Flags().String("severity-label", "severity", "Alert label holding the severity")
+	serveAlertmanagerCmd.Flags().StringToString("severity-sound", map[string]string{"critical": "fail", "warning": "doorbell"}, "Sound per severity, e.g. critical=fail,warning=pop")
+	serveAlertmanagerCmd.Flags().StringSlice("time-sensitive", []string{"critical"}, "Severities sent as time-sensitive notifications")
+	serveAlertmanagerCmd.Flags().String("resolved-sound", "correct", "Sound for resolved alerts")
+	serveAlertmanagerCmd.Flags().String("group-label", "", "Alert label holding the Push group ID to notify")
+	serveAlertmanagerCmd.Flags().String("group", "", "Push group ID to notify when --group-label is not set on the alerts")
+	serveCmd.AddCommand(serveAlertmanagerCmd)
+
+	addServeFlags(serveWebhookCmd, ":9095")
+	serveWebhookCmd.Flags().String("provider", "", "Webhook provider: github or gitlab")
+	serveWebhookCmd.MarkFlagRequired("provider")
+	serveWebhookCmd.Flags().String("secret", "", "Webhook secret used to verify deliveries (or set PUSH_WEBHOOK_SECRET)")
+	serveWebhookCmd.Flags().StringSlice("events", nil, "Events to notify about (default: all supported events)")
+	serveWebhookCmd.Flags().String("group", "", "Push group ID to notify instead of the API key's devices")
+	serveWebhookCmd.Flags().StringArray("filter", nil, "Per-event filter as event=template, sent only when it renders \"true\" (repeatable)")
+	serveWebhookCmd.Flags().StringArray("title-template", nil, "Per-event title template as event=template (repeatable)")
+	serveWebhookCmd.Flags().StringArray("body-template", nil, "Per-event body template as event=template (repeatable)")
+	serveWebhookCmd.Flags().StringArray("link-template", nil, "Per-event link template as event=template (repeatable)")
+	serveWebhookCmd.Flags().StringArray("sound-template", nil, "Per-event sound template as event=template (repeatable)")
+	serveCmd.AddCommand(serveWebhookCmd)
+	rootCmd.AddCommand(serveCmd)
+}
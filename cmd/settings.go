@@ -0,0 +1,238 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+	"github.com/techulus/push-cli/internal/api"
+	"github.com/techulus/push-cli/internal/config"
+	"github.com/techulus/push-cli/internal/throttle"
+)
+
+// setting is a value that "push config set" can save to the active profile.
+type setting struct {
+	Key         string
+	Env         string
+	Description string
+	// parse checks a value with the same rules as the matching flag and
+	// returns what is written to the config file.
+	parse func(string) (interface{}, error)
+}
+
+func parseString(value string) (interface{}, error) {
+	return value, nil
+}
+
+var settings = []setting{
+	{Key: "base_url", Env: config.BaseURLEnv, Description: "Push API base URL", parse: func(v string) (interface{}, error) {
+		v = strings.TrimSpace(v)
+		return v, api.ValidateBaseURL(v)
+	}},
+	{Key: "rate_limit", Env: config.RateLimitEnv, Description: "Most notifications sent per period, e.g. 30/m", parse: func(v string) (interface{}, error) {
+		v = strings.TrimSpace(v)
+		_, err := throttle.ParseRate(v)
+		return v, err
+	}},
+	{Key: "api_key_command", Description: "Command that prints the API key", parse: parseString},
+	{Key: "notify.sound", Description: "Default --sound", parse: func(v string) (interface{}, error) {
+		v = strings.TrimSpace(v)
+		return v, validateSound(v)
+	}},
+	{Key: "notify.channel", Description: "Default --channel", parse: parseString},
+	{Key: "notify.link", Description: "Default --link", parse: parseString},
+	{Key: "notify.image", Description: "Default --image", parse: parseString},
+	{Key: "notify.time_sensitive", Description: "Default --time-sensitive", parse: func(v string) (interface{}, error) {
+		b, err := strconv.ParseBool(strings.TrimSpace(v))
+		if err != nil {
+			return nil, fmt.Errorf("invalid boolean %q, use true or false", v)
+		}
+		return b, nil
+	}},
+	{Key: "notify.title_prefix", Description: "Text put in front of every notification title", parse: parseString},
+}
+
+func findSetting(key string) (setting, error) {
+	key = strings.ToLower(key)
+	for _, s := range settings {
+		if s.Key == key {
+			return s, nil
+		}
+	}
+	if key == "api_key" || key == "api_key_store" {
+		return setting{}, errors.New("use push config set-key to change the API key")
+	}
+	keys := make([]string, len(settings))
+	for i, s := range settings {
+		keys[i] = s.Key
+	}
+	return setting{}, fmt.Errorf("unknown setting %q, valid settings: %s", key, strings.Join(keys, ", "))
+}
+
+// notifyFlag returns the value of a notify flag, falling back to the
+// notify.* setting when the flag wasn't given.
+func notifyFlag(cmd *cobra.Command, name string) string {
+	f := cmd.Flags().Lookup(name)
+	if f.Changed {
+		return f.Value.String()
+	}
+	if value, _ := config.LookupSetting("notify."+strings.ReplaceAll(name, "-", "_"), ""); value != "" {
+		return value
+	}
+	return f.Value.String()
+}
+
+func titlePrefix() string {
+	prefix, _ := config.LookupSetting("notify.title_prefix", "")
+	return prefix
+}
+
+// withTitlePrefix adds notify.title_prefix to a title, if there is one.
+func withTitlePrefix(title string) string {
+	if title == "" {
+		return title
+	}
+	return titlePrefix() + title
+}
+
+type settingOutput struct {
+	Key    string `json:"key"`
+	Value  string `json:"value"`
+	Source string `json:"source"`
+}
+
+// effectiveSettings returns the settings that have a value, and where it
+// came from.
+func effectiveSettings() []settingOutput {
+	out := []settingOutput{}
+	for _, s := range settings {
+		value, src := config.LookupSetting(s.Key, s.Env)
+		if value == "" {
+			continue
+		}
+		out = append(out, settingOutput{Key: s.Key, Value: value, Source: describeSource(src, s.Key, s.Env)})
+	}
+	return out
+}
+
+var configSetCmd = &cobra.Command{
+	Use:   "set <key> <value>",
+	Short: "Save a setting to the active profile, e.g. notify.sound pop",
+	Long: `Save a setting to the active profile.
+
+The notify.* settings are defaults for the notify flags and are used
+whenever the flag isn't given. Run "push config get" to list all settings.`,
+	Args: cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		s, err := findSetting(args[0])
+		if err != nil {
+			fail(exitValidation, err)
+		}
+		// Values aren't trimmed here, so a title prefix can end in a space.
+		raw := args[1]
+		if strings.TrimSpace(raw) == "" {
+			fail(exitValidation, fmt.Errorf("%s can't be empty, use: push config unset %s", s.Key, s.Key))
+		}
+		value, err := s.parse(raw)
+		if err != nil {
+			fail(exitValidation, err)
+		}
+		if err := config.SetSetting(s.Key, value); err != nil {
+			fail(exitError, fmt.Errorf("saving %s: %w", s.Key, err))
+		}
+		if outputFormat == outputText {
+			fmt.Printf("%s saved to profile %q\n", s.Key, config.ActiveProfile())
+		}
+	},
+}
+
+var configGetCmd = &cobra.Command{
+	Use:   "get [key]",
+	Short: "Print a setting, or list all settings",
+	Args:  cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(args) == 0 {
+			listSettings()
+			return
+		}
+		s, err := findSetting(args[0])
+		if err != nil {
+			fail(exitValidation, err)
+		}
+		value, src := config.LookupSetting(s.Key, s.Env)
+		switch outputFormat {
+		case outputJSON:
+			printJSON(settingOutput{Key: s.Key, Value: value, Source: describeSource(src, s.Key, s.Env)})
+		case outputText:
+			if value != "" {
+				fmt.Println(value)
+			}
+		}
+		// Like git config, an unset key exits with 1 so scripts can tell.
+		if value == "" {
+			os.Exit(1)
+		}
+	},
+}
+
+func listSettings() {
+	if outputFormat == outputJSON {
+		out := []settingOutput{}
+		for _, s := range settings {
+			value, src := config.LookupSetting(s.Key, s.Env)
+			out = append(out, settingOutput{Key: s.Key, Value: value, Source: describeSource(src, s.Key, s.Env)})
+		}
+		printJSON(out)
+		return
+	}
+	if outputFormat != outputText {
+		return
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "KEY\tVALUE\tSOURCE\tDESCRIPTION")
+	for _, s := range settings {
+		value, src := config.LookupSetting(s.Key, s.Env)
+		source := "-"
+		if value == "" {
+			value = "-"
+		} else {
+			source = describeSource(src, s.Key, s.Env)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", s.Key, value, source, s.Description)
+	}
+	w.Flush()
+}
+
+var configUnsetCmd = &cobra.Command{
+	Use:   "unset <key>",
+	Short: "Remove a setting from the active profile",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		s, err := findSetting(args[0])
+		if err != nil {
+			fail(exitValidation, err)
+		}
+		found, err := config.UnsetSetting(s.Key)
+		if err != nil {
+			fail(exitError, fmt.Errorf("removing %s: %w", s.Key, err))
+		}
+		if outputFormat != outputText {
+			return
+		}
+		if !found {
+			fmt.Printf("%s is not set in profile %q\n", s.Key, config.ActiveProfile())
+			return
+		}
+		fmt.Printf("%s removed from profile %q\n", s.Key, config.ActiveProfile())
+	},
+}
+
+func init() {
+	configCmd.AddCommand(configSetCmd)
+	configCmd.AddCommand(configGetCmd)
+	configCmd.AddCommand(configUnsetCmd)
+}
@@ -0,0 +1,94 @@
+package cmd
+
+import (
+	"testing"
+
+	"github.com/spf13/viper"
+)
+
+func TestFindSetting(t *testing.T) {
+	if s, err := findSetting("Notify.Sound"); err != nil || s.Key != "notify.sound" {
+		t.Errorf("findSetting() = %+v, %v, want notify.sound", s, err)
+	}
+	for _, key := range []string{"notify.volume", "api_key"} {
+		if _, err := findSetting(key); err == nil {
+			t.Errorf("findSetting(%q) expected an error", key)
+		}
+	}
+}
+
+func TestSettingParse(t *testing.T) {
+	tests := []struct {
+		key, value string
+		want       interface{}
+		wantErr    bool
+	}{
+		{"notify.sound", "pop", "pop", false},
+		{"notify.sound", "loud", nil, true},
+		{"notify.time_sensitive", "yes", nil, true},
+		{"notify.time_sensitive", "true", true, false},
+		{"rate_limit", "30/m", "30/m", false},
+		{"rate_limit", "lots", nil, true},
+		{"base_url", "ftp://example.com", nil, true},
+	}
+	for _, tt := range tests {
+		s, err := findSetting(tt.key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := s.parse(tt.value)
+		if (err != nil) != tt.wantErr || (!tt.wantErr && got != tt.want) {
+			t.Errorf("parse %s=%q = %v, %v, want %v, error %v", tt.key, tt.value, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestNotifyRequestWithBody_Defaults(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	viper.Reset()
+	t.Cleanup(viper.Reset)
+	viper.Set("notify.sound", "pop")
+	viper.Set("notify.time_sensitive", true)
+	viper.Set("profiles.default.notify.channel", "ops")
+	viper.Set("profiles.default.notify.title_prefix", "[prod] ")
+
+	cmd := newTestCmd()
+	cmd.SetArgs([]string{"--title", "Deploy", "--body", "done"})
+	cmd.Execute()
+	req, err := notifyRequestWithBody(cmd, "done")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if req.Title != "[prod] Deploy" || req.Sound != "pop" || req.Channel != "ops" || !req.TimeSensitive {
+		t.Errorf("expected the config defaults, got %+v", req)
+	}
+
+	cmd = newTestCmd()
+	cmd.SetArgs([]string{"--title", "Deploy", "--body", "done", "--sound", "", "--channel", "dev", "--time-sensitive=false"})
+	cmd.Execute()
+	req, err = notifyRequestWithBody(cmd, "done")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if req.Sound != "" || req.Channel != "dev" || req.TimeSensitive {
+		t.Errorf("expected flags to win over the config defaults, got %+v", req)
+	}
+}
+
+func TestBuildNotifyRequest_TitlePrefixIsLiteral(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	viper.Reset()
+	t.Cleanup(viper.Reset)
+	viper.Set("notify.title_prefix", "{{.Var.x}} ")
+
+	cmd := newTemplateTestCmd()
+	cmd.SetArgs([]string{"--title", "{{.Var.x | upper}}", "--body", "done", "--var", "x=api"})
+	cmd.Execute()
+	req, err := buildNotifyRequest(cmd)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if req.Title != "{{.Var.x}} API" {
+		t.Errorf("expected the rendered title behind the literal prefix, got %q", req.Title)
+	}
+}
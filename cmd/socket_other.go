@@ -0,0 +1,20 @@
+//go:build !unix
+
+package cmd
+
+import (
+	"net"
+	"os"
+)
+
+func listenUnix(path string) (net.Listener, error) {
+	ln, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Chmod(path, 0600); err != nil {
+		ln.Close()
+		return nil, err
+	}
+	return ln, nil
+}
@@ -0,0 +1,16 @@
+//go:build unix
+
+package cmd
+
+import (
+	"net"
+	"syscall"
+)
+
+// listenUnix creates the socket under a umask that leaves it readable and
+// writable by the owner only, so there is no window where others can connect.
+func listenUnix(path string) (net.Listener, error) {
+	old := syscall.Umask(0177)
+	defer syscall.Umask(old)
+	return net.Listen("unix", path)
+}
@@ -0,0 +1,121 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+	"time"
+	"unicode/utf8"
+
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+var templateFuncs = template.FuncMap{
+	"truncate": func(n int, s string) string {
+		if n < 0 || utf8.RuneCountInString(s) <= n {
+			return s
+		}
+		return string([]rune(s)[:n]) + "..."
+	},
+	"hostname": func() string {
+		name, _ := os.Hostname()
+		return name
+	},
+	"now": time.Now,
+	"format": func(layout string, t time.Time) string {
+		return t.Format(layout)
+	},
+	"upper": strings.ToUpper,
+	"lower": strings.ToLower,
+	"trim":  strings.TrimSpace,
+	"env":   os.Getenv,
+	"default": func(def, value interface{}) interface{} {
+		switch v := value.(type) {
+		case nil:
+			return def
+		case string:
+			if v == "" {
+				return def
+			}
+		}
+		return value
+	},
+}
+
+type templateData struct {
+	Var  map[string]string
+	Env  map[string]string
+	Data interface{}
+}
+
+func addTemplateFlags(cmd *cobra.Command) {
+	cmd.Flags().StringArray("var", nil, "Template variable as key=value, available as {{.Var.key}} (repeatable)")
+	cmd.Flags().String("data", "", "JSON or YAML file available to templates as {{.Data}}")
+	cmd.Flags().String("template-file", "", "File containing the body template (instead of --body)")
+}
+
+func templateDataFromFlags(cmd *cobra.Command) (templateData, error) {
+	data := templateData{Var: map[string]string{}, Env: map[string]string{}}
+
+	for _, kv := range os.Environ() {
+		if key, value, ok := strings.Cut(kv, "="); ok {
+			data.Env[key] = value
+		}
+	}
+
+	vars, _ := cmd.Flags().GetStringArray("var")
+	for _, kv := range vars {
+		key, value, ok := strings.Cut(kv, "=")
+		if !ok || key == "" {
+			return data, fmt.Errorf("invalid --var %q, expected key=value", kv)
+		}
+		data.Var[key] = value
+	}
+
+	if path, _ := cmd.Flags().GetString("data"); path != "" {
+		parsed, err := loadTemplateDataFile(path)
+		if err != nil {
+			return data, err
+		}
+		data.Data = parsed
+	}
+	return data, nil
+}
+
+func loadTemplateDataFile(path string) (interface{}, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading data file: %w", err)
+	}
+
+	var parsed interface{}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		err = json.Unmarshal(raw, &parsed)
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(raw, &parsed)
+	default:
+		return nil, fmt.Errorf("unsupported data file %q (use .json, .yaml or .yml)", path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("parsing data file %s: %w", path, err)
+	}
+	return parsed, nil
+}
+
+func renderTemplate(name, text string, data templateData) (string, error) {
+	tmpl, err := template.New(name).Funcs(templateFuncs).Option("missingkey=zero").Parse(text)
+	if err != nil {
+		return "", fmt.Errorf("parsing %s template: %w", name, err)
+	}
+
+	var b strings.Builder
+	if err := tmpl.Execute(&b, data); err != nil {
+		return "", fmt.Errorf("rendering %s template: %w", name, err)
+	}
+	return b.String(), nil
+}
@@ -0,0 +1,119 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/cobra"
+)
+
+func newTemplateTestCmd() *cobra.Command {
+	cmd := newTestCmd()
+	addTemplateFlags(cmd)
+	return cmd
+}
+
+func TestBuildNotifyRequest_Templates(t *testing.T) {
+	t.Setenv("PUSH_TEST_STAGE", "prod")
+
+	dataFile := filepath.Join(t.TempDir(), "data.yaml")
+	os.WriteFile(dataFile, []byte("build:\n  id: 42\n"), 0600)
+
+	cmd := newTemplateTestCmd()
+	cmd.SetArgs([]string{
+		"--title", "{{.Var.service | upper}} on {{.Env.PUSH_TEST_STAGE}}",
+		"--body", "Build {{.Data.build.id}} {{.Var.note | default \"-\"}}",
+		"--var", "service=api",
+		"--data", dataFile,
+	})
+	cmd.Execute()
+
+	req, err := buildNotifyRequest(cmd)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if req.Title != "API on prod" {
+		t.Errorf("expected rendered title, got %q", req.Title)
+	}
+	if req.Body != "Build 42 -" {
+		t.Errorf("expected rendered body, got %q", req.Body)
+	}
+}
+
+func TestBuildNotifyRequest_TemplateFile(t *testing.T) {
+	tmplFile := filepath.Join(t.TempDir(), "body.tmpl")
+	os.WriteFile(tmplFile, []byte("{{ truncate 5 .Var.msg }}\n"), 0600)
+
+	cmd := newTemplateTestCmd()
+	cmd.SetArgs([]string{"--title", "Test", "--template-file", tmplFile, "--var", "msg=hello world"})
+	cmd.Execute()
+
+	req, err := buildNotifyRequest(cmd)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if req.Body != "hello..." {
+		t.Errorf("expected truncated body, got %q", req.Body)
+	}
+}
+
+func TestBuildNotifyRequest_TemplateFileAndBody(t *testing.T) {
+	cmd := newTemplateTestCmd()
+	cmd.SetArgs([]string{"--title", "Test", "--body", "x", "--template-file", "body.tmpl"})
+	cmd.Execute()
+
+	if _, err := buildNotifyRequest(cmd); err == nil {
+		t.Fatal("expected error when both --body and --template-file are set")
+	}
+}
+
+func TestBuildNotifyRequest_StdinNotTemplated(t *testing.T) {
+	r, w, _ := os.Pipe()
+	w.WriteString("literal {{ .Var.x }}")
+	w.Close()
+
+	origStdin := os.Stdin
+	os.Stdin = r
+	defer func() { os.Stdin = origStdin }()
+
+	cmd := newTemplateTestCmd()
+	cmd.SetArgs([]string{"--title", "Test"})
+	cmd.Execute()
+
+	req, err := buildNotifyRequest(cmd)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if req.Body != "literal {{ .Var.x }}" {
+		t.Errorf("expected stdin body to be sent as-is, got %q", req.Body)
+	}
+}
+
+func TestTemplateDataFromFlags_InvalidVar(t *testing.T) {
+	cmd := newTemplateTestCmd()
+	cmd.SetArgs([]string{"--title", "Test", "--var", "novalue"})
+	cmd.Execute()
+
+	if _, err := templateDataFromFlags(cmd); err == nil {
+		t.Fatal("expected error for --var without '='")
+	}
+}
+
+func TestRenderTemplate_Errors(t *testing.T) {
+	data := templateData{}
+	if _, err := renderTemplate("title", "{{ .Var", data); err == nil {
+		t.Error("expected parse error")
+	}
+	if _, err := renderTemplate("title", "{{ truncate \"x\" .Var.a }}", data); err == nil {
+		t.Error("expected execution error")
+	}
+}
+
+func TestLoadTemplateDataFile_UnsupportedExtension(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "data.txt")
+	os.WriteFile(path, []byte("x"), 0600)
+	if _, err := loadTemplateDataFile(path); err == nil {
+		t.Fatal("expected error for unsupported extension")
+	}
+}
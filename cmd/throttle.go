@@ -0,0 +1,132 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/techulus/push-cli/internal/api"
+	"github.com/techulus/push-cli/internal/config"
+	"github.com/techulus/push-cli/internal/throttle"
+)
+
+func addThrottleFlags(cmd *cobra.Command) {
+	cmd.Flags().String("dedup-key", "", "Key identifying repeats of this notification (default: hash of title, body and channel)")
+	cmd.Flags().Duration("dedup-window", 0, "Don't send repeats of this notification for this long")
+	cmd.Flags().Bool("report-suppressed", false, "Mention suppressed repeats and rate-limited notifications in the next one sent")
+}
+
+func openThrottle() (*throttle.Store, error) {
+	dir, err := config.Dir()
+	if err != nil {
+		return nil, err
+	}
+	return throttle.Open(filepath.Join(dir, "throttle.json")), nil
+}
+
+// rateLimit returns the active profile's rate limit, or nil if it has none.
+func rateLimit() (*throttle.Rate, error) {
+	value, src := config.LookupRateLimit()
+	if value == "" {
+		return nil, nil
+	}
+	rate, err := throttle.ParseRate(value)
+	if err != nil {
+		return nil, fmt.Errorf("%v (from %s)", err, describeSource(src, "rate_limit", config.RateLimitEnv))
+	}
+	return &rate, nil
+}
+
+func throttleRequest(cmd *cobra.Command, req api.NotifyRequest) (throttle.Request, error) {
+	r := throttle.Request{Profile: config.ActiveProfile()}
+	if cmd.Flags().Lookup("dedup-window") != nil {
+		r.Key, _ = cmd.Flags().GetString("dedup-key")
+		r.Window, _ = cmd.Flags().GetDuration("dedup-window")
+		if r.Window < 0 {
+			return r, fmt.Errorf("--dedup-window can't be negative")
+		}
+		if r.Key != "" && r.Window == 0 {
+			return r, fmt.Errorf("--dedup-key needs a --dedup-window")
+		}
+		if r.Key == "" {
+			r.Key = throttle.Key(req.Title, req.Body, req.Channel)
+		}
+	}
+
+	var err error
+	r.Rate, err = rateLimit()
+	return r, err
+}
+
+// suppressedNote describes what was suppressed before d was allowed.
+func suppressedNote(d throttle.Decision) string {
+	var parts []string
+	if d.Duplicates > 0 {
+		parts = append(parts, fmt.Sprintf("%d repeat(s) suppressed", d.Duplicates))
+	}
+	if d.RateLimited > 0 {
+		parts = append(parts, fmt.Sprintf("%d notification(s) dropped by the rate limit", d.RateLimited))
+	}
+	if len(parts) == 0 {
+		return ""
+	}
+	return "(" + strings.Join(parts, ", ") + ")"
+}
+
+type suppressedOutput struct {
+	Success    bool   `json:"success"`
+	Suppressed bool   `json:"suppressed"`
+	Reason     string `json:"reason"`
+}
+
+// throttleNotification applies --dedup-window and the profile's rate limit
+// to req. It returns false if req must not be sent, after printing why, and
+// otherwise a function to call if sending fails. If the state file can't be
+// used, the notification is sent anyway.
+func throttleNotification(cmd *cobra.Command, req *api.NotifyRequest) (release func(), ok bool) {
+	r, err := throttleRequest(cmd, *req)
+	if err != nil {
+		fail(exitValidation, err)
+	}
+	if r.Window == 0 && r.Rate == nil {
+		return func() {}, true
+	}
+
+	store, err := openThrottle()
+	var d throttle.Decision
+	if err == nil {
+		d, err = store.Allow(r, time.Now())
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: skipping dedup and rate limit: %v\n", err)
+		return func() {}, true
+	}
+
+	if !d.Allowed {
+		switch outputFormat {
+		case outputJSON:
+			printJSON(suppressedOutput{Success: true, Suppressed: true, Reason: string(d.Reason)})
+		case outputText:
+			if d.Reason == throttle.Duplicate {
+				fmt.Printf("Notification suppressed: already sent within %s\n", r.Window)
+			} else {
+				fmt.Printf("Notification suppressed: rate limit of %s reached\n", r.Rate)
+			}
+		}
+		return nil, false
+	}
+
+	if report, _ := cmd.Flags().GetBool("report-suppressed"); report {
+		if note := suppressedNote(d); note != "" {
+			req.Body += "\n\n" + note
+		}
+	}
+	return func() {
+		if err := store.Release(d); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+		}
+	}, true
+}
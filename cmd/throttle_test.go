@@ -0,0 +1,73 @@
+package cmd
+
+import (
+	"testing"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/techulus/push-cli/internal/api"
+	"github.com/techulus/push-cli/internal/config"
+	"github.com/techulus/push-cli/internal/throttle"
+)
+
+func newThrottleTestCmd(args ...string) *cobra.Command {
+	cmd := &cobra.Command{Use: "test", Run: func(*cobra.Command, []string) {}}
+	addThrottleFlags(cmd)
+	cmd.SetArgs(args)
+	cmd.Execute()
+	return cmd
+}
+
+func TestThrottleRequest(t *testing.T) {
+	t.Setenv(config.RateLimitEnv, "")
+	req := api.NotifyRequest{Title: "Disk full", Body: "/var at 99%"}
+
+	r, err := throttleRequest(newThrottleTestCmd("--dedup-window", "5m"), req)
+	if err != nil {
+		t.Fatalf("throttleRequest() error: %v", err)
+	}
+	if r.Key != throttle.Key(req.Title, req.Body, "") || r.Window != 5*time.Minute || r.Rate != nil {
+		t.Errorf("unexpected request %+v", r)
+	}
+
+	r, err = throttleRequest(newThrottleTestCmd("--dedup-window", "5m", "--dedup-key", "disk"), req)
+	if err != nil || r.Key != "disk" {
+		t.Errorf("expected the --dedup-key to be used, got %+v, %v", r, err)
+	}
+
+	if _, err := throttleRequest(newThrottleTestCmd("--dedup-key", "disk"), req); err == nil {
+		t.Error("expected an error for --dedup-key without --dedup-window")
+	}
+}
+
+func TestThrottleRequest_RateLimit(t *testing.T) {
+	t.Setenv(config.RateLimitEnv, "10/m")
+	r, err := throttleRequest(newThrottleTestCmd(), api.NotifyRequest{})
+	if err != nil {
+		t.Fatalf("throttleRequest() error: %v", err)
+	}
+	if r.Window != 0 || r.Rate == nil || *r.Rate != (throttle.Rate{Count: 10, Per: time.Minute}) {
+		t.Errorf("unexpected request %+v", r)
+	}
+
+	t.Setenv(config.RateLimitEnv, "lots")
+	if _, err := throttleRequest(newThrottleTestCmd(), api.NotifyRequest{}); err == nil {
+		t.Error("expected an error for an invalid rate limit")
+	}
+}
+
+func TestSuppressedNote(t *testing.T) {
+	tests := []struct {
+		d    throttle.Decision
+		want string
+	}{
+		{throttle.Decision{Allowed: true}, ""},
+		{throttle.Decision{Allowed: true, Duplicates: 3}, "(3 repeat(s) suppressed)"},
+		{throttle.Decision{Allowed: true, Duplicates: 1, RateLimited: 2}, "(1 repeat(s) suppressed, 2 notification(s) dropped by the rate limit)"},
+	}
+	for _, tt := range tests {
+		if got := suppressedNote(tt.d); got != tt.want {
+			t.Errorf("suppressedNote(%+v) = %q, want %q", tt.d, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,268 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/techulus/push-cli/internal/api"
+	"github.com/techulus/push-cli/internal/tail"
+)
+
+type watchRule struct {
+	re    *regexp.Regexp
+	title string
+	sound string
+}
+
+type numberedLine struct {
+	n    int
+	text string
+}
+
+type watchBatch struct {
+	count    int
+	lines    []string
+	lastLine int
+	deadline time.Time
+}
+
+// lineMatcher turns matching lines into notifications. Each line is checked
+// against the rules in order and counts for the first rule it matches.
+// Matches for a rule within window of its first match are sent together.
+type lineMatcher struct {
+	rules    []watchRule
+	context  int
+	window   time.Duration
+	maxLines int
+
+	lineNo  int
+	recent  []numberedLine
+	pending map[int]*watchBatch
+}
+
+func newLineMatcher(rules []watchRule, contextLines int, window time.Duration, maxLines int) *lineMatcher {
+	return &lineMatcher{rules: rules, context: contextLines, window: window, maxLines: maxLines, pending: map[int]*watchBatch{}}
+}
+
+// line records text and returns any notification that is ready to send.
+func (m *lineMatcher) line(text string, now time.Time) []api.NotifyRequest {
+	m.lineNo++
+	defer func() {
+		if m.context > 0 {
+			m.recent = append(m.recent, numberedLine{m.lineNo, text})
+			if len(m.recent) > m.context {
+				m.recent = m.recent[1:]
+			}
+		}
+	}()
+
+	for i, rule := range m.rules {
+		if !rule.re.MatchString(text) {
+			continue
+		}
+
+		b := m.pending[i]
+		if b == nil {
+			b = &watchBatch{deadline: now.Add(m.window)}
+			m.pending[i] = b
+		}
+		b.count++
+		// Context lines already included for an earlier match aren't
+		// repeated; a gap between chunks is marked like grep does.
+		for _, l := range m.recent {
+			if l.n <= b.lastLine {
+				continue
+			}
+			if b.lastLine > 0 && l.n > b.lastLine+1 {
+				b.lines = append(b.lines, "--")
+			}
+			b.lines = append(b.lines, l.text)
+			b.lastLine = l.n
+		}
+		if b.lastLine > 0 && m.lineNo > b.lastLine+1 {
+			b.lines = append(b.lines, "--")
+		}
+		b.lines = append(b.lines, text)
+		b.lastLine = m.lineNo
+
+		if m.window <= 0 {
+			return m.due(now)
+		}
+		return nil
+	}
+	return nil
+}
+
+// due returns the batches whose window has passed.
+func (m *lineMatcher) due(now time.Time) []api.NotifyRequest {
+	var reqs []api.NotifyRequest
+	for i := range m.rules {
+		if b := m.pending[i]; b != nil && !now.Before(b.deadline) {
+			reqs = append(reqs, m.build(i, b))
+			delete(m.pending, i)
+		}
+	}
+	return reqs
+}
+
+// flush returns every pending batch, regardless of its window.
+func (m *lineMatcher) flush() []api.NotifyRequest {
+	var reqs []api.NotifyRequest
+	for i := range m.rules {
+		if b := m.pending[i]; b != nil {
+			reqs = append(reqs, m.build(i, b))
+			delete(m.pending, i)
+		}
+	}
+	return reqs
+}
+
+func (m *lineMatcher) build(i int, b *watchBatch) api.NotifyRequest {
+	rule := m.rules[i]
+	lines := b.lines
+	if m.maxLines > 0 && len(lines) > m.maxLines {
+		lines = append(lines[:m.maxLines:m.maxLines], fmt.Sprintf("... %d more line(s)", len(b.lines)-m.maxLines))
+	}
+	body := strings.Join(lines, "\n")
+	if b.count > 1 {
+		body = fmt.Sprintf("%d matching lines\n%s", b.count, body)
+	}
+	return api.NotifyRequest{Title: rule.title, Body: body, Sound: rule.sound}
+}
+
+// watchRules pairs each --match with the --title and --sound at the same
+// position. A single --title or --sound applies to every rule.
+func watchRules(cmd *cobra.Command, path string) ([]watchRule, error) {
+	matches, _ := cmd.Flags().GetStringArray("match")
+	titles, _ := cmd.Flags().GetStringArray("title")
+	sounds, _ := cmd.Flags().GetStringArray("sound")
+	if len(matches) == 0 {
+		return nil, fmt.Errorf("at least one --match is required")
+	}
+	if len(titles) > 1 && len(titles) != len(matches) {
+		return nil, fmt.Errorf("got %d --title values for %d --match rules, pass one or one per rule", len(titles), len(matches))
+	}
+	if len(sounds) > 1 && len(sounds) != len(matches) {
+		return nil, fmt.Errorf("got %d --sound values for %d --match rules, pass one or one per rule", len(sounds), len(matches))
+	}
+
+	pick := func(values []string, i int) string {
+		switch len(values) {
+		case 0:
+			return ""
+		case 1:
+			return values[0]
+		}
+		return values[i]
+	}
+
+	rules := make([]watchRule, len(matches))
+	for i, expr := range matches {
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid --match %q: %w", expr, err)
+		}
+		rule := watchRule{re: re, title: pick(titles, i), sound: pick(sounds, i)}
+		if rule.title == "" {
+			rule.title = fmt.Sprintf("%s: %s", filepath.Base(path), expr)
+		}
+		if err := validateSound(rule.sound); err != nil {
+			return nil, err
+		}
+		rules[i] = rule
+	}
+	return rules, nil
+}
+
+var watchCmd = &cobra.Command{
+	Use:   "watch",
+	Short: "Watch files and processes and notify when something happens",
+}
+
+var watchFileCmd = &cobra.Command{
+	Use:   "file <path>",
+	Short: "Follow a log file and notify on lines matching a pattern",
+	Long: `Follow a log file and notify on lines matching a regular expression.
+
+The file is followed across log rotation and truncation. Each --match is a
+rule; a line counts for the first rule it matches. Give one --title and
+--sound for every rule, or one per rule in the same order:
+
+  push watch file /var/log/app.log \
+    --match 'panic:' --title 'App panicked' --sound fail \
+    --match 'ERROR'  --title 'App error'    --sound pop
+
+Lines matching the same rule within --batch-window of each other are sent as
+one notification, so a burst of errors doesn't become a burst of pushes.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		path := args[0]
+		contextLines, _ := cmd.Flags().GetInt("context")
+		window, _ := cmd.Flags().GetDuration("batch-window")
+		maxLines, _ := cmd.Flags().GetInt("max-lines")
+		fromStart, _ := cmd.Flags().GetBool("from-start")
+		interval, _ := cmd.Flags().GetDuration("poll-interval")
+
+		rules, err := watchRules(cmd, path)
+		if err != nil {
+			fail(exitValidation, err)
+		}
+		client := newAPIClient(cmd)
+		matcher := newLineMatcher(rules, contextLines, window, maxLines)
+
+		lines := make(chan string)
+		followErr := make(chan error, 1)
+		go func() {
+			followErr <- tail.Follow(cmd.Context(), path, tail.Options{PollInterval: interval, FromStart: fromStart}, lines)
+		}()
+
+		// Pending batches are still sent after an interrupt.
+		sendCtx := context.WithoutCancel(cmd.Context())
+		send := func(reqs []api.NotifyRequest) {
+			for _, req := range reqs {
+				resp, err := client.NotifyContext(sendCtx, req)
+				if err != nil {
+					printError(exitCode(err), err)
+					continue
+				}
+				printResponse(resp)
+			}
+		}
+
+		ticker := time.NewTicker(time.Second)
+		defer ticker.Stop()
+		for {
+			select {
+			case line := <-lines:
+				send(matcher.line(line, time.Now()))
+			case now := <-ticker.C:
+				send(matcher.due(now))
+			case err := <-followErr:
+				send(matcher.flush())
+				if err != nil && cmd.Context().Err() == nil {
+					fail(exitError, err)
+				}
+				return
+			}
+		}
+	},
+}
+
+func init() {
+	addClientFlags(watchFileCmd)
+	watchFileCmd.Flags().StringArray("match", nil, "Regular expression to match (repeatable, one rule each)")
+	watchFileCmd.Flags().StringArray("title", nil, "Notification title, once or once per --match")
+	watchFileCmd.Flags().StringArray("sound", nil, "Notification sound, once or once per --match")
+	watchFileCmd.Flags().Int("context", 0, "Number of lines before each match to include")
+	watchFileCmd.Flags().Duration("batch-window", 10*time.Second, "Send matches for a rule within this window together (0 sends each match)")
+	watchFileCmd.Flags().Int("max-lines", 20, "Maximum number of lines in a notification body")
+	watchFileCmd.Flags().Bool("from-start", false, "Read the existing contents of the file instead of only new lines")
+	watchFileCmd.Flags().Duration("poll-interval", time.Second, "How often to check the file for changes")
+	watchCmd.AddCommand(watchFileCmd)
+	rootCmd.AddCommand(watchCmd)
+}
@@ -0,0 +1,194 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/techulus/push-cli/internal/api"
+	"github.com/techulus/push-cli/internal/proc"
+)
+
+type processExit struct {
+	proc.Process
+	ended time.Time
+	state proc.State
+}
+
+func (e processExit) summary() string {
+	s := fmt.Sprintf("%s (PID %d) exited after %s", e.Name, e.PID, formatDuration(e.ended.Sub(e.Started)))
+	if e.state.ExitKnown {
+		s += fmt.Sprintf(" with exit code %d", e.state.ExitCode)
+	}
+	return s
+}
+
+// processNotification fills in the title and body for exits, keeping any
+// --title and placing any --body above the summary.
+func processNotification(req api.NotifyRequest, prefix string, exits []processExit) api.NotifyRequest {
+	if req.Title == "" {
+		if len(exits) == 1 {
+			req.Title = exits[0].Name + " exited"
+		} else {
+			req.Title = fmt.Sprintf("%d processes exited", len(exits))
+		}
+	}
+
+	lines := make([]string, len(exits))
+	for i, e := range exits {
+		lines[i] = e.summary()
+	}
+	req.Body = strings.Join(lines, "\n")
+	if prefix != "" {
+		req.Body = prefix + "\n\n" + req.Body
+	}
+	return req
+}
+
+// waitForExits polls procs until each has exited, calling onExit as they
+// go. It returns early if ctx is canceled.
+func waitForExits(ctx context.Context, procs []proc.Process, interval time.Duration, onExit func(processExit)) error {
+	running := append([]proc.Process(nil), procs...)
+	for len(running) > 0 {
+		remaining := running[:0]
+		for _, p := range running {
+			state, err := proc.Poll(p)
+			if err != nil {
+				return err
+			}
+			if state.Running {
+				remaining = append(remaining, p)
+				continue
+			}
+			onExit(processExit{Process: p, ended: time.Now(), state: state})
+		}
+		running = remaining
+		if len(running) == 0 {
+			break
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(interval):
+		}
+	}
+	return nil
+}
+
+func runProcessWatch(cmd *cobra.Command, procs []proc.Process) {
+	interval, _ := cmd.Flags().GetDuration("poll-interval")
+	if interval <= 0 {
+		fail(exitValidation, errors.New("--poll-interval must be positive"))
+	}
+	each, _ := cmd.Flags().GetBool("each")
+	prefix, _ := cmd.Flags().GetString("body")
+
+	req, err := notifyRequestWithBody(cmd, prefix)
+	if err != nil {
+		fail(exitValidation, err)
+	}
+	client := newAPIClient(cmd)
+
+	send := func(exits []processExit) {
+		resp, err := client.NotifyContext(cmd.Context(), processNotification(req, prefix, exits))
+		if err != nil {
+			exitWithError(err)
+		}
+		printResponse(resp)
+	}
+
+	if outputFormat == outputText {
+		names := make([]string, len(procs))
+		for i, p := range procs {
+			names[i] = fmt.Sprintf("%s (%d)", p.Name, p.PID)
+		}
+		fmt.Fprintf(os.Stderr, "Waiting for %s\n", strings.Join(names, ", "))
+	}
+
+	var exits []processExit
+	err = waitForExits(cmd.Context(), procs, interval, func(e processExit) {
+		if each {
+			send([]processExit{e})
+			return
+		}
+		exits = append(exits, e)
+	})
+	if err != nil {
+		exitWithError(err)
+	}
+	if !each {
+		send(exits)
+	}
+}
+
+var watchPidCmd = &cobra.Command{
+	Use:   "pid <pid> [pid...]",
+	Short: "Notify when running processes exit",
+	Long: `Notify when already-running processes exit.
+
+Use this when a job is already running and you want to know when it's done.
+The notification names each process and how long it ran. The exit code is
+included when it can be read, which is usually only for processes started by
+the same user on Linux, before their parent has collected it.
+
+With several PIDs, one notification is sent once all of them have exited,
+or one per process with --each.`,
+	Args: cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		var procs []proc.Process
+		for _, arg := range args {
+			pid, err := strconv.Atoi(arg)
+			if err != nil || pid <= 0 {
+				fail(exitValidation, fmt.Errorf("invalid PID %q", arg))
+			}
+			p, err := proc.Get(pid)
+			if errors.Is(err, proc.ErrNotFound) {
+				fail(exitValidation, fmt.Errorf("no running process with PID %d", pid))
+			}
+			if err != nil {
+				fail(exitError, err)
+			}
+			procs = append(procs, p)
+		}
+		runProcessWatch(cmd, procs)
+	},
+}
+
+var watchCmdCmd = &cobra.Command{
+	Use:   "cmd <name>",
+	Short: "Notify when running processes with a given name exit",
+	Long: `Notify when running processes with a given name exit.
+
+The name is matched exactly against the executable name, as shown by ps.
+Every matching process is watched; see push watch pid for the details.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		procs, err := proc.FindByName(args[0])
+		if err != nil {
+			fail(exitError, err)
+		}
+		if len(procs) == 0 {
+			fail(exitValidation, fmt.Errorf("no running process named %q", args[0]))
+		}
+		runProcessWatch(cmd, procs)
+	},
+}
+
+func init() {
+	for _, c := range []*cobra.Command{watchPidCmd, watchCmdCmd} {
+		addNotifyFlags(c)
+		addClientFlags(c)
+		c.Flags().SetAnnotation("title", cobra.BashCompOneRequiredFlag, []string{"false"})
+		c.Flags().Lookup("title").Usage = "Notification title (default: \"<name> exited\")"
+		c.Flags().Lookup("body").Usage = "Text placed above the exit summary"
+		c.Flags().Duration("poll-interval", time.Second, "How often to check whether the processes are running")
+		c.Flags().Bool("each", false, "Notify as each process exits instead of once all have")
+		watchCmd.AddCommand(c)
+	}
+}
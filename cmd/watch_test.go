@@ -0,0 +1,167 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/techulus/push-cli/internal/api"
+	"github.com/techulus/push-cli/internal/proc"
+)
+
+func testRules() []watchRule {
+	return []watchRule{
+		{re: regexp.MustCompile(`panic:`), title: "Panic", sound: "fail"},
+		{re: regexp.MustCompile(`ERROR`), title: "Error"},
+	}
+}
+
+func TestLineMatcher_SendsEachMatchWithoutWindow(t *testing.T) {
+	m := newLineMatcher(testRules(), 0, 0, 10)
+	now := time.Now()
+
+	if reqs := m.line("INFO ok", now); len(reqs) != 0 {
+		t.Fatalf("expected no notification, got %v", reqs)
+	}
+	reqs := m.line("ERROR panic: boom", now)
+	if len(reqs) != 1 {
+		t.Fatalf("expected 1 notification, got %d", len(reqs))
+	}
+	// The first matching rule wins.
+	if reqs[0].Title != "Panic" || reqs[0].Sound != "fail" || reqs[0].Body != "ERROR panic: boom" {
+		t.Errorf("unexpected notification: %+v", reqs[0])
+	}
+}
+
+func TestLineMatcher_BatchesWithinWindow(t *testing.T) {
+	m := newLineMatcher(testRules(), 0, 10*time.Second, 10)
+	start := time.Now()
+
+	m.line("ERROR one", start)
+	m.line("ERROR two", start.Add(time.Second))
+	m.line("panic: three", start.Add(2*time.Second))
+
+	if reqs := m.due(start.Add(5 * time.Second)); len(reqs) != 0 {
+		t.Fatalf("expected nothing due yet, got %v", reqs)
+	}
+	reqs := m.due(start.Add(10 * time.Second))
+	if len(reqs) != 1 {
+		t.Fatalf("expected 1 due notification, got %d", len(reqs))
+	}
+	if reqs[0].Title != "Error" || reqs[0].Body != "2 matching lines\nERROR one\nERROR two" {
+		t.Errorf("unexpected batch: %+v", reqs[0])
+	}
+
+	reqs = m.flush()
+	if len(reqs) != 1 || reqs[0].Title != "Panic" {
+		t.Errorf("expected flush to return the panic batch, got %v", reqs)
+	}
+	if reqs := m.flush(); len(reqs) != 0 {
+		t.Errorf("expected nothing left after flush, got %v", reqs)
+	}
+}
+
+func TestLineMatcher_Context(t *testing.T) {
+	m := newLineMatcher(testRules(), 2, time.Minute, 0)
+	now := time.Now()
+
+	for _, line := range []string{"a", "b", "c", "ERROR first", "d", "ERROR second", "e", "f", "g", "ERROR third"} {
+		m.line(line, now)
+	}
+
+	reqs := m.flush()
+	want := "3 matching lines\nb\nc\nERROR first\nd\nERROR second\n--\nf\ng\nERROR third"
+	if len(reqs) != 1 || reqs[0].Body != want {
+		t.Errorf("expected body %q, got %v", want, reqs)
+	}
+}
+
+func TestLineMatcher_MaxLines(t *testing.T) {
+	m := newLineMatcher(testRules(), 0, time.Minute, 2)
+	now := time.Now()
+	for i := 0; i < 4; i++ {
+		m.line("ERROR x", now)
+	}
+
+	reqs := m.flush()
+	want := "4 matching lines\nERROR x\nERROR x\n... 2 more line(s)"
+	if len(reqs) != 1 || reqs[0].Body != want {
+		t.Errorf("expected body %q, got %v", want, reqs)
+	}
+}
+
+func TestProcessNotification(t *testing.T) {
+	started := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	backup := processExit{
+		Process: proc.Process{PID: 42, Name: "backup", Started: started},
+		ended:   started.Add(90 * time.Minute),
+		state:   proc.State{ExitCode: 1, ExitKnown: true},
+	}
+	rsync := processExit{
+		Process: proc.Process{PID: 43, Name: "rsync", Started: started},
+		ended:   started.Add(5 * time.Second),
+	}
+
+	req := processNotification(api.NotifyRequest{}, "", []processExit{backup})
+	if req.Title != "backup exited" || req.Body != "backup (PID 42) exited after 1h30m0s with exit code 1" {
+		t.Errorf("unexpected notification: %+v", req)
+	}
+
+	req = processNotification(api.NotifyRequest{Title: "Done"}, "Nightly jobs", []processExit{backup, rsync})
+	want := "Nightly jobs\n\nbackup (PID 42) exited after 1h30m0s with exit code 1\nrsync (PID 43) exited after 5s"
+	if req.Title != "Done" || req.Body != want {
+		t.Errorf("unexpected notification: %+v", req)
+	}
+
+	req = processNotification(api.NotifyRequest{}, "", []processExit{backup, rsync})
+	if req.Title != "2 processes exited" {
+		t.Errorf("unexpected title %q", req.Title)
+	}
+}
+
+func TestWaitForExits(t *testing.T) {
+	short := exec.Command("sleep", "0.05")
+	long := exec.Command("sleep", "0.2")
+	for _, c := range []*exec.Cmd{short, long} {
+		if err := c.Start(); err != nil {
+			t.Skipf("cannot start sleep: %v", err)
+		}
+		go c.Wait()
+	}
+
+	var procs []proc.Process
+	for _, c := range []*exec.Cmd{short, long} {
+		p, err := proc.Get(c.Process.Pid)
+		if err != nil {
+			t.Fatalf("proc.Get() error: %v", err)
+		}
+		procs = append(procs, p)
+	}
+
+	var order []int
+	err := waitForExits(context.Background(), procs, 10*time.Millisecond, func(e processExit) {
+		order = append(order, e.PID)
+	})
+	if err != nil {
+		t.Fatalf("waitForExits() error: %v", err)
+	}
+	if len(order) != 2 || order[0] != short.Process.Pid || order[1] != long.Process.Pid {
+		t.Errorf("expected exits in order [%d %d], got %v", short.Process.Pid, long.Process.Pid, order)
+	}
+}
+
+func TestWaitForExits_Canceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	self, err := proc.Get(os.Getpid())
+	if err != nil {
+		t.Fatalf("proc.Get() error: %v", err)
+	}
+	if err := waitForExits(ctx, []proc.Process{self}, time.Millisecond, func(processExit) {}); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context.Canceled, got %v", err)
+	}
+}
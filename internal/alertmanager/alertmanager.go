@@ -0,0 +1,275 @@
+package alertmanager
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"github.com/techulus/push-cli/internal/api"
+)
+
+const maxRequestBody = 4 << 20
+
+const (
+	StatusFiring   = "firing"
+	StatusResolved = "resolved"
+)
+
+// Message is the body of an Alertmanager webhook (version 4). It is also the
+// data passed to the title and body templates, so templates can use the same
+// fields as Alertmanager's own notification templates.
+type Message struct {
+	Version           string            `json:"version"`
+	GroupKey          string            `json:"groupKey"`
+	TruncatedAlerts   int               `json:"truncatedAlerts"`
+	Status            string            `json:"status"`
+	Receiver          string            `json:"receiver"`
+	GroupLabels       map[string]string `json:"groupLabels"`
+	CommonLabels      map[string]string `json:"commonLabels"`
+	CommonAnnotations map[string]string `json:"commonAnnotations"`
+	ExternalURL       string            `json:"externalURL"`
+	Alerts            []Alert           `json:"alerts"`
+}
+
+type Alert struct {
+	Status       string            `json:"status"`
+	Labels       map[string]string `json:"labels"`
+	Annotations  map[string]string `json:"annotations"`
+	StartsAt     time.Time         `json:"startsAt"`
+	EndsAt       time.Time         `json:"endsAt"`
+	GeneratorURL string            `json:"generatorURL"`
+	Fingerprint  string            `json:"fingerprint"`
+}
+
+// Severity controls how alerts of one severity are delivered.
+type Severity struct {
+	Sound         string
+	TimeSensitive bool
+}
+
+// Notification is one notification built from a webhook. GroupID is set when
+// it should go to a Push group rather than the API key's devices.
+type Notification struct {
+	GroupID string
+	Request api.NotifyRequest
+}
+
+type Options struct {
+	Title *template.Template
+	Body  *template.Template
+
+	// SeverityLabel names the alert label looked up in Severities.
+	SeverityLabel string
+	Severities    map[string]Severity
+	ResolvedSound string
+
+	// GroupLabel names a label whose value is the Push group ID to notify.
+	// Group is used when the label is missing.
+	GroupLabel string
+	Group      string
+}
+
+// Build turns a webhook into one notification for its firing alerts and one
+// for its resolved alerts, skipping whichever is empty. With a GroupLabel,
+// each of those is split further by the Push group its alerts route to.
+func Build(msg Message, opts Options) ([]Notification, error) {
+	var notifications []Notification
+	for _, status := range []string{StatusFiring, StatusResolved} {
+		var groupIDs []string
+		byGroup := map[string][]Alert{}
+		for _, alert := range msg.Alerts {
+			if alert.Status != status {
+				continue
+			}
+			id := alertGroup(alert, opts)
+			if _, ok := byGroup[id]; !ok {
+				groupIDs = append(groupIDs, id)
+			}
+			byGroup[id] = append(byGroup[id], alert)
+		}
+
+		for _, id := range groupIDs {
+			group := msg
+			group.Status = status
+			group.Alerts = byGroup[id]
+			n, err := buildNotification(group, opts)
+			if err != nil {
+				return nil, err
+			}
+			n.GroupID = id
+			notifications = append(notifications, n)
+		}
+	}
+	return notifications, nil
+}
+
+// alertGroup returns the Push group an alert routes to.
+func alertGroup(alert Alert, opts Options) string {
+	if opts.GroupLabel == "" {
+		return opts.Group
+	}
+	if id := alert.Labels[opts.GroupLabel]; id != "" {
+		return id
+	}
+	return opts.Group
+}
+
+func buildNotification(msg Message, opts Options) (Notification, error) {
+	title, err := render(opts.Title, msg)
+	if err != nil {
+		return Notification{}, err
+	}
+	body, err := render(opts.Body, msg)
+	if err != nil {
+		return Notification{}, err
+	}
+	if title == "" {
+		title = "Alertmanager " + msg.Status
+	}
+	if body == "" {
+		body = fmt.Sprintf("%d alert(s) %s", len(msg.Alerts), msg.Status)
+	}
+
+	req := api.NotifyRequest{Title: title, Body: body, Link: msg.ExternalURL}
+	if msg.Status == StatusResolved {
+		req.Sound = opts.ResolvedSound
+	} else if sev, ok := opts.Severities[label(msg, opts.SeverityLabel)]; ok {
+		req.Sound = sev.Sound
+		req.TimeSensitive = sev.TimeSensitive
+	}
+	return Notification{Request: req}, nil
+}
+
+// label returns name from the labels shared by every alert in msg, falling
+// back to the first alert so a mixed-severity group still maps somewhere.
+func label(msg Message, name string) string {
+	if name == "" {
+		return ""
+	}
+	if v := msg.CommonLabels[name]; v != "" {
+		return v
+	}
+	if v := msg.GroupLabels[name]; v != "" {
+		return v
+	}
+	if len(msg.Alerts) > 0 {
+		return msg.Alerts[0].Labels[name]
+	}
+	return ""
+}
+
+func render(tmpl *template.Template, msg Message) (string, error) {
+	if tmpl == nil {
+		return "", nil
+	}
+	var b strings.Builder
+	if err := tmpl.Execute(&b, msg); err != nil {
+		return "", fmt.Errorf("rendering %s template: %w", tmpl.Name(), err)
+	}
+	return strings.TrimSpace(b.String()), nil
+}
+
+type SendFunc func(ctx context.Context, n Notification) error
+
+// deliveredTTL is how long a notification sent for a webhook that failed
+// overall is remembered, so Alertmanager's retries don't send it again.
+const deliveredTTL = time.Hour
+
+// Handler receives Alertmanager webhooks. Notifications are sent before the
+// response is written, so a failed delivery answers 502 and Alertmanager
+// retries the whole group. Notifications that did go out are remembered and
+// skipped on the retry.
+func Handler(send SendFunc, opts Options, token string, logger *slog.Logger) http.Handler {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	var mu sync.Mutex
+	delivered := map[string]time.Time{}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			http.Error(w, "use POST", http.StatusMethodNotAllowed)
+			return
+		}
+		if token != "" && subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), []byte("Bearer "+token)) != 1 {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		var msg Message
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxRequestBody)).Decode(&msg); err != nil {
+			http.Error(w, "invalid JSON: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		if msg.Version != "4" {
+			http.Error(w, fmt.Sprintf("unsupported webhook version %q, expected 4", msg.Version), http.StatusBadRequest)
+			return
+		}
+
+		notifications, err := Build(msg, opts)
+		if err != nil {
+			logger.Error("building notification failed", "group_key", msg.GroupKey, "error", err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		mu.Lock()
+		for key, at := range delivered {
+			if time.Since(at) > deliveredTTL {
+				delete(delivered, key)
+			}
+		}
+		mu.Unlock()
+
+		var keys []string
+		var sendErr error
+		for _, n := range notifications {
+			log := logger.With("group_key", msg.GroupKey, "title", n.Request.Title)
+			if n.GroupID != "" {
+				log = log.With("group", n.GroupID)
+			}
+			key := deliveryKey(msg.GroupKey, n)
+			keys = append(keys, key)
+			mu.Lock()
+			_, done := delivered[key]
+			mu.Unlock()
+			if done {
+				log.Info("notification already sent, skipping")
+				continue
+			}
+			if err := send(r.Context(), n); err != nil {
+				log.Error("notification failed", "error", err)
+				sendErr = err
+				continue
+			}
+			mu.Lock()
+			delivered[key] = time.Now()
+			mu.Unlock()
+			log.Info("notification sent")
+		}
+		if sendErr != nil {
+			http.Error(w, sendErr.Error(), http.StatusBadGateway)
+			return
+		}
+
+		// Everything went out, so a later webhook with the same content is
+		// a repeat notification and must be sent again.
+		mu.Lock()
+		for _, key := range keys {
+			delete(delivered, key)
+		}
+		mu.Unlock()
+		w.WriteHeader(http.StatusOK)
+	})
+}
+
+func deliveryKey(groupKey string, n Notification) string {
+	return strings.Join([]string{groupKey, n.GroupID, n.Request.Title, n.Request.Body}, "\x00")
+}
@@ -0,0 +1,211 @@
+package alertmanager
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"text/template"
+)
+
+func loadFixture(t *testing.T, name string) []byte {
+	t.Helper()
+	raw, err := os.ReadFile("testdata/" + name)
+	if err != nil {
+		t.Fatalf("reading fixture: %v", err)
+	}
+	return raw
+}
+
+func testOptions() Options {
+	funcs := template.FuncMap{"upper": strings.ToUpper}
+	return Options{
+		Title: template.Must(template.New("title").Funcs(funcs).Parse(`[{{.Status | upper}}] {{.CommonLabels.alertname}}`)),
+		Body: template.Must(template.New("body").Option("missingkey=zero").Parse(
+			`{{range .Alerts}}{{or .Annotations.summary .Annotations.description}}
+{{end}}`)),
+		SeverityLabel: "severity",
+		Severities: map[string]Severity{
+			"critical": {Sound: "fail", TimeSensitive: true},
+			"warning":  {Sound: "doorbell"},
+		},
+		ResolvedSound: "correct",
+	}
+}
+
+func TestBuild_Firing(t *testing.T) {
+	var msg Message
+	if err := json.Unmarshal(loadFixture(t, "firing.json"), &msg); err != nil {
+		t.Fatalf("decoding fixture: %v", err)
+	}
+
+	notifications, err := Build(msg, testOptions())
+	if err != nil {
+		t.Fatalf("Build() error: %v", err)
+	}
+	if len(notifications) != 1 {
+		t.Fatalf("expected 1 notification, got %d", len(notifications))
+	}
+
+	req := notifications[0].Request
+	if req.Title != "[FIRING] HighLatency" {
+		t.Errorf("unexpected title %q", req.Title)
+	}
+	if req.Body != "p99 latency above 2s on api-1\np99 latency above 2s on api-2" {
+		t.Errorf("unexpected body %q", req.Body)
+	}
+	if req.Sound != "fail" || !req.TimeSensitive {
+		t.Errorf("expected critical severity mapping, got sound=%q timeSensitive=%v", req.Sound, req.TimeSensitive)
+	}
+	if req.Link != "http://alertmanager.example.com:9093" {
+		t.Errorf("expected external URL as link, got %q", req.Link)
+	}
+	if notifications[0].GroupID != "" {
+		t.Errorf("expected no group, got %q", notifications[0].GroupID)
+	}
+}
+
+func TestBuild_SplitsFiringAndResolved(t *testing.T) {
+	var msg Message
+	if err := json.Unmarshal(loadFixture(t, "mixed.json"), &msg); err != nil {
+		t.Fatalf("decoding fixture: %v", err)
+	}
+
+	notifications, err := Build(msg, testOptions())
+	if err != nil {
+		t.Fatalf("Build() error: %v", err)
+	}
+	if len(notifications) != 2 {
+		t.Fatalf("expected 2 notifications, got %d", len(notifications))
+	}
+
+	firing, resolved := notifications[0].Request, notifications[1].Request
+	if firing.Title != "[FIRING] DiskFull" || firing.Body != "/var is 91% full on db-1" || firing.Sound != "doorbell" || firing.TimeSensitive {
+		t.Errorf("unexpected firing notification: %+v", firing)
+	}
+	if resolved.Title != "[RESOLVED] DiskFull" || resolved.Body != "/var is 90% full on db-2" || resolved.Sound != "correct" {
+		t.Errorf("unexpected resolved notification: %+v", resolved)
+	}
+}
+
+func TestBuild_GroupRouting(t *testing.T) {
+	var msg Message
+	if err := json.Unmarshal(loadFixture(t, "firing.json"), &msg); err != nil {
+		t.Fatalf("decoding fixture: %v", err)
+	}
+
+	opts := testOptions()
+	opts.Group = "fallback"
+	opts.GroupLabel = "team"
+	notifications, _ := Build(msg, opts)
+	if notifications[0].GroupID != "ops-group" {
+		t.Errorf("expected group from label, got %q", notifications[0].GroupID)
+	}
+
+	opts.GroupLabel = "missing"
+	notifications, _ = Build(msg, opts)
+	if notifications[0].GroupID != "fallback" {
+		t.Errorf("expected fallback group, got %q", notifications[0].GroupID)
+	}
+
+	// Alerts with different values for the label go to their own groups.
+	msg.Alerts[1].Labels = map[string]string{"team": "db-group"}
+	delete(msg.CommonLabels, "team")
+	opts.GroupLabel = "team"
+	notifications, _ = Build(msg, opts)
+	if len(notifications) != 2 || notifications[0].GroupID != "ops-group" || notifications[1].GroupID != "db-group" {
+		t.Fatalf("expected one notification per group, got %+v", notifications)
+	}
+	if notifications[1].Request.Body != "p99 latency above 2s on api-2" {
+		t.Errorf("expected only the db alert in its notification, got %q", notifications[1].Request.Body)
+	}
+}
+
+func TestHandler(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	tests := []struct {
+		name    string
+		body    []byte
+		token   string
+		auth    string
+		sendErr error
+		want    int
+		sent    int
+	}{
+		{"firing", loadFixture(t, "firing.json"), "", "", nil, http.StatusOK, 1},
+		{"mixed", loadFixture(t, "mixed.json"), "", "", nil, http.StatusOK, 2},
+		{"invalid JSON", []byte("nope"), "", "", nil, http.StatusBadRequest, 0},
+		{"wrong version", []byte(`{"version":"3"}`), "", "", nil, http.StatusBadRequest, 0},
+		{"missing token", loadFixture(t, "firing.json"), "secret", "", nil, http.StatusUnauthorized, 0},
+		{"valid token", loadFixture(t, "firing.json"), "secret", "Bearer secret", nil, http.StatusOK, 1},
+		{"send failure", loadFixture(t, "firing.json"), "", "", errors.New("boom"), http.StatusBadGateway, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var sent []Notification
+			handler := Handler(func(ctx context.Context, n Notification) error {
+				sent = append(sent, n)
+				return tt.sendErr
+			}, testOptions(), tt.token, logger)
+
+			req := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(tt.body))
+			if tt.auth != "" {
+				req.Header.Set("Authorization", tt.auth)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != tt.want {
+				t.Errorf("expected status %d, got %d: %s", tt.want, rec.Code, rec.Body.String())
+			}
+			if len(sent) != tt.sent {
+				t.Errorf("expected %d notification(s) sent, got %d", tt.sent, len(sent))
+			}
+		})
+	}
+}
+
+func TestHandler_SkipsDeliveredOnRetry(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	var sent []string
+	failResolved := true
+	handler := Handler(func(ctx context.Context, n Notification) error {
+		if failResolved && strings.HasPrefix(n.Request.Title, "[RESOLVED]") {
+			return errors.New("boom")
+		}
+		sent = append(sent, n.Request.Title)
+		return nil
+	}, testOptions(), "", logger)
+
+	post := func() int {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(loadFixture(t, "mixed.json"))))
+		return rec.Code
+	}
+
+	if code := post(); code != http.StatusBadGateway {
+		t.Fatalf("expected 502 when a notification fails, got %d", code)
+	}
+	failResolved = false
+	if code := post(); code != http.StatusOK {
+		t.Fatalf("expected 200 on retry, got %d", code)
+	}
+	if strings.Join(sent, ",") != "[FIRING] DiskFull,[RESOLVED] DiskFull" {
+		t.Errorf("expected the retry to send only the failed notification, got %v", sent)
+	}
+
+	// Once the whole webhook went out, a repeat of it is sent again.
+	post()
+	if len(sent) != 4 {
+		t.Errorf("expected a repeated webhook to be sent in full, got %v", sent)
+	}
+}
@@ -2,15 +2,22 @@ package api
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
+	"math/rand"
 	"net/http"
+	"net/http/httptrace"
 	"net/url"
+	"strconv"
+	"strings"
 	"time"
 )
 
-var baseURL = "https://push.techulus.com/api/v1"
+const DefaultBaseURL = "https://push.techulus.com/api/v1"
+
+var baseURL = DefaultBaseURL
 
 type NotifyRequest struct {
 	Title         string `json:"title"`
@@ -22,58 +29,264 @@ type NotifyRequest struct {
 	TimeSensitive bool   `json:"timeSensitive,omitempty"`
 }
 
+const (
+	defaultRetryBaseDelay = 500 * time.Millisecond
+	defaultRetryMaxWait   = 30 * time.Second
+)
+
+// RetryPolicy controls how failed requests are retried. Only failures that
+// can't have delivered the notification are retried: connection errors
+// before the request was written, 429, 502, 503 and 504. A 500 or an error
+// after the request went out may mean it was processed, so a retry could
+// send it twice.
+type RetryPolicy struct {
+	MaxRetries int
+	MaxWait    time.Duration
+	BaseDelay  time.Duration
+}
+
 type Client struct {
 	apiKey     string
+	baseURL    string
 	httpClient *http.Client
+	retry      RetryPolicy
+	sleep      func(context.Context, time.Duration) error
+}
+
+type Option func(*Client)
+
+func ValidateBaseURL(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return fmt.Errorf("invalid base URL %q: %w", raw, err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("invalid base URL %q: scheme must be http or https", raw)
+	}
+	if u.Host == "" {
+		return fmt.Errorf("invalid base URL %q: missing host", raw)
+	}
+	if u.RawQuery != "" || u.Fragment != "" {
+		return fmt.Errorf("invalid base URL %q: query strings and fragments are not allowed", raw)
+	}
+	return nil
+}
+
+func WithBaseURL(u string) Option {
+	return func(c *Client) {
+		c.baseURL = strings.TrimRight(u, "/")
+	}
+}
+
+func WithRetryPolicy(p RetryPolicy) Option {
+	return func(c *Client) {
+		c.retry = p
+	}
+}
+
+// WithTimeout limits how long a single request attempt may take, including
+// reading the response. It replaces the 30 second default.
+func WithTimeout(d time.Duration) Option {
+	return func(c *Client) {
+		c.httpClient.Timeout = d
+	}
 }
 
-func NewClient(apiKey string) *Client {
-	return &Client{
+func NewClient(apiKey string, opts ...Option) *Client {
+	c := &Client{
 		apiKey:     apiKey,
+		baseURL:    baseURL,
 		httpClient: &http.Client{Timeout: 30 * time.Second},
+		sleep:      sleepContext,
 	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
 }
 
-func (c *Client) Notify(req NotifyRequest) (string, error) {
-	return c.post("/notify", req)
+func (c *Client) Notify(req NotifyRequest) (*NotifyResponse, error) {
+	return c.NotifyContext(context.Background(), req)
 }
 
-func (c *Client) NotifyAsync(req NotifyRequest) (string, error) {
-	return c.post("/notify-async", req)
+func (c *Client) NotifyAsync(req NotifyRequest) (*NotifyResponse, error) {
+	return c.NotifyAsyncContext(context.Background(), req)
 }
 
-func (c *Client) NotifyGroup(groupID string, req NotifyRequest) (string, error) {
-	return c.post("/notify/group/"+url.PathEscape(groupID), req)
+func (c *Client) NotifyGroup(groupID string, req NotifyRequest) (*NotifyResponse, error) {
+	return c.NotifyGroupContext(context.Background(), groupID, req)
 }
 
-func (c *Client) post(path string, payload interface{}) (string, error) {
-	body, err := json.Marshal(payload)
+func (c *Client) NotifyContext(ctx context.Context, req NotifyRequest) (*NotifyResponse, error) {
+	return c.notify(ctx, "/notify", req)
+}
+
+func (c *Client) NotifyAsyncContext(ctx context.Context, req NotifyRequest) (*NotifyResponse, error) {
+	return c.notify(ctx, "/notify-async", req)
+}
+
+func (c *Client) NotifyGroupContext(ctx context.Context, groupID string, req NotifyRequest) (*NotifyResponse, error) {
+	return c.notify(ctx, "/notify/group/"+url.PathEscape(groupID), req)
+}
+
+func (c *Client) notify(ctx context.Context, path string, req NotifyRequest) (*NotifyResponse, error) {
+	resp, err := c.post(ctx, path, req)
 	if err != nil {
-		return "", fmt.Errorf("marshaling request: %w", err)
+		return nil, err
 	}
+	return parseNotifyResponse(resp)
+}
+
+type response struct {
+	status int
+	header http.Header
+	body   []byte
+}
 
-	req, err := http.NewRequest("POST", baseURL+path, bytes.NewReader(body))
+func (c *Client) post(ctx context.Context, path string, payload interface{}) (*response, error) {
+	body, err := json.Marshal(payload)
 	if err != nil {
-		return "", fmt.Errorf("creating request: %w", err)
+		return nil, fmt.Errorf("marshaling request: %w", err)
 	}
 
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("x-api-key", c.apiKey)
+	for attempt := 0; ; attempt++ {
+		req, err := http.NewRequestWithContext(ctx, "POST", c.baseURL+path, bytes.NewReader(body))
+		if err != nil {
+			return nil, fmt.Errorf("creating request: %w", err)
+		}
 
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("x-api-key", c.apiKey)
+
+		written := false
+		req = req.WithContext(httptrace.WithClientTrace(ctx, &httptrace.ClientTrace{
+			WroteRequest: func(info httptrace.WroteRequestInfo) { written = info.Err == nil },
+		}))
+
+		resp, err := c.do(req)
+		if err == nil && resp.status >= 200 && resp.status < 300 {
+			return resp, nil
+		}
+
+		wait, retry := c.retryDelay(attempt, resp, written)
+		if !retry || ctx.Err() != nil {
+			if err != nil {
+				return nil, err
+			}
+			return nil, newAPIError(resp.status, resp.body)
+		}
+		if err := c.sleep(ctx, wait); err != nil {
+			return nil, fmt.Errorf("waiting to retry: %w", err)
+		}
+	}
+}
+
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+func (c *Client) do(req *http.Request) (*response, error) {
 	resp, err := c.httpClient.Do(req)
 	if err != nil {
-		return "", fmt.Errorf("sending request: %w", err)
+		return nil, fmt.Errorf("sending request: %w", err)
 	}
 	defer resp.Body.Close()
 
 	respBody, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return "", fmt.Errorf("reading response: %w", err)
+		return nil, fmt.Errorf("reading response: %w", err)
 	}
 
-	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-		return "", fmt.Errorf("API error (HTTP %d): %s", resp.StatusCode, string(respBody))
+	return &response{status: resp.StatusCode, header: resp.Header, body: respBody}, nil
+}
+
+// retryDelay reports whether a failed attempt should be retried and how long
+// to wait first. A nil response means the request never got an answer;
+// written tells whether it was sent in full before that.
+func (c *Client) retryDelay(attempt int, resp *response, written bool) (time.Duration, bool) {
+	if attempt >= c.retry.MaxRetries {
+		return 0, false
+	}
+	if resp == nil && written {
+		return 0, false
+	}
+	if resp != nil && !retryableStatus(resp.status) {
+		return 0, false
 	}
 
-	return string(respBody), nil
+	if resp != nil && (resp.status == http.StatusTooManyRequests || resp.status == http.StatusServiceUnavailable) {
+		if wait, ok := parseRetryAfter(resp.header.Get("Retry-After")); ok {
+			// Retrying before the server allows it would only fail again.
+			if wait > c.retry.maxWait() {
+				return 0, false
+			}
+			return wait, true
+		}
+	}
+
+	return c.retry.backoff(attempt), true
+}
+
+// retryableStatus reports whether a response says the request wasn't
+// processed.
+func retryableStatus(status int) bool {
+	switch status {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+func (p RetryPolicy) maxWait() time.Duration {
+	if p.MaxWait <= 0 {
+		return defaultRetryMaxWait
+	}
+	return p.MaxWait
+}
+
+func (p RetryPolicy) backoff(attempt int) time.Duration {
+	delay := p.BaseDelay
+	if delay <= 0 {
+		delay = defaultRetryBaseDelay
+	}
+
+	maxWait := p.maxWait()
+	for i := 0; i < attempt && delay < maxWait; i++ {
+		delay *= 2
+	}
+	if delay > maxWait {
+		delay = maxWait
+	}
+
+	// Jitter between half and the full delay so that many clients failing at
+	// once don't retry in lockstep.
+	half := delay / 2
+	return half + time.Duration(rand.Int63n(int64(delay-half)+1))
+}
+
+func parseRetryAfter(value string) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+	if secs, err := strconv.Atoi(value); err == nil {
+		if secs < 0 {
+			return 0, false
+		}
+		return time.Duration(secs) * time.Second, true
+	}
+	if at, err := http.ParseTime(value); err == nil {
+		wait := time.Until(at)
+		if wait < 0 {
+			wait = 0
+		}
+		return wait, true
+	}
+	return 0, false
 }
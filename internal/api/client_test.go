@@ -1,7 +1,10 @@
 package api
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
+	"io"
 	"net/http"
 	"net/http/httptest"
 	"testing"
@@ -74,8 +77,8 @@ func TestNotify(t *testing.T) {
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
-	if resp != `{"success":true}` {
-		t.Errorf("unexpected response: %s", resp)
+	if !resp.Success || resp.Raw != `{"success":true}` {
+		t.Errorf("unexpected response: %s", resp.Raw)
 	}
 }
 
@@ -208,3 +211,353 @@ func TestOmitEmptyFields(t *testing.T) {
 		t.Fatalf("unexpected error: %v", err)
 	}
 }
+
+func TestWithBaseURL(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v1/notify" {
+			t.Errorf("expected /api/v1/notify, got %s", r.URL.Path)
+		}
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"success":true}`))
+	}))
+	defer server.Close()
+
+	client := NewClient("test-key", WithBaseURL(server.URL+"/api/v1/"))
+	_, err := client.Notify(NotifyRequest{Title: "Test", Body: "Hello"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestValidateBaseURL(t *testing.T) {
+	valid := []string{
+		"https://push.techulus.com/api/v1",
+		"http://localhost:3000/api/v1",
+		"https://push.example.com",
+	}
+	for _, u := range valid {
+		if err := ValidateBaseURL(u); err != nil {
+			t.Errorf("ValidateBaseURL(%q) unexpected error: %v", u, err)
+		}
+	}
+
+	invalid := []string{
+		"",
+		"push.example.com/api/v1",
+		"ftp://push.example.com",
+		"https://",
+		"https://push.example.com/api/v1?debug=1",
+		"https://push.example.com/api/v1#frag",
+		"http://[::1",
+	}
+	for _, u := range invalid {
+		if err := ValidateBaseURL(u); err == nil {
+			t.Errorf("ValidateBaseURL(%q) expected error", u)
+		}
+	}
+}
+
+func newRetryTestClient(t *testing.T, serverURL string, policy RetryPolicy) (*Client, *[]time.Duration) {
+	t.Helper()
+	var waits []time.Duration
+	client := NewClient("test-key", WithBaseURL(serverURL), WithRetryPolicy(policy))
+	client.sleep = func(ctx context.Context, d time.Duration) error {
+		waits = append(waits, d)
+		return nil
+	}
+	return client, &waits
+}
+
+func TestRetry_ServerErrorThenSuccess(t *testing.T) {
+	attempts := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		if attempts < 3 {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"success":true}`))
+	}))
+	defer server.Close()
+
+	client, waits := newRetryTestClient(t, server.URL, RetryPolicy{MaxRetries: 3, BaseDelay: 100 * time.Millisecond, MaxWait: time.Second})
+	resp, err := client.Notify(NotifyRequest{Title: "Test", Body: "Hello"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !resp.Success || resp.Raw != `{"success":true}` {
+		t.Errorf("unexpected response: %s", resp.Raw)
+	}
+	if attempts != 3 {
+		t.Errorf("expected 3 attempts, got %d", attempts)
+	}
+	if len(*waits) != 2 {
+		t.Fatalf("expected 2 waits, got %d", len(*waits))
+	}
+	if w := (*waits)[1]; w < 100*time.Millisecond || w > 200*time.Millisecond {
+		t.Errorf("expected second backoff between 100ms and 200ms, got %v", w)
+	}
+}
+
+func TestRetry_GivesUpAfterMaxRetries(t *testing.T) {
+	attempts := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	client, _ := newRetryTestClient(t, server.URL, RetryPolicy{MaxRetries: 2})
+	_, err := client.Notify(NotifyRequest{Title: "Test", Body: "Hello"})
+	if err == nil {
+		t.Fatal("expected error after exhausting retries")
+	}
+	if attempts != 3 {
+		t.Errorf("expected 3 attempts, got %d", attempts)
+	}
+}
+
+func TestRetry_NoRetryOnClientError(t *testing.T) {
+	attempts := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"success":false,"message":"title is required"}`))
+	}))
+	defer server.Close()
+
+	client, _ := newRetryTestClient(t, server.URL, RetryPolicy{MaxRetries: 3})
+	_, err := client.Notify(NotifyRequest{Body: "Hello"})
+	if err == nil {
+		t.Fatal("expected error for 400 response")
+	}
+	if attempts != 1 {
+		t.Errorf("expected 1 attempt, got %d", attempts)
+	}
+}
+
+func TestRetry_NoRetryOnInternalServerError(t *testing.T) {
+	attempts := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	client, _ := newRetryTestClient(t, server.URL, RetryPolicy{MaxRetries: 3})
+	if _, err := client.Notify(NotifyRequest{Title: "Test", Body: "Hello"}); err == nil {
+		t.Fatal("expected error for 500 response")
+	}
+	if attempts != 1 {
+		t.Errorf("expected 1 attempt, since the server may have sent it, got %d", attempts)
+	}
+}
+
+func TestRetry_NoRetryAfterRequestWritten(t *testing.T) {
+	attempts := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		io.ReadAll(r.Body)
+		// Drop the connection without answering, as a crashing server would.
+		conn, _, _ := w.(http.Hijacker).Hijack()
+		conn.Close()
+	}))
+	defer server.Close()
+
+	client, waits := newRetryTestClient(t, server.URL, RetryPolicy{MaxRetries: 3})
+	if _, err := client.Notify(NotifyRequest{Title: "Test", Body: "Hello"}); err == nil {
+		t.Fatal("expected error when the connection drops")
+	}
+	if attempts != 1 || len(*waits) != 0 {
+		t.Errorf("expected no retry once the request was written, got %d attempts", attempts)
+	}
+}
+
+func TestRetry_HonorsRetryAfter(t *testing.T) {
+	attempts := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		if attempts == 1 {
+			w.Header().Set("Retry-After", "7")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	client, waits := newRetryTestClient(t, server.URL, RetryPolicy{MaxRetries: 1, MaxWait: 10 * time.Second})
+	if _, err := client.Notify(NotifyRequest{Title: "Test", Body: "Hello"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(*waits) != 1 || (*waits)[0] != 7*time.Second {
+		t.Errorf("expected a single 7s wait, got %v", *waits)
+	}
+}
+
+func TestRetry_RetryAfterBeyondMaxWait(t *testing.T) {
+	attempts := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		w.Header().Set("Retry-After", "120")
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	client, _ := newRetryTestClient(t, server.URL, RetryPolicy{MaxRetries: 3, MaxWait: 30 * time.Second})
+	if _, err := client.Notify(NotifyRequest{Title: "Test", Body: "Hello"}); err == nil {
+		t.Fatal("expected error when Retry-After exceeds max wait")
+	}
+	if attempts != 1 {
+		t.Errorf("expected 1 attempt, got %d", attempts)
+	}
+}
+
+func TestRetry_NetworkError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	serverURL := server.URL
+	server.Close()
+
+	client, waits := newRetryTestClient(t, serverURL, RetryPolicy{MaxRetries: 2})
+	if _, err := client.Notify(NotifyRequest{Title: "Test", Body: "Hello"}); err == nil {
+		t.Fatal("expected network error")
+	}
+	if len(*waits) != 2 {
+		t.Errorf("expected 2 waits, got %d", len(*waits))
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	if d, ok := parseRetryAfter("5"); !ok || d != 5*time.Second {
+		t.Errorf("parseRetryAfter(5) = %v, %v", d, ok)
+	}
+	future := time.Now().Add(time.Minute).UTC().Format(http.TimeFormat)
+	if d, ok := parseRetryAfter(future); !ok || d <= 0 || d > time.Minute {
+		t.Errorf("parseRetryAfter(date) = %v, %v", d, ok)
+	}
+	for _, v := range []string{"", "-1", "soon"} {
+		if _, ok := parseRetryAfter(v); ok {
+			t.Errorf("parseRetryAfter(%q) expected not ok", v)
+		}
+	}
+}
+
+func TestWithTimeout(t *testing.T) {
+	client := NewClient("test-key", WithTimeout(5*time.Second))
+	if client.httpClient.Timeout != 5*time.Second {
+		t.Errorf("expected timeout 5s, got %v", client.httpClient.Timeout)
+	}
+}
+
+func TestNotifyContext_Canceled(t *testing.T) {
+	block := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-block
+	}))
+	defer server.Close()
+	defer close(block)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	client := NewClient("test-key", WithBaseURL(server.URL), WithRetryPolicy(RetryPolicy{MaxRetries: 3}))
+	_, err := client.NotifyContext(ctx, NotifyRequest{Title: "Test", Body: "Hello"})
+	if err == nil {
+		t.Fatal("expected error for canceled context")
+	}
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected context.DeadlineExceeded, got %v", err)
+	}
+}
+
+func TestNotifyContext_CanceledDuringBackoff(t *testing.T) {
+	attempts := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer server.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	client := NewClient("test-key", WithBaseURL(server.URL), WithRetryPolicy(RetryPolicy{MaxRetries: 3, BaseDelay: time.Minute}))
+	client.sleep = func(ctx context.Context, d time.Duration) error {
+		cancel()
+		return sleepContext(ctx, d)
+	}
+
+	_, err := client.NotifyContext(ctx, NotifyRequest{Title: "Test", Body: "Hello"})
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context.Canceled, got %v", err)
+	}
+	if attempts != 1 {
+		t.Errorf("expected 1 attempt, got %d", attempts)
+	}
+}
+
+func TestAPIError_ParsesMessage(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+		w.Write([]byte(`{"success":false,"message":"Invalid API key"}`))
+	}))
+	defer server.Close()
+
+	client := NewClient("bad-key", WithBaseURL(server.URL))
+	_, err := client.Notify(NotifyRequest{Title: "Test", Body: "Hello"})
+
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		t.Fatalf("expected *APIError, got %T: %v", err, err)
+	}
+	if apiErr.StatusCode != http.StatusUnauthorized {
+		t.Errorf("expected status 401, got %d", apiErr.StatusCode)
+	}
+	if apiErr.Message != "Invalid API key" {
+		t.Errorf("expected message 'Invalid API key', got %q", apiErr.Message)
+	}
+	if apiErr.Body != `{"success":false,"message":"Invalid API key"}` {
+		t.Errorf("unexpected raw body: %s", apiErr.Body)
+	}
+	if err.Error() != "API error (HTTP 401): Invalid API key" {
+		t.Errorf("unexpected error string: %s", err.Error())
+	}
+	if !IsUnauthorized(err) || IsRateLimited(err) || IsServerError(err) {
+		t.Error("expected only IsUnauthorized to match")
+	}
+}
+
+func TestAPIError_NonJSONBody(t *testing.T) {
+	err := newAPIError(http.StatusBadGateway, []byte("<html>Bad Gateway</html>"))
+	if err.Message != "" {
+		t.Errorf("expected empty message, got %q", err.Message)
+	}
+	if err.Error() != "API error (HTTP 502): <html>Bad Gateway</html>" {
+		t.Errorf("unexpected error string: %s", err.Error())
+	}
+	if !IsServerError(err) {
+		t.Error("expected IsServerError to match")
+	}
+
+	empty := newAPIError(http.StatusTooManyRequests, nil)
+	if empty.Error() != "API error (HTTP 429): Too Many Requests" {
+		t.Errorf("unexpected error string: %s", empty.Error())
+	}
+	if !IsRateLimited(empty) {
+		t.Error("expected IsRateLimited to match")
+	}
+}
+
+func TestIsNetworkError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	serverURL := server.URL
+	server.Close()
+
+	client := NewClient("test-key", WithBaseURL(serverURL))
+	_, err := client.Notify(NotifyRequest{Title: "Test", Body: "Hello"})
+	if !IsNetworkError(err) {
+		t.Errorf("expected network error, got %v", err)
+	}
+	if IsNetworkError(newAPIError(http.StatusBadGateway, nil)) {
+		t.Error("expected APIError not to be a network error")
+	}
+}
@@ -0,0 +1,88 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// NotifyResponse is the decoded body of a successful notify call. Fields the
+// CLI doesn't know about are kept in Extra so they survive a round trip
+// through MarshalJSON.
+type NotifyResponse struct {
+	Success bool
+	Message string
+	ID      string
+	Extra   map[string]json.RawMessage
+	Raw     string
+}
+
+func (r *NotifyResponse) UnmarshalJSON(data []byte) error {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return err
+	}
+
+	r.Success = true
+	r.Extra = map[string]json.RawMessage{}
+	for key, value := range fields {
+		var err error
+		switch key {
+		case "success":
+			err = json.Unmarshal(value, &r.Success)
+		case "message":
+			err = json.Unmarshal(value, &r.Message)
+		case "id", "notificationId":
+			r.ID = rawString(value)
+		default:
+			r.Extra[key] = value
+		}
+		if err != nil {
+			return fmt.Errorf("decoding %q: %w", key, err)
+		}
+	}
+	return nil
+}
+
+func (r NotifyResponse) MarshalJSON() ([]byte, error) {
+	fields := map[string]interface{}{}
+	for key, value := range r.Extra {
+		fields[key] = value
+	}
+	fields["success"] = r.Success
+	if r.Message != "" {
+		fields["message"] = r.Message
+	}
+	if r.ID != "" {
+		fields["id"] = r.ID
+	}
+	return json.Marshal(fields)
+}
+
+// rawString accepts both string and numeric IDs.
+func rawString(value json.RawMessage) string {
+	var s string
+	if json.Unmarshal(value, &s) == nil {
+		return s
+	}
+	return strings.TrimSpace(string(value))
+}
+
+func parseNotifyResponse(resp *response) (*NotifyResponse, error) {
+	body := bytes.TrimSpace(resp.body)
+	if len(body) == 0 || body[0] != '{' {
+		// Not every deployment answers with JSON; a 2xx is still a success.
+		return &NotifyResponse{Success: true, Message: string(body), Raw: string(resp.body)}, nil
+	}
+
+	var parsed NotifyResponse
+	if err := json.Unmarshal(body, &parsed); err != nil {
+		return nil, fmt.Errorf("decoding response: %w", err)
+	}
+	parsed.Raw = string(resp.body)
+	if !parsed.Success {
+		return nil, newAPIError(resp.status, resp.body)
+	}
+	return &parsed, nil
+}
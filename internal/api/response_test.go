@@ -0,0 +1,71 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+)
+
+func TestParseNotifyResponse_KnownAndUnknownFields(t *testing.T) {
+	body := []byte(`{"success":true,"message":"Notification sent","id":"abc123","queued":3}`)
+	resp, err := parseNotifyResponse(&response{status: http.StatusOK, body: body})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !resp.Success {
+		t.Error("expected success to be true")
+	}
+	if resp.Message != "Notification sent" {
+		t.Errorf("expected message, got %q", resp.Message)
+	}
+	if resp.ID != "abc123" {
+		t.Errorf("expected ID abc123, got %q", resp.ID)
+	}
+	if string(resp.Extra["queued"]) != "3" {
+		t.Errorf("expected unknown field queued to be kept, got %s", resp.Extra["queued"])
+	}
+	if resp.Raw != string(body) {
+		t.Errorf("expected raw body to be kept, got %s", resp.Raw)
+	}
+
+	out, err := json.Marshal(resp)
+	if err != nil {
+		t.Fatalf("marshal error: %v", err)
+	}
+	want := `{"id":"abc123","message":"Notification sent","queued":3,"success":true}`
+	if string(out) != want {
+		t.Errorf("MarshalJSON() = %s, want %s", out, want)
+	}
+}
+
+func TestParseNotifyResponse_NumericID(t *testing.T) {
+	resp, err := parseNotifyResponse(&response{status: http.StatusOK, body: []byte(`{"success":true,"notificationId":42}`)})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if resp.ID != "42" {
+		t.Errorf("expected ID 42, got %q", resp.ID)
+	}
+}
+
+func TestParseNotifyResponse_NonJSON(t *testing.T) {
+	for _, body := range []string{"", "OK\n"} {
+		resp, err := parseNotifyResponse(&response{status: http.StatusOK, body: []byte(body)})
+		if err != nil {
+			t.Fatalf("unexpected error for %q: %v", body, err)
+		}
+		if !resp.Success {
+			t.Errorf("expected success for %q", body)
+		}
+	}
+}
+
+func TestParseNotifyResponse_SuccessFalse(t *testing.T) {
+	_, err := parseNotifyResponse(&response{status: http.StatusOK, body: []byte(`{"success":false,"message":"Channel not found"}`)})
+	if err == nil {
+		t.Fatal("expected error when success is false")
+	}
+	if err.Error() != "API error (HTTP 200): Channel not found" {
+		t.Errorf("unexpected error string: %s", err.Error())
+	}
+}
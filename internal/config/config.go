@@ -3,10 +3,15 @@ package config
 import (
 	"errors"
 	"fmt"
+	"io/fs"
 	"os"
 	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
 
 	"github.com/spf13/viper"
+	"github.com/techulus/push-cli/internal/secret"
 )
 
 var osExit = os.Exit
@@ -15,8 +20,74 @@ const (
 	configDir  = "push"
 	configFile = "config"
 	configType = "yaml"
+
+	DefaultProfile = "default"
+
+	APIKeyEnv    = "PUSH_API_KEY"
+	BaseURLEnv   = "PUSH_BASE_URL"
+	RateLimitEnv = "PUSH_RATE_LIMIT"
+)
+
+type Source int
+
+const (
+	SourceNone Source = iota
+	SourceDefault
+	SourceFile
+	SourceProfile
+	SourceEnv
+	SourceFlag
+	SourceCommand
+	SourceKeyring
+	SourceEncrypted
+)
+
+// Where set-key stores the API key.
+const (
+	StoreFile      = "file"
+	StoreKeyring   = "keyring"
+	StoreEncrypted = "encrypted"
+)
+
+func (s Source) String() string {
+	switch s {
+	case SourceDefault:
+		return "default"
+	case SourceFile:
+		return "config file"
+	case SourceProfile:
+		return "profile"
+	case SourceEnv:
+		return "environment"
+	case SourceFlag:
+		return "flag"
+	case SourceCommand:
+		return "api_key_command"
+	case SourceKeyring:
+		return "keyring"
+	case SourceEncrypted:
+		return "encrypted file"
+	}
+	return "not set"
+}
+
+var (
+	profileOverride  string
+	flagOverrides    = map[string]string{}
+	validProfileName = regexp.MustCompile(`^[a-zA-Z0-9_-]+$`)
+
+	// resolvedKeys caches keys read from a backend by profile, so the
+	// command or passphrase prompt runs at most once per invocation.
+	resolvedKeys = map[string]resolvedKey{}
+
+	warnings []string
 )
 
+type resolvedKey struct {
+	key string
+	src Source
+}
+
 func Init() {
 	cfgBase, err := os.UserConfigDir()
 	if err != nil {
@@ -34,30 +105,461 @@ func Init() {
 			fmt.Fprintf(os.Stderr, "Error reading config file: %v\n", err)
 			osExit(1)
 		}
+		return
 	}
+	warnings = checkSettings(viper.AllSettings())
 }
 
-func SetAPIKey(key string) error {
+// Dir returns the directory holding the config file and other local state.
+func Dir() (string, error) {
 	cfgBase, err := os.UserConfigDir()
 	if err != nil {
-		return fmt.Errorf("finding config directory: %w", err)
+		return "", fmt.Errorf("finding config directory: %w", err)
+	}
+	return filepath.Join(cfgBase, configDir), nil
+}
+
+func configPath() (string, error) {
+	dir, err := Dir()
+	if err != nil {
+		return "", err
 	}
+	return filepath.Join(dir, configFile+"."+configType), nil
+}
 
-	dir := filepath.Join(cfgBase, configDir)
-	if err := os.MkdirAll(dir, 0700); err != nil {
+// readFileSettings returns only what is stored in the config file, so that
+// values coming from overrides are never written back to disk.
+func readFileSettings(path string) (map[string]interface{}, error) {
+	v := viper.New()
+	v.SetConfigFile(path)
+	if err := v.ReadInConfig(); err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return map[string]interface{}{}, nil
+		}
+		return nil, fmt.Errorf("reading config file: %w", err)
+	}
+	return v.AllSettings(), nil
+}
+
+func updateConfig(mutate func(settings map[string]interface{}) error) error {
+	path, err := configPath()
+	if err != nil {
+		return err
+	}
+
+	settings, err := readFileSettings(path)
+	if err != nil {
+		return err
+	}
+	if err := mutate(settings); err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
 		return fmt.Errorf("creating config directory: %w", err)
 	}
 
-	viper.Set("api_key", key)
-	configPath := filepath.Join(dir, configFile+".yaml")
-	if err := viper.WriteConfigAs(configPath); err != nil {
+	v := viper.New()
+	if err := v.MergeConfigMap(settings); err != nil {
+		return fmt.Errorf("preparing config: %w", err)
+	}
+	if err := v.WriteConfigAs(path); err != nil {
 		return err
 	}
-	return os.Chmod(configPath, 0600)
+	if err := os.Chmod(path, 0600); err != nil {
+		return err
+	}
+
+	viper.SetConfigFile(path)
+	return viper.ReadInConfig()
+}
+
+func profilesSection(settings map[string]interface{}) map[string]interface{} {
+	profiles, ok := settings["profiles"].(map[string]interface{})
+	if !ok {
+		profiles = map[string]interface{}{}
+		settings["profiles"] = profiles
+	}
+	return profiles
+}
+
+func profileSection(settings map[string]interface{}, name string) map[string]interface{} {
+	profiles := profilesSection(settings)
+	profile, ok := profiles[name].(map[string]interface{})
+	if !ok {
+		profile = map[string]interface{}{}
+		profiles[name] = profile
+	}
+	return profile
+}
+
+func profileKey(name, key string) string {
+	return "profiles." + name + "." + key
+}
+
+func ValidateProfileName(name string) error {
+	if !validProfileName.MatchString(name) {
+		return fmt.Errorf("invalid profile name %q (use letters, digits, '-' and '_')", name)
+	}
+	return nil
+}
+
+// SetProfile selects the profile for this invocation without touching the
+// active profile stored in the config file.
+func SetProfile(name string) {
+	profileOverride = strings.ToLower(name)
+}
+
+func ActiveProfile() string {
+	if profileOverride != "" {
+		return profileOverride
+	}
+	if name := viper.GetString("active_profile"); name != "" {
+		return name
+	}
+	return DefaultProfile
+}
+
+func Profiles() []string {
+	seen := map[string]bool{}
+	for name := range viper.GetStringMap("profiles") {
+		seen[name] = true
+	}
+	if viper.GetString("api_key") != "" {
+		seen[DefaultProfile] = true
+	}
+
+	names := make([]string, 0, len(seen))
+	for name := range seen {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func ProfileExists(name string) bool {
+	for _, p := range Profiles() {
+		if p == name {
+			return true
+		}
+	}
+	return false
+}
+
+func UseProfile(name string) error {
+	name = strings.ToLower(name)
+	if !ProfileExists(name) {
+		return fmt.Errorf("profile %q does not exist", name)
+	}
+	return updateConfig(func(settings map[string]interface{}) error {
+		settings["active_profile"] = name
+		return nil
+	})
+}
+
+func DeleteProfile(name string) error {
+	name = strings.ToLower(name)
+	if !ProfileExists(name) {
+		return fmt.Errorf("profile %q does not exist", name)
+	}
+	forgetStoredKey(name, viper.GetString(profileKey(name, "api_key_store")))
+	return updateConfig(func(settings map[string]interface{}) error {
+		delete(profilesSection(settings), name)
+		if name == DefaultProfile {
+			delete(settings, "api_key")
+		}
+		if settings["active_profile"] == name {
+			delete(settings, "active_profile")
+		}
+		return nil
+	})
+}
+
+// SetAPIKey saves key in plaintext in the config file.
+func SetAPIKey(key string) error {
+	return StoreAPIKey(StoreFile, key, "")
+}
+
+// EncryptedKeyPath returns where the encrypted store keeps profile's key.
+func EncryptedKeyPath(profile string) (string, error) {
+	dir, err := Dir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "keys", profile+".age"), nil
+}
+
+// StoreAPIKey saves key for the active profile in store. The keyring and
+// encrypted stores remove any plaintext key from the config file, and
+// passphrase is only used by the encrypted store.
+func StoreAPIKey(store, key, passphrase string) error {
+	profile := ActiveProfile()
+	switch store {
+	case StoreFile:
+	case StoreKeyring:
+		if err := secret.KeyringSet(profile, key); err != nil {
+			return err
+		}
+	case StoreEncrypted:
+		path, err := EncryptedKeyPath(profile)
+		if err != nil {
+			return err
+		}
+		if err := secret.WriteEncrypted(path, key, passphrase); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("invalid key store %q, valid stores: file, keyring, encrypted", store)
+	}
+
+	if previous := viper.GetString(profileKey(profile, "api_key_store")); previous != store {
+		forgetStoredKey(profile, previous)
+	}
+	delete(resolvedKeys, profile)
+	return updateConfig(func(settings map[string]interface{}) error {
+		section := profileSection(settings, profile)
+		if profile == DefaultProfile {
+			delete(settings, "api_key")
+		}
+		if store != StoreFile {
+			section["api_key_store"] = store
+			delete(section, "api_key")
+			return nil
+		}
+		section["api_key"] = key
+		// A top-level api_key_store applies to every profile, so the
+		// profile has to opt out of it explicitly.
+		if shared, _ := settings["api_key_store"].(string); shared != "" && shared != StoreFile {
+			section["api_key_store"] = StoreFile
+		} else {
+			delete(section, "api_key_store")
+		}
+		return nil
+	})
+}
+
+// SetOverride records a value given on the command line. Overrides win over
+// environment variables, the active profile and the config file, in that
+// order.
+func SetOverride(key, value string) {
+	flagOverrides[key] = value
+}
+
+func lookup(key, env string) (string, Source) {
+	return lookupIn(ActiveProfile(), key, env)
+}
+
+func lookupIn(profile, key, env string) (string, Source) {
+	if value := flagOverrides[key]; value != "" {
+		return value, SourceFlag
+	}
+	if value := os.Getenv(env); value != "" {
+		return value, SourceEnv
+	}
+	if value := viper.GetString(profileKey(profile, key)); value != "" {
+		return value, SourceProfile
+	}
+	// Top-level values are shared by every profile, except the API key: config
+	// files written before profiles existed keep it there, and it belongs to
+	// the default profile only.
+	if key != "api_key" || profile == DefaultProfile {
+		if value := viper.GetString(key); value != "" {
+			return value, SourceFile
+		}
+	}
+	return "", SourceNone
+}
+
+func LookupAPIKey() (string, Source) {
+	return lookup("api_key", APIKeyEnv)
+}
+
+func LookupBaseURL() (string, Source) {
+	return lookup("base_url", BaseURLEnv)
+}
+
+// LookupBaseURLFor is LookupBaseURL for a profile other than the active one.
+func LookupBaseURLFor(profile string) (string, Source) {
+	return lookupIn(profile, "base_url", BaseURLEnv)
+}
+
+func SetBaseURL(baseURL string) error {
+	profile := ActiveProfile()
+	return updateConfig(func(settings map[string]interface{}) error {
+		profileSection(settings, profile)["base_url"] = baseURL
+		return nil
+	})
+}
+
+func LookupRateLimit() (string, Source) {
+	return lookup("rate_limit", RateLimitEnv)
+}
+
+// SetRateLimit saves the active profile's rate limit, or removes it when
+// limit is empty.
+func SetRateLimit(limit string) error {
+	profile := ActiveProfile()
+	return updateConfig(func(settings map[string]interface{}) error {
+		section := profileSection(settings, profile)
+		if limit == "" {
+			delete(section, "rate_limit")
+		} else {
+			section["rate_limit"] = limit
+		}
+		return nil
+	})
+}
+
+// LookupSetting returns a setting such as "notify.sound" from env, the
+// active profile or the top level of the config file.
+func LookupSetting(key, env string) (string, Source) {
+	return lookup(key, env)
+}
+
+// SetSetting saves a setting such as "notify.sound" to the active profile.
+func SetSetting(key string, value interface{}) error {
+	profile := ActiveProfile()
+	return updateConfig(func(settings map[string]interface{}) error {
+		section := profileSection(settings, profile)
+		parts := strings.Split(key, ".")
+		for _, part := range parts[:len(parts)-1] {
+			child, ok := section[part].(map[string]interface{})
+			if !ok {
+				child = map[string]interface{}{}
+				section[part] = child
+			}
+			section = child
+		}
+		section[parts[len(parts)-1]] = value
+		return nil
+	})
+}
+
+// UnsetSetting removes a setting from the active profile, along with any
+// sections it leaves empty. It reports whether the profile had it.
+func UnsetSetting(key string) (bool, error) {
+	profile := ActiveProfile()
+	found := false
+	err := updateConfig(func(settings map[string]interface{}) error {
+		found = unsetPath(profileSection(settings, profile), strings.Split(key, "."))
+		return nil
+	})
+	return found, err
+}
+
+func unsetPath(section map[string]interface{}, parts []string) bool {
+	if len(parts) == 1 {
+		_, ok := section[parts[0]]
+		delete(section, parts[0])
+		return ok
+	}
+	child, ok := section[parts[0]].(map[string]interface{})
+	if !ok {
+		return false
+	}
+	found := unsetPath(child, parts[1:])
+	if len(child) == 0 {
+		delete(section, parts[0])
+	}
+	return found
+}
+
+// LookupSection decodes the config section key from the active profile, or
+// from the top level if the profile doesn't set it. It returns false if
+// neither does.
+func LookupSection(key string, v interface{}) (bool, error) {
+	return LookupSectionFor(ActiveProfile(), key, v)
+}
+
+// LookupSectionFor is LookupSection for a profile other than the active one.
+func LookupSectionFor(profile, key string, v interface{}) (bool, error) {
+	name := profileKey(profile, key)
+	if !viper.IsSet(name) {
+		name = key
+	}
+	if !viper.IsSet(name) {
+		return false, nil
+	}
+	if err := viper.UnmarshalKey(name, v); err != nil {
+		return false, fmt.Errorf("invalid %s: %w", key, err)
+	}
+	return true, nil
+}
+
+// forgetStoredKey removes profile's key from store. This is best effort: an
+// unavailable keyring shouldn't stop the key from being replaced or the
+// profile from being deleted.
+func forgetStoredKey(profile, store string) {
+	switch store {
+	case StoreKeyring:
+		secret.KeyringDelete(profile)
+	case StoreEncrypted:
+		if path, err := EncryptedKeyPath(profile); err == nil {
+			os.Remove(path)
+		}
+	}
+	delete(resolvedKeys, profile)
+}
+
+// ResolveAPIKey returns the API key from, in order, the --api-key flag,
+// PUSH_API_KEY, the profile's api_key_command, its api_key_store and finally
+// the plaintext api_key in the config file.
+func ResolveAPIKey() (string, Source, error) {
+	return ResolveAPIKeyFor(ActiveProfile())
+}
+
+// ResolveAPIKeyFor is ResolveAPIKey for a profile other than the active one.
+func ResolveAPIKeyFor(profile string) (string, Source, error) {
+	key, src := lookupIn(profile, "api_key", APIKeyEnv)
+	if src == SourceFlag || src == SourceEnv {
+		return key, src, nil
+	}
+
+	if r, ok := resolvedKeys[profile]; ok {
+		return r.key, r.src, nil
+	}
+	var err error
+	if command, _ := lookupIn(profile, "api_key_command", ""); command != "" {
+		key, src = "", SourceCommand
+		key, err = secret.RunCommand(command)
+	} else {
+		switch store, _ := lookupIn(profile, "api_key_store", ""); store {
+		case "", StoreFile:
+			return key, src, nil
+		case StoreKeyring:
+			key, src = "", SourceKeyring
+			key, err = secret.KeyringGet(profile)
+		case StoreEncrypted:
+			key, src = "", SourceEncrypted
+			var path, passphrase string
+			if path, err = EncryptedKeyPath(profile); err == nil {
+				if passphrase, err = secret.ReadPassphrase(false); err == nil {
+					key, err = secret.ReadEncrypted(path, passphrase)
+				}
+			}
+		default:
+			return "", SourceNone, fmt.Errorf("invalid api_key_store %q, valid stores: file, keyring, encrypted", store)
+		}
+	}
+	if errors.Is(err, secret.ErrNotFound) {
+		return "", SourceNone, nil
+	}
+	if err != nil {
+		return "", src, err
+	}
+	key = strings.TrimSpace(key)
+	resolvedKeys[profile] = resolvedKey{key, src}
+	return key, src, nil
 }
 
 func GetAPIKey() string {
-	return viper.GetString("api_key")
+	key, _, _ := ResolveAPIKey()
+	return key
+}
+
+func GetBaseURL() string {
+	baseURL, _ := LookupBaseURL()
+	return baseURL
 }
 
 func MaskedAPIKey() string {
@@ -3,6 +3,8 @@ package config
 import (
 	"os"
 	"path/filepath"
+	"runtime"
+	"strings"
 	"testing"
 
 	"github.com/spf13/viper"
@@ -83,6 +85,7 @@ func TestSetAPIKey_FilePermissions(t *testing.T) {
 	t.Setenv("HOME", tmpDir)
 
 	viper.Reset()
+	defer viper.Reset()
 
 	if err := SetAPIKey("test-key"); err != nil {
 		t.Fatalf("SetAPIKey() error: %v", err)
@@ -110,3 +113,308 @@ func TestGetAPIKey(t *testing.T) {
 		t.Errorf("GetAPIKey() = %q, want %q", got, "my-test-key")
 	}
 }
+
+func resetProfileState(t *testing.T) {
+	t.Helper()
+	tmpDir := t.TempDir()
+	t.Setenv("HOME", tmpDir)
+	viper.Reset()
+	profileOverride = ""
+	resolvedKeys = map[string]resolvedKey{}
+	t.Cleanup(func() {
+		viper.Reset()
+		profileOverride = ""
+		resolvedKeys = map[string]resolvedKey{}
+	})
+}
+
+func TestGetAPIKey_LegacyTopLevelKey(t *testing.T) {
+	resetProfileState(t)
+	viper.Set("api_key", "legacy-key")
+
+	if got := GetAPIKey(); got != "legacy-key" {
+		t.Errorf("GetAPIKey() = %q, want %q", got, "legacy-key")
+	}
+
+	SetProfile("team")
+	if got := GetAPIKey(); got != "" {
+		t.Errorf("GetAPIKey() for team = %q, want empty", got)
+	}
+}
+
+func TestProfiles_SetUseDelete(t *testing.T) {
+	resetProfileState(t)
+
+	if err := SetAPIKey("personal-key"); err != nil {
+		t.Fatalf("SetAPIKey() error: %v", err)
+	}
+	SetProfile("Team")
+	if err := SetAPIKey("team-key"); err != nil {
+		t.Fatalf("SetAPIKey() error: %v", err)
+	}
+	profileOverride = ""
+
+	if got := GetAPIKey(); got != "personal-key" {
+		t.Errorf("GetAPIKey() = %q, want %q", got, "personal-key")
+	}
+
+	profiles := Profiles()
+	if len(profiles) != 2 || profiles[0] != "default" || profiles[1] != "team" {
+		t.Fatalf("Profiles() = %v, want [default team]", profiles)
+	}
+
+	if err := UseProfile("team"); err != nil {
+		t.Fatalf("UseProfile() error: %v", err)
+	}
+	if got := ActiveProfile(); got != "team" {
+		t.Errorf("ActiveProfile() = %q, want %q", got, "team")
+	}
+	if got := GetAPIKey(); got != "team-key" {
+		t.Errorf("GetAPIKey() = %q, want %q", got, "team-key")
+	}
+
+	if err := DeleteProfile("team"); err != nil {
+		t.Fatalf("DeleteProfile() error: %v", err)
+	}
+	if got := ActiveProfile(); got != DefaultProfile {
+		t.Errorf("ActiveProfile() after delete = %q, want %q", got, DefaultProfile)
+	}
+	if ProfileExists("team") {
+		t.Error("expected team profile to be deleted")
+	}
+}
+
+func TestUseProfile_Missing(t *testing.T) {
+	resetProfileState(t)
+
+	if err := UseProfile("nope"); err == nil {
+		t.Fatal("expected error for missing profile")
+	}
+}
+
+func TestValidateProfileName(t *testing.T) {
+	for _, name := range []string{"default", "team-1", "my_profile"} {
+		if err := ValidateProfileName(name); err != nil {
+			t.Errorf("ValidateProfileName(%q) error: %v", name, err)
+		}
+	}
+	for _, name := range []string{"", "a.b", "with space"} {
+		if err := ValidateProfileName(name); err == nil {
+			t.Errorf("ValidateProfileName(%q) expected error", name)
+		}
+	}
+}
+
+func TestLookupAPIKey_Precedence(t *testing.T) {
+	resetProfileState(t)
+	t.Cleanup(func() { delete(flagOverrides, "api_key") })
+
+	viper.Set("api_key", "file-key")
+	if key, src := LookupAPIKey(); key != "file-key" || src != SourceFile {
+		t.Errorf("LookupAPIKey() = %q, %v, want file-key from config file", key, src)
+	}
+
+	viper.Set("profiles.default.api_key", "profile-key")
+	if key, src := LookupAPIKey(); key != "profile-key" || src != SourceProfile {
+		t.Errorf("LookupAPIKey() = %q, %v, want profile-key from profile", key, src)
+	}
+
+	t.Setenv(APIKeyEnv, "env-key")
+	if key, src := LookupAPIKey(); key != "env-key" || src != SourceEnv {
+		t.Errorf("LookupAPIKey() = %q, %v, want env-key from environment", key, src)
+	}
+
+	SetOverride("api_key", "flag-key")
+	if key, src := LookupAPIKey(); key != "flag-key" || src != SourceFlag {
+		t.Errorf("LookupAPIKey() = %q, %v, want flag-key from flag", key, src)
+	}
+}
+
+func TestLookupBaseURL_SharedAcrossProfiles(t *testing.T) {
+	resetProfileState(t)
+
+	viper.Set("base_url", "https://push.example.com/api/v1")
+	SetProfile("team")
+	if got, src := LookupBaseURL(); got != "https://push.example.com/api/v1" || src != SourceFile {
+		t.Errorf("LookupBaseURL() = %q, %v, want shared base URL from config file", got, src)
+	}
+
+	t.Setenv(BaseURLEnv, "http://localhost:3000/api/v1")
+	if got, src := LookupBaseURL(); got != "http://localhost:3000/api/v1" || src != SourceEnv {
+		t.Errorf("LookupBaseURL() = %q, %v, want base URL from environment", got, src)
+	}
+}
+
+func TestSetBaseURL_Profile(t *testing.T) {
+	resetProfileState(t)
+
+	SetProfile("staging")
+	if err := SetBaseURL("http://localhost:3000/api/v1"); err != nil {
+		t.Fatalf("SetBaseURL() error: %v", err)
+	}
+	if got, src := LookupBaseURL(); got != "http://localhost:3000/api/v1" || src != SourceProfile {
+		t.Errorf("LookupBaseURL() = %q, %v, want staging base URL from profile", got, src)
+	}
+
+	SetProfile(DefaultProfile)
+	if got := GetBaseURL(); got != "" {
+		t.Errorf("GetBaseURL() for default = %q, want empty", got)
+	}
+}
+
+func TestSetRateLimit_Unset(t *testing.T) {
+	resetProfileState(t)
+
+	if err := SetRateLimit("30/m"); err != nil {
+		t.Fatalf("SetRateLimit() error: %v", err)
+	}
+	if got, src := LookupRateLimit(); got != "30/m" || src != SourceProfile {
+		t.Errorf("LookupRateLimit() = %q, %v, want 30/m from profile", got, src)
+	}
+
+	if err := SetRateLimit(""); err != nil {
+		t.Fatalf("SetRateLimit() error: %v", err)
+	}
+	if got, _ := LookupRateLimit(); got != "" {
+		t.Errorf("LookupRateLimit() after unset = %q, want empty", got)
+	}
+}
+
+func TestLookupSection_ProfileWins(t *testing.T) {
+	resetProfileState(t)
+
+	type section struct {
+		Action string `mapstructure:"action"`
+	}
+	var got section
+	if ok, err := LookupSection("quiet_hours", &got); ok || err != nil {
+		t.Fatalf("LookupSection() = %v, %v, want not set", ok, err)
+	}
+
+	viper.Set("quiet_hours", map[string]interface{}{"action": "hold"})
+	viper.Set("profiles.work.quiet_hours", map[string]interface{}{"action": "drop"})
+	if ok, err := LookupSection("quiet_hours", &got); !ok || err != nil || got.Action != "hold" {
+		t.Errorf("LookupSection() = %v, %v, %+v, want the top-level section", ok, err, got)
+	}
+
+	SetProfile("work")
+	got = section{}
+	if ok, err := LookupSection("quiet_hours", &got); !ok || err != nil || got.Action != "drop" {
+		t.Errorf("LookupSection() = %v, %v, %+v, want the profile's section", ok, err, got)
+	}
+}
+
+func TestResolveAPIKey_Command(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses sh")
+	}
+	resetProfileState(t)
+	viper.Set("profiles.default.api_key", "plaintext-key")
+	viper.Set("profiles.default.api_key_command", "echo command-key")
+
+	if key, src, err := ResolveAPIKey(); err != nil || key != "command-key" || src != SourceCommand {
+		t.Errorf("ResolveAPIKey() = %q, %v, %v, want command-key from the command", key, src, err)
+	}
+
+	t.Setenv(APIKeyEnv, "env-key")
+	if key, src, _ := ResolveAPIKey(); key != "env-key" || src != SourceEnv {
+		t.Errorf("ResolveAPIKey() = %q, %v, want the environment to win", key, src)
+	}
+	os.Unsetenv(APIKeyEnv)
+
+	resolvedKeys = map[string]resolvedKey{}
+	viper.Set("profiles.default.api_key_command", "exit 3")
+	if _, _, err := ResolveAPIKey(); err == nil {
+		t.Error("expected an error from a failing command")
+	}
+}
+
+func TestStoreAPIKey_Encrypted(t *testing.T) {
+	resetProfileState(t)
+	t.Setenv("PUSH_KEY_PASSPHRASE", "correct horse")
+	if err := SetAPIKey("plaintext-key"); err != nil {
+		t.Fatalf("SetAPIKey() error: %v", err)
+	}
+
+	if err := StoreAPIKey(StoreEncrypted, "secret-key", "correct horse"); err != nil {
+		t.Fatalf("StoreAPIKey() error: %v", err)
+	}
+	if viper.IsSet("profiles.default.api_key") {
+		t.Error("expected the plaintext key to be removed from the config file")
+	}
+	resolvedKeys = map[string]resolvedKey{}
+	if key, src, err := ResolveAPIKey(); err != nil || key != "secret-key" || src != SourceEncrypted {
+		t.Errorf("ResolveAPIKey() = %q, %v, %v, want secret-key from the encrypted file", key, src, err)
+	}
+
+	resolvedKeys = map[string]resolvedKey{}
+	t.Setenv("PUSH_KEY_PASSPHRASE", "wrong")
+	if _, _, err := ResolveAPIKey(); err == nil {
+		t.Error("expected an error with the wrong passphrase")
+	}
+
+	if err := SetAPIKey("plaintext-key"); err != nil {
+		t.Fatalf("SetAPIKey() error: %v", err)
+	}
+	if key, src, err := ResolveAPIKey(); err != nil || key != "plaintext-key" || src != SourceProfile {
+		t.Errorf("ResolveAPIKey() = %q, %v, %v, want the plaintext key after switching back", key, src, err)
+	}
+}
+
+func TestSetSetting_Nested(t *testing.T) {
+	resetProfileState(t)
+
+	if err := SetSetting("notify.sound", "pop"); err != nil {
+		t.Fatalf("SetSetting() error: %v", err)
+	}
+	if err := SetSetting("notify.channel", "ops"); err != nil {
+		t.Fatalf("SetSetting() error: %v", err)
+	}
+	if got, src := LookupSetting("notify.sound", ""); got != "pop" || src != SourceProfile {
+		t.Errorf("LookupSetting() = %q, %v, want pop from profile", got, src)
+	}
+
+	for _, key := range []string{"notify.sound", "notify.channel"} {
+		if found, err := UnsetSetting(key); !found || err != nil {
+			t.Fatalf("UnsetSetting(%q) = %v, %v", key, found, err)
+		}
+	}
+	if viper.IsSet("profiles.default.notify") {
+		t.Error("expected the empty notify section to be removed")
+	}
+	if found, err := UnsetSetting("notify.sound"); found || err != nil {
+		t.Errorf("UnsetSetting() of a missing key = %v, %v, want false", found, err)
+	}
+}
+
+func TestCheckSettings(t *testing.T) {
+	settings := map[string]interface{}{
+		"active_profile": "work",
+		"api_key":        "key",
+		"notfy":          map[string]interface{}{"sound": "pop"},
+		"quiet_hours": map[string]interface{}{
+			"windows": []interface{}{map[string]interface{}{"start": "22:00", "end": "07:00", "dayz": []interface{}{"mon"}}},
+		},
+		"profiles": map[string]interface{}{
+			"work": map[string]interface{}{
+				"base_url": "https://push.example.com",
+				"notify":   map[string]interface{}{"sond": "pop", "time_sensitive": "maybe", "channel": "ops"},
+			},
+		},
+	}
+	want := []string{
+		"unknown key notfy is ignored, did you mean notify?",
+		"unknown key profiles.work.notify.sond is ignored, did you mean profiles.work.notify.sound?",
+		"profiles.work.notify.time_sensitive should be true or false",
+		"unknown key quiet_hours.windows[0].dayz is ignored, did you mean quiet_hours.windows[0].days?",
+	}
+	got := checkSettings(settings)
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("checkSettings() =\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+
+	valid := map[string]interface{}{"notify": map[string]interface{}{"time_sensitive": true, "sound": "pop"}}
+	if got := checkSettings(valid); len(got) != 0 {
+		t.Errorf("checkSettings() of a valid config = %v, want no problems", got)
+	}
+}
@@ -0,0 +1,198 @@
+package config
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+type valueKind int
+
+const (
+	kindString valueKind = iota
+	kindBool
+	kindList
+)
+
+// profileSchema lists the keys a profile, or the top level of the config
+// file, may set. Keys under a list apply to each of its items.
+var profileSchema = map[string]valueKind{
+	"api_key":                            kindString,
+	"api_key_store":                      kindString,
+	"api_key_command":                    kindString,
+	"base_url":                           kindString,
+	"rate_limit":                         kindString,
+	"notify.sound":                       kindString,
+	"notify.channel":                     kindString,
+	"notify.link":                        kindString,
+	"notify.image":                       kindString,
+	"notify.time_sensitive":              kindBool,
+	"notify.title_prefix":                kindString,
+	"quiet_hours.timezone":               kindString,
+	"quiet_hours.action":                 kindString,
+	"quiet_hours.include_time_sensitive": kindBool,
+	"quiet_hours.windows":                kindList,
+	"quiet_hours.windows.days":           kindList,
+	"quiet_hours.windows.start":          kindString,
+	"quiet_hours.windows.end":            kindString,
+}
+
+// File returns the path of the config file that was read, or "" if there
+// is none.
+func File() string {
+	return viper.ConfigFileUsed()
+}
+
+// Warnings returns the problems found in the config file when it was read:
+// unknown keys, which are ignored, and values of the wrong type.
+func Warnings() []string {
+	return warnings
+}
+
+// checkSettings validates settings read from the config file.
+func checkSettings(settings map[string]interface{}) []string {
+	var problems []string
+	for _, key := range sortedKeys(settings) {
+		value := settings[key]
+		switch key {
+		case "active_profile":
+			if _, ok := value.(string); !ok {
+				problems = append(problems, fmt.Sprintf("active_profile should be a profile name, got %v", value))
+			}
+		case "profiles":
+			profiles, ok := value.(map[string]interface{})
+			if !ok {
+				problems = append(problems, "profiles should be a section with one entry per profile")
+				continue
+			}
+			for _, name := range sortedKeys(profiles) {
+				profile, ok := profiles[name].(map[string]interface{})
+				if !ok {
+					problems = append(problems, fmt.Sprintf("profiles.%s should be a section", name))
+					continue
+				}
+				problems = append(problems, checkSection(profile, "", "profiles."+name+".")...)
+			}
+		default:
+			problems = append(problems, checkSection(map[string]interface{}{key: value}, "", "")...)
+		}
+	}
+	return problems
+}
+
+// checkSection checks section, found at path in the schema, and reports
+// keys under display, the prefix the user sees.
+func checkSection(section map[string]interface{}, path, display string) []string {
+	var problems []string
+	for _, key := range sortedKeys(section) {
+		value, name := section[key], path+key
+		kind, known := profileSchema[name]
+		switch {
+		case known && kind == kindList:
+			items, ok := value.([]interface{})
+			if !ok {
+				problems = append(problems, fmt.Sprintf("%s%s should be a list", display, key))
+				continue
+			}
+			for i, item := range items {
+				if child, ok := item.(map[string]interface{}); ok {
+					problems = append(problems, checkSection(child, name+".", fmt.Sprintf("%s%s[%d].", display, key, i))...)
+				}
+			}
+		case known:
+			if msg := checkValue(kind, value); msg != "" {
+				problems = append(problems, fmt.Sprintf("%s%s %s", display, key, msg))
+			}
+		case isSection(name):
+			child, ok := value.(map[string]interface{})
+			if !ok {
+				problems = append(problems, fmt.Sprintf("%s%s should be a section", display, key))
+				continue
+			}
+			problems = append(problems, checkSection(child, name+".", display+key+".")...)
+		default:
+			msg := fmt.Sprintf("unknown key %s%s is ignored", display, key)
+			if s := suggest(path, key); s != "" {
+				msg += fmt.Sprintf(", did you mean %s%s?", display, s)
+			}
+			problems = append(problems, msg)
+		}
+	}
+	return problems
+}
+
+func checkValue(kind valueKind, value interface{}) string {
+	switch v := value.(type) {
+	case map[string]interface{}, []interface{}, nil:
+		return "should be a single value"
+	case string:
+		if kind == kindBool {
+			if _, err := strconv.ParseBool(v); err != nil {
+				return "should be true or false"
+			}
+		}
+	case bool:
+	default:
+		if kind == kindBool {
+			return "should be true or false"
+		}
+	}
+	return ""
+}
+
+func isSection(name string) bool {
+	for key := range profileSchema {
+		if strings.HasPrefix(key, name+".") {
+			return true
+		}
+	}
+	return false
+}
+
+// suggest returns the known key next to path that is closest to key, if it
+// is likely a typo.
+func suggest(path, key string) string {
+	best, bestDist := "", 3
+	for known := range profileSchema {
+		if !strings.HasPrefix(known, path) {
+			continue
+		}
+		name, _, _ := strings.Cut(strings.TrimPrefix(known, path), ".")
+		if d := editDistance(key, name); d < bestDist || (d == bestDist && name < best) {
+			best, bestDist = name, d
+		}
+	}
+	return best
+}
+
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur := make([]int, len(b)+1)
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev = cur
+	}
+	return prev[len(b)]
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
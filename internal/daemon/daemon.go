@@ -0,0 +1,278 @@
+package daemon
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"mime"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/techulus/push-cli/internal/api"
+	"github.com/techulus/push-cli/internal/spool"
+)
+
+const maxRequestBody = 1 << 20
+
+// Job is one accepted notification waiting to be forwarded.
+type Job struct {
+	ID      string
+	Target  string
+	GroupID string
+	Request api.NotifyRequest
+}
+
+type SendFunc func(ctx context.Context, job Job) error
+
+// FailFunc is called with a job that could not be delivered, after the
+// client's own retries are exhausted.
+type FailFunc func(job Job, err error)
+
+type Options struct {
+	Workers   int
+	QueueSize int
+	Validate  func(api.NotifyRequest) error
+	// Token, if set, must be sent as a bearer token on every notification.
+	Token     string
+	OnFailure FailFunc
+	Logger    *slog.Logger
+}
+
+// Server accepts notifications over HTTP, queues them in memory and
+// forwards them with a fixed pool of workers.
+type Server struct {
+	send SendFunc
+	opts Options
+	jobs chan Job
+
+	mu     sync.RWMutex
+	closed bool
+	wg     sync.WaitGroup
+
+	accepted atomic.Int64
+	sent     atomic.Int64
+	failed   atomic.Int64
+	started  time.Time
+}
+
+func New(send SendFunc, opts Options) *Server {
+	if opts.Workers < 1 {
+		opts.Workers = 1
+	}
+	if opts.QueueSize < 1 {
+		opts.QueueSize = 1
+	}
+	if opts.Logger == nil {
+		opts.Logger = slog.Default()
+	}
+	return &Server{
+		send:    send,
+		opts:    opts,
+		jobs:    make(chan Job, opts.QueueSize),
+		started: time.Now(),
+	}
+}
+
+// Start launches the workers. ctx is passed to every send, so canceling it
+// aborts deliveries in flight; use Shutdown to drain the queue instead.
+func (s *Server) Start(ctx context.Context) {
+	for i := 0; i < s.opts.Workers; i++ {
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			for job := range s.jobs {
+				s.forward(ctx, job)
+			}
+		}()
+	}
+}
+
+func (s *Server) forward(ctx context.Context, job Job) {
+	log := s.opts.Logger.With("id", job.ID, "target", job.Target, "title", job.Request.Title)
+	if job.GroupID != "" {
+		log = log.With("group", job.GroupID)
+	}
+
+	start := time.Now()
+	err := s.send(ctx, job)
+	durationMS := time.Since(start).Milliseconds()
+	if err != nil {
+		s.failed.Add(1)
+		log.Error("notification failed", "duration_ms", durationMS, "error", err)
+		if s.opts.OnFailure != nil {
+			s.opts.OnFailure(job, err)
+		}
+		return
+	}
+	s.sent.Add(1)
+	log.Info("notification sent", "duration_ms", durationMS)
+}
+
+// Shutdown stops accepting notifications and waits for queued ones to be
+// forwarded, or for ctx to expire.
+func (s *Server) Shutdown(ctx context.Context) error {
+	s.mu.Lock()
+	if !s.closed {
+		s.closed = true
+		close(s.jobs)
+	}
+	s.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("%d notification(s) still queued: %w", len(s.jobs), ctx.Err())
+	}
+}
+
+var errQueueFull = errors.New("queue is full")
+var errShuttingDown = errors.New("daemon is shutting down")
+
+func (s *Server) enqueue(job Job) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.closed {
+		return errShuttingDown
+	}
+	select {
+	case s.jobs <- job:
+		s.accepted.Add(1)
+		return nil
+	default:
+		return errQueueFull
+	}
+}
+
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/healthz", s.handleHealth)
+	mux.HandleFunc("/notify", s.handleNotify)
+	mux.HandleFunc("/notify-async", s.handleNotify)
+	mux.HandleFunc("/notify/group/", s.handleNotify)
+	return mux
+}
+
+type healthResponse struct {
+	Status   string `json:"status"`
+	Queued   int    `json:"queued"`
+	Accepted int64  `json:"accepted"`
+	Sent     int64  `json:"sent"`
+	Failed   int64  `json:"failed"`
+	Uptime   string `json:"uptime"`
+}
+
+func (s *Server) handleHealth(w http.ResponseWriter, r *http.Request) {
+	status := "ok"
+	s.mu.RLock()
+	if s.closed {
+		status = "shutting down"
+	}
+	s.mu.RUnlock()
+
+	writeJSON(w, http.StatusOK, healthResponse{
+		Status:   status,
+		Queued:   len(s.jobs),
+		Accepted: s.accepted.Load(),
+		Sent:     s.sent.Load(),
+		Failed:   s.failed.Load(),
+		Uptime:   time.Since(s.started).Round(time.Second).String(),
+	})
+}
+
+type acceptedResponse struct {
+	Success bool   `json:"success"`
+	Queued  bool   `json:"queued"`
+	ID      string `json:"id"`
+}
+
+type errorResponse struct {
+	Success bool   `json:"success"`
+	Message string `json:"message"`
+}
+
+func (s *Server) handleNotify(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		writeError(w, http.StatusMethodNotAllowed, "use POST")
+		return
+	}
+	if s.opts.Token != "" && subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), []byte("Bearer "+s.opts.Token)) != 1 {
+		writeError(w, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+	// Browsers can send text/plain POSTs to localhost without a preflight,
+	// so only JSON requests are accepted.
+	if mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mediaType != "application/json" {
+		writeError(w, http.StatusUnsupportedMediaType, "Content-Type must be application/json")
+		return
+	}
+
+	job := Job{ID: newID()}
+	switch {
+	case r.URL.Path == "/notify":
+		job.Target = spool.TargetNotify
+	case r.URL.Path == "/notify-async":
+		job.Target = spool.TargetAsync
+	default:
+		job.Target = spool.TargetGroup
+		job.GroupID = strings.TrimPrefix(r.URL.Path, "/notify/group/")
+		if job.GroupID == "" || strings.Contains(job.GroupID, "/") {
+			writeError(w, http.StatusNotFound, "expected /notify/group/<group-id>")
+			return
+		}
+	}
+
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxRequestBody))
+	if err := dec.Decode(&job.Request); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid JSON: "+err.Error())
+		return
+	}
+	if strings.TrimSpace(job.Request.Title) == "" || strings.TrimSpace(job.Request.Body) == "" {
+		writeError(w, http.StatusBadRequest, "title and body are required")
+		return
+	}
+	if s.opts.Validate != nil {
+		if err := s.opts.Validate(job.Request); err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+
+	if err := s.enqueue(job); err != nil {
+		w.Header().Set("Retry-After", "1")
+		writeError(w, http.StatusServiceUnavailable, err.Error())
+		s.opts.Logger.Warn("notification rejected", "target", job.Target, "error", err)
+		return
+	}
+	writeJSON(w, http.StatusAccepted, acceptedResponse{Success: true, Queued: true, ID: job.ID})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, errorResponse{Success: false, Message: message})
+}
+
+func newID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
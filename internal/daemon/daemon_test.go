@@ -0,0 +1,247 @@
+package daemon
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/techulus/push-cli/internal/api"
+	"github.com/techulus/push-cli/internal/spool"
+)
+
+func quietLogger() *slog.Logger {
+	return slog.New(slog.NewTextHandler(io.Discard, nil))
+}
+
+type recorder struct {
+	mu   sync.Mutex
+	jobs []Job
+}
+
+func (r *recorder) send(ctx context.Context, job Job) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.jobs = append(r.jobs, job)
+	return nil
+}
+
+func post(t *testing.T, url, body string) (*http.Response, map[string]interface{}) {
+	t.Helper()
+	resp, err := http.Post(url, "application/json", strings.NewReader(body))
+	if err != nil {
+		t.Fatalf("POST %s error: %v", url, err)
+	}
+	defer resp.Body.Close()
+	var decoded map[string]interface{}
+	json.NewDecoder(resp.Body).Decode(&decoded)
+	return resp, decoded
+}
+
+func TestServer_ForwardsNotifications(t *testing.T) {
+	rec := &recorder{}
+	s := New(rec.send, Options{Workers: 2, QueueSize: 10, Logger: quietLogger()})
+	s.Start(context.Background())
+	server := httptest.NewServer(s.Handler())
+	defer server.Close()
+
+	resp, body := post(t, server.URL+"/notify", `{"title":"A","body":"one"}`)
+	if resp.StatusCode != http.StatusAccepted || body["id"] == "" || body["queued"] != true {
+		t.Fatalf("unexpected response: %d %v", resp.StatusCode, body)
+	}
+	post(t, server.URL+"/notify-async", `{"title":"B","body":"two"}`)
+	post(t, server.URL+"/notify/group/ops", `{"title":"C","body":"three"}`)
+
+	if err := s.Shutdown(context.Background()); err != nil {
+		t.Fatalf("Shutdown() error: %v", err)
+	}
+
+	targets := map[string]string{}
+	for _, job := range rec.jobs {
+		targets[job.Request.Title] = job.Target + job.GroupID
+	}
+	want := map[string]string{"A": spool.TargetNotify, "B": spool.TargetAsync, "C": spool.TargetGroup + "ops"}
+	for title, target := range want {
+		if targets[title] != target {
+			t.Errorf("expected %s to go to %s, got %q", title, target, targets[title])
+		}
+	}
+}
+
+func TestServer_RejectsInvalidRequests(t *testing.T) {
+	s := New((&recorder{}).send, Options{
+		Logger: quietLogger(),
+		Validate: func(req api.NotifyRequest) error {
+			if req.Sound == "bad" {
+				return errors.New("invalid sound")
+			}
+			return nil
+		},
+	})
+	server := httptest.NewServer(s.Handler())
+	defer server.Close()
+
+	tests := []struct {
+		path, body string
+		want       int
+	}{
+		{"/notify", `not json`, http.StatusBadRequest},
+		{"/notify", `{"title":"A"}`, http.StatusBadRequest},
+		{"/notify", `{"title":"A","body":"b","sound":"bad"}`, http.StatusBadRequest},
+		{"/notify/group/", `{"title":"A","body":"b"}`, http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		resp, body := post(t, server.URL+tt.path, tt.body)
+		if resp.StatusCode != tt.want || body["success"] != false {
+			t.Errorf("POST %s %s = %d %v, want %d", tt.path, tt.body, resp.StatusCode, body, tt.want)
+		}
+	}
+
+	resp, err := http.Get(server.URL + "/notify")
+	if err != nil {
+		t.Fatalf("GET error: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusMethodNotAllowed {
+		t.Errorf("GET /notify = %d, want 405", resp.StatusCode)
+	}
+}
+
+func TestServer_RequiresJSONAndToken(t *testing.T) {
+	rec := &recorder{}
+	s := New(rec.send, Options{Token: "secret", Logger: quietLogger()})
+	server := httptest.NewServer(s.Handler())
+	defer server.Close()
+
+	tests := []struct {
+		name, contentType, auth string
+		want                    int
+	}{
+		{"missing token", "application/json", "", http.StatusUnauthorized},
+		{"wrong token", "application/json", "Bearer nope", http.StatusUnauthorized},
+		{"text/plain", "text/plain", "Bearer secret", http.StatusUnsupportedMediaType},
+		{"no content type", "", "Bearer secret", http.StatusUnsupportedMediaType},
+		{"valid", "application/json; charset=utf-8", "Bearer secret", http.StatusAccepted},
+	}
+	for _, tt := range tests {
+		req, _ := http.NewRequest(http.MethodPost, server.URL+"/notify", strings.NewReader(`{"title":"A","body":"b"}`))
+		if tt.contentType != "" {
+			req.Header.Set("Content-Type", tt.contentType)
+		}
+		if tt.auth != "" {
+			req.Header.Set("Authorization", tt.auth)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("%s: POST error: %v", tt.name, err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != tt.want {
+			t.Errorf("%s: got %d, want %d", tt.name, resp.StatusCode, tt.want)
+		}
+	}
+}
+
+func TestServer_QueueFull(t *testing.T) {
+	s := New((&recorder{}).send, Options{QueueSize: 1, Logger: quietLogger()})
+	server := httptest.NewServer(s.Handler())
+	defer server.Close()
+
+	// No workers are running, so the second request finds the queue full.
+	post(t, server.URL+"/notify", `{"title":"A","body":"one"}`)
+	resp, _ := post(t, server.URL+"/notify", `{"title":"B","body":"two"}`)
+	if resp.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("expected 503 when queue is full, got %d", resp.StatusCode)
+	}
+	if resp.Header.Get("Retry-After") == "" {
+		t.Error("expected Retry-After header")
+	}
+}
+
+func TestServer_ShutdownDrainsAndRejects(t *testing.T) {
+	release := make(chan struct{})
+	rec := &recorder{}
+	s := New(func(ctx context.Context, job Job) error {
+		<-release
+		return rec.send(ctx, job)
+	}, Options{Workers: 1, QueueSize: 5, Logger: quietLogger()})
+	s.Start(context.Background())
+	server := httptest.NewServer(s.Handler())
+	defer server.Close()
+
+	for i := 0; i < 3; i++ {
+		post(t, server.URL+"/notify", `{"title":"A","body":"b"}`)
+	}
+
+	done := make(chan error)
+	go func() { done <- s.Shutdown(context.Background()) }()
+	time.Sleep(20 * time.Millisecond)
+
+	resp, _ := post(t, server.URL+"/notify", `{"title":"late","body":"b"}`)
+	if resp.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("expected 503 while shutting down, got %d", resp.StatusCode)
+	}
+
+	close(release)
+	if err := <-done; err != nil {
+		t.Fatalf("Shutdown() error: %v", err)
+	}
+	if len(rec.jobs) != 3 {
+		t.Errorf("expected 3 queued jobs to be delivered, got %d", len(rec.jobs))
+	}
+}
+
+func TestServer_ShutdownTimeout(t *testing.T) {
+	block := make(chan struct{})
+	defer close(block)
+	s := New(func(ctx context.Context, job Job) error {
+		<-block
+		return nil
+	}, Options{Workers: 1, QueueSize: 5, Logger: quietLogger()})
+	s.Start(context.Background())
+	server := httptest.NewServer(s.Handler())
+	defer server.Close()
+
+	post(t, server.URL+"/notify", `{"title":"A","body":"b"}`)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := s.Shutdown(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected deadline exceeded, got %v", err)
+	}
+}
+
+func TestServer_HealthAndFailures(t *testing.T) {
+	var failedJob Job
+	s := New(func(ctx context.Context, job Job) error {
+		return errors.New("boom")
+	}, Options{Logger: quietLogger(), OnFailure: func(job Job, err error) { failedJob = job }})
+	s.Start(context.Background())
+	server := httptest.NewServer(s.Handler())
+	defer server.Close()
+
+	post(t, server.URL+"/notify", `{"title":"A","body":"b"}`)
+	s.Shutdown(context.Background())
+
+	if failedJob.Request.Title != "A" {
+		t.Errorf("expected OnFailure to receive the job, got %+v", failedJob)
+	}
+
+	resp, err := http.Get(server.URL + "/healthz")
+	if err != nil {
+		t.Fatalf("GET /healthz error: %v", err)
+	}
+	defer resp.Body.Close()
+	var health healthResponse
+	json.NewDecoder(resp.Body).Decode(&health)
+	if health.Accepted != 1 || health.Failed != 1 || health.Sent != 0 || health.Status != "shutting down" {
+		t.Errorf("unexpected health: %+v", health)
+	}
+}
@@ -0,0 +1,295 @@
+package digest
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/techulus/push-cli/internal/state"
+)
+
+const (
+	DefaultTitleTemplate = `{{.Key}} ({{.Count}})`
+	DefaultBodyTemplate  = `{{range .Shown}}{{.Body}}
+{{end}}{{if .More}}+{{.More}} more{{end}}`
+	DefaultShow = 10
+)
+
+var ErrUnknown = errors.New("unknown digest")
+
+type Item struct {
+	Body string    `json:"body"`
+	Time time.Time `json:"time"`
+}
+
+// Settings control how a digest is summarized and when a running daemon
+// flushes it. They are kept with the digest, so the latest values given to
+// push digest add apply.
+type Settings struct {
+	TitleTemplate string `json:"titleTemplate,omitempty"`
+	BodyTemplate  string `json:"bodyTemplate,omitempty"`
+	// Show is how many bodies are listed before "+K more".
+	Show    int    `json:"show,omitempty"`
+	Sound   string `json:"sound,omitempty"`
+	Channel string `json:"channel,omitempty"`
+	// MaxItems and MaxAge make the digest due once it holds that many items
+	// or its oldest item is that old. Zero disables either.
+	MaxItems int           `json:"maxItems,omitempty"`
+	MaxAge   time.Duration `json:"-"`
+}
+
+// MarshalJSON writes MaxAge as a string like "10m0s".
+func (s Settings) MarshalJSON() ([]byte, error) {
+	type plain Settings
+	raw := struct {
+		plain
+		MaxAge string `json:"maxAge,omitempty"`
+	}{plain: plain(s)}
+	if s.MaxAge > 0 {
+		raw.MaxAge = s.MaxAge.String()
+	}
+	return json.Marshal(raw)
+}
+
+func (s *Settings) UnmarshalJSON(data []byte) error {
+	type plain Settings
+	var raw struct {
+		plain
+		MaxAge string `json:"maxAge,omitempty"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	*s = Settings(raw.plain)
+	if raw.MaxAge != "" {
+		d, err := time.ParseDuration(raw.MaxAge)
+		if err != nil {
+			return fmt.Errorf("invalid maxAge: %w", err)
+		}
+		s.MaxAge = d
+	}
+	return nil
+}
+
+// Validate checks that the templates parse and render a sample digest, so
+// a template that refers to a missing field fails here rather than on every
+// flush.
+func (s Settings) Validate(funcs template.FuncMap) error {
+	sample := Digest{Key: "sample", Items: []Item{{Body: "sample", Time: time.Now()}}, Settings: s}
+	_, _, err := sample.Render(funcs)
+	return err
+}
+
+func (s Settings) templates(funcs template.FuncMap) (title, body *template.Template, err error) {
+	titleText, bodyText := s.TitleTemplate, s.BodyTemplate
+	if titleText == "" {
+		titleText = DefaultTitleTemplate
+	}
+	if bodyText == "" {
+		bodyText = DefaultBodyTemplate
+	}
+	if title, err = template.New("title").Funcs(funcs).Parse(titleText); err != nil {
+		return nil, nil, fmt.Errorf("invalid title template: %w", err)
+	}
+	if body, err = template.New("body").Funcs(funcs).Parse(bodyText); err != nil {
+		return nil, nil, fmt.Errorf("invalid body template: %w", err)
+	}
+	return title, body, nil
+}
+
+// Digest is a buffer of items waiting to be sent as one notification.
+type Digest struct {
+	Key      string   `json:"key"`
+	Items    []Item   `json:"items"`
+	Settings Settings `json:"settings"`
+	// Error is set when the digest was parked after a flush that retrying
+	// won't fix. A parked digest is never due; it is sent again once its
+	// settings change or it is flushed explicitly.
+	Error string `json:"error,omitempty"`
+}
+
+// Due reports whether a daemon should flush d.
+func (d Digest) Due(now time.Time) bool {
+	if len(d.Items) == 0 || d.Error != "" {
+		return false
+	}
+	if d.Settings.MaxItems > 0 && len(d.Items) >= d.Settings.MaxItems {
+		return true
+	}
+	return d.Settings.MaxAge > 0 && now.Sub(d.Items[0].Time) >= d.Settings.MaxAge
+}
+
+// Summary is the data available to the title and body templates.
+type Summary struct {
+	Key   string
+	Count int
+	Items []Item
+	// Shown is the first Settings.Show items, and More the number left out.
+	Shown []Item
+	More  int
+	First time.Time
+	Last  time.Time
+}
+
+// Render builds the title and body of the notification for d. funcs are
+// added to the templates.
+func (d Digest) Render(funcs template.FuncMap) (title, body string, err error) {
+	titleTmpl, bodyTmpl, err := d.Settings.templates(funcs)
+	if err != nil {
+		return "", "", err
+	}
+
+	show := d.Settings.Show
+	if show <= 0 {
+		show = DefaultShow
+	}
+	s := Summary{Key: d.Key, Count: len(d.Items), Items: d.Items, Shown: d.Items}
+	if len(d.Items) > show {
+		s.Shown, s.More = d.Items[:show], len(d.Items)-show
+	}
+	if len(d.Items) > 0 {
+		s.First, s.Last = d.Items[0].Time, d.Items[len(d.Items)-1].Time
+	}
+
+	var buf bytes.Buffer
+	if err := titleTmpl.Execute(&buf, s); err != nil {
+		return "", "", fmt.Errorf("rendering title: %w", err)
+	}
+	title = strings.TrimSpace(buf.String())
+	buf.Reset()
+	if err := bodyTmpl.Execute(&buf, s); err != nil {
+		return "", "", fmt.Errorf("rendering body: %w", err)
+	}
+	return title, strings.TrimSpace(buf.String()), nil
+}
+
+// Store keeps digests in a JSON file shared by every push process.
+type Store struct {
+	path string
+}
+
+func Open(path string) *Store {
+	return &Store{path: path}
+}
+
+func (s *Store) update(fn func(map[string]*Digest) error) error {
+	digests := map[string]*Digest{}
+	return state.Update(s.path, &digests, func() error { return fn(digests) })
+}
+
+// Add appends item to the digest named key, creating it if needed.
+// configure, if set, may change the digest's settings.
+func (s *Store) Add(key string, item Item, configure func(*Settings)) (Digest, error) {
+	var d Digest
+	err := s.update(func(digests map[string]*Digest) error {
+		p := digests[key]
+		if p == nil {
+			p = &Digest{Key: key}
+			digests[key] = p
+		}
+		p.Items = append(p.Items, item)
+		if configure != nil {
+			before := p.Settings
+			configure(&p.Settings)
+			if p.Settings != before {
+				p.Error = ""
+			}
+		}
+		d = *p
+		return nil
+	})
+	return d, err
+}
+
+// List returns every digest, sorted by key.
+func (s *Store) List() ([]Digest, error) {
+	digests := map[string]*Digest{}
+	if err := state.Load(s.path, &digests); err != nil {
+		return nil, err
+	}
+	list := make([]Digest, 0, len(digests))
+	for _, d := range digests {
+		list = append(list, *d)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Key < list[j].Key })
+	return list, nil
+}
+
+// Take removes and returns the pending items of the digests chosen by take,
+// keeping their settings. Pass the result to Restore if it can't be sent.
+func (s *Store) Take(take func(Digest) bool) ([]Digest, error) {
+	var taken []Digest
+	err := s.update(func(digests map[string]*Digest) error {
+		for _, d := range digests {
+			if len(d.Items) == 0 || !take(*d) {
+				continue
+			}
+			taken = append(taken, *d)
+			d.Items, d.Error = nil, ""
+		}
+		return nil
+	})
+	sort.Slice(taken, func(i, j int) bool { return taken[i].Key < taken[j].Key })
+	return taken, err
+}
+
+// TakeKey is Take for a single digest. It returns ErrUnknown if the digest
+// doesn't exist.
+func (s *Store) TakeKey(key string) (Digest, error) {
+	var d Digest
+	err := s.update(func(digests map[string]*Digest) error {
+		p := digests[key]
+		if p == nil {
+			return fmt.Errorf("%w %q", ErrUnknown, key)
+		}
+		d = *p
+		p.Items, p.Error = nil, ""
+		return nil
+	})
+	return d, err
+}
+
+// Restore puts the items of a digest that failed to send back in front of
+// any added since it was taken.
+func (s *Store) Restore(d Digest) error {
+	return s.update(func(digests map[string]*Digest) error {
+		p := digests[d.Key]
+		if p == nil {
+			digests[d.Key] = &d
+			return nil
+		}
+		p.Items = append(append([]Item(nil), d.Items...), p.Items...)
+		return nil
+	})
+}
+
+// Park restores the items of d like Restore, and marks the digest so that
+// it is no longer due.
+func (s *Store) Park(d Digest, reason string) error {
+	return s.update(func(digests map[string]*Digest) error {
+		p := digests[d.Key]
+		if p == nil {
+			p = &Digest{Key: d.Key, Settings: d.Settings}
+			digests[d.Key] = p
+		}
+		p.Items = append(append([]Item(nil), d.Items...), p.Items...)
+		p.Error = reason
+		return nil
+	})
+}
+
+// Remove deletes the digest named key along with any pending items.
+func (s *Store) Remove(key string) error {
+	return s.update(func(digests map[string]*Digest) error {
+		if digests[key] == nil {
+			return fmt.Errorf("%w %q", ErrUnknown, key)
+		}
+		delete(digests, key)
+		return nil
+	})
+}
@@ -0,0 +1,182 @@
+package digest
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+var start = time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+func items(n int) []Item {
+	list := make([]Item, n)
+	for i := range list {
+		list[i] = Item{Body: fmt.Sprintf("deploy %d", i+1), Time: start.Add(time.Duration(i) * time.Minute)}
+	}
+	return list
+}
+
+func TestRender_Default(t *testing.T) {
+	d := Digest{Key: "deploys", Items: items(3)}
+	title, body, err := d.Render(nil)
+	if err != nil {
+		t.Fatalf("Render() error: %v", err)
+	}
+	if title != "deploys (3)" {
+		t.Errorf("title = %q", title)
+	}
+	if body != "deploy 1\ndeploy 2\ndeploy 3" {
+		t.Errorf("body = %q", body)
+	}
+}
+
+func TestRender_More(t *testing.T) {
+	d := Digest{Key: "deploys", Items: items(5), Settings: Settings{Show: 2}}
+	_, body, err := d.Render(nil)
+	if err != nil {
+		t.Fatalf("Render() error: %v", err)
+	}
+	if body != "deploy 1\ndeploy 2\n+3 more" {
+		t.Errorf("body = %q", body)
+	}
+}
+
+func TestRender_CustomTemplates(t *testing.T) {
+	d := Digest{Key: "deploys", Items: items(12), Settings: Settings{
+		TitleTemplate: `{{.Count}} {{upper .Key}}`,
+		BodyTemplate:  `{{len .Shown}} shown, {{.More}} more, last at {{.Last.Format "15:04"}}`,
+	}}
+	title, body, err := d.Render(map[string]interface{}{"upper": strings.ToUpper})
+	if err != nil {
+		t.Fatalf("Render() error: %v", err)
+	}
+	if title != "12 DEPLOYS" || body != "10 shown, 2 more, last at 12:11" {
+		t.Errorf("Render() = %q, %q", title, body)
+	}
+
+	bad := Settings{TitleTemplate: "{{.Count"}
+	if err := bad.Validate(nil); err == nil {
+		t.Error("expected an error for an invalid template")
+	}
+}
+
+func TestDue(t *testing.T) {
+	d := Digest{Key: "k", Items: items(3), Settings: Settings{MaxItems: 5, MaxAge: 10 * time.Minute}}
+	if d.Due(start.Add(5 * time.Minute)) {
+		t.Error("expected a small, young digest not to be due")
+	}
+	if !d.Due(start.Add(10 * time.Minute)) {
+		t.Error("expected the digest to be due once its oldest item is old enough")
+	}
+	d.Items = items(5)
+	if !d.Due(start) {
+		t.Error("expected the digest to be due once it holds MaxItems")
+	}
+	if (Digest{Settings: Settings{MaxItems: 1}}).Due(start) {
+		t.Error("expected an empty digest never to be due")
+	}
+}
+
+func TestSettings_JSON(t *testing.T) {
+	s := Settings{Show: 3, MaxItems: 20, MaxAge: 10 * time.Minute, Sound: "pop"}
+	data, err := json.Marshal(s)
+	if err != nil {
+		t.Fatalf("Marshal() error: %v", err)
+	}
+	if !strings.Contains(string(data), `"maxAge":"10m0s"`) {
+		t.Errorf("expected a readable maxAge, got %s", data)
+	}
+	var got Settings
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatalf("Unmarshal() error: %v", err)
+	}
+	if got != s {
+		t.Errorf("round trip = %+v, want %+v", got, s)
+	}
+}
+
+func TestStore_AddTakeRestore(t *testing.T) {
+	s := Open(filepath.Join(t.TempDir(), "digests.json"))
+	for i, item := range items(3) {
+		_, err := s.Add("deploys", item, func(set *Settings) {
+			if i == 0 {
+				set.MaxItems = 3
+			}
+		})
+		if err != nil {
+			t.Fatalf("Add() error: %v", err)
+		}
+	}
+	s.Add("alerts", Item{Body: "cpu", Time: start}, nil)
+
+	taken, err := s.Take(func(d Digest) bool { return d.Due(start) })
+	if err != nil {
+		t.Fatalf("Take() error: %v", err)
+	}
+	if len(taken) != 1 || taken[0].Key != "deploys" || len(taken[0].Items) != 3 {
+		t.Fatalf("expected only deploys to be taken, got %+v", taken)
+	}
+
+	s.Add("deploys", Item{Body: "deploy 4", Time: start.Add(time.Hour)}, nil)
+	if err := s.Restore(taken[0]); err != nil {
+		t.Fatalf("Restore() error: %v", err)
+	}
+	d, err := s.TakeKey("deploys")
+	if err != nil {
+		t.Fatalf("TakeKey() error: %v", err)
+	}
+	var bodies []string
+	for _, item := range d.Items {
+		bodies = append(bodies, item.Body)
+	}
+	if got := strings.Join(bodies, ","); got != "deploy 1,deploy 2,deploy 3,deploy 4" {
+		t.Errorf("items after restore = %s", got)
+	}
+	if d.Settings.MaxItems != 3 {
+		t.Errorf("expected settings to be kept, got %+v", d.Settings)
+	}
+
+	if _, err := s.TakeKey("missing"); !errors.Is(err, ErrUnknown) {
+		t.Errorf("TakeKey(missing) error = %v, want ErrUnknown", err)
+	}
+}
+
+func TestSettings_Validate(t *testing.T) {
+	if err := (Settings{TitleTemplate: `{{.Key}}`}).Validate(nil); err != nil {
+		t.Errorf("Validate() error: %v", err)
+	}
+	if err := (Settings{TitleTemplate: `{{.Nope}}`}).Validate(nil); err == nil {
+		t.Error("expected a template with a missing field to fail validation")
+	}
+	if err := (Settings{BodyTemplate: `{{`}).Validate(nil); err == nil {
+		t.Error("expected a template that doesn't parse to fail validation")
+	}
+}
+
+func TestStore_Park(t *testing.T) {
+	s := Open(filepath.Join(t.TempDir(), "digests.json"))
+	s.Add("deploys", Item{Body: "deploy 1", Time: start}, func(set *Settings) { set.MaxItems = 1 })
+
+	taken, _ := s.Take(func(d Digest) bool { return d.Due(start) })
+	if err := s.Park(taken[0], "bad request"); err != nil {
+		t.Fatalf("Park() error: %v", err)
+	}
+	list, _ := s.List()
+	if len(list[0].Items) != 1 || list[0].Error != "bad request" || list[0].Due(start) {
+		t.Fatalf("expected a parked digest that is not due, got %+v", list[0])
+	}
+
+	// Adding with the same settings keeps it parked; changing them doesn't.
+	s.Add("deploys", Item{Body: "deploy 2", Time: start}, func(set *Settings) { set.MaxItems = 1 })
+	if list, _ = s.List(); list[0].Error == "" {
+		t.Error("expected the digest to stay parked")
+	}
+	s.Add("deploys", Item{Body: "deploy 3", Time: start}, func(set *Settings) { set.Sound = "pop" })
+	if list, _ = s.List(); list[0].Error != "" || !list[0].Due(start) {
+		t.Errorf("expected new settings to unpark the digest, got %+v", list[0])
+	}
+}
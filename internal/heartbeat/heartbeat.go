@@ -0,0 +1,183 @@
+package heartbeat
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/techulus/push-cli/internal/state"
+)
+
+var ErrUnknown = errors.New("unknown heartbeat")
+
+// Heartbeat is a job that is expected to ping at least once every Period.
+// It is late once Period plus Grace has passed since the last ping.
+type Heartbeat struct {
+	Name     string
+	Period   time.Duration
+	Grace    time.Duration
+	LastPing time.Time
+	// Down is set once a missed heartbeat has been reported, and cleared
+	// once its recovery has been.
+	Down   bool
+	DownAt time.Time
+}
+
+type heartbeatJSON struct {
+	Name     string    `json:"name"`
+	Period   string    `json:"period"`
+	Grace    string    `json:"grace"`
+	LastPing time.Time `json:"lastPing"`
+	Down     bool      `json:"down,omitempty"`
+	DownAt   time.Time `json:"downAt"`
+}
+
+// MarshalJSON writes durations as strings like "24h0m0s" so the state file
+// and --output json are readable.
+func (h Heartbeat) MarshalJSON() ([]byte, error) {
+	return json.Marshal(heartbeatJSON{
+		Name:     h.Name,
+		Period:   h.Period.String(),
+		Grace:    h.Grace.String(),
+		LastPing: h.LastPing,
+		Down:     h.Down,
+		DownAt:   h.DownAt,
+	})
+}
+
+func (h *Heartbeat) UnmarshalJSON(data []byte) error {
+	var raw heartbeatJSON
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	period, err := time.ParseDuration(raw.Period)
+	if err != nil {
+		return fmt.Errorf("heartbeat %s: invalid period: %w", raw.Name, err)
+	}
+	grace, err := time.ParseDuration(raw.Grace)
+	if err != nil {
+		return fmt.Errorf("heartbeat %s: invalid grace: %w", raw.Name, err)
+	}
+	*h = Heartbeat{Name: raw.Name, Period: period, Grace: grace, LastPing: raw.LastPing, Down: raw.Down, DownAt: raw.DownAt}
+	return nil
+}
+
+// Deadline is when the heartbeat becomes late.
+func (h Heartbeat) Deadline() time.Time {
+	return h.LastPing.Add(h.Period + h.Grace)
+}
+
+func (h Heartbeat) Late(now time.Time) bool {
+	return now.After(h.Deadline())
+}
+
+// Store keeps heartbeats in a JSON file shared by every push process.
+type Store struct {
+	path string
+}
+
+func Open(path string) *Store {
+	return &Store{path: path}
+}
+
+func (s *Store) update(fn func(map[string]*Heartbeat) error) error {
+	beats := map[string]*Heartbeat{}
+	return state.Update(s.path, &beats, func() error { return fn(beats) })
+}
+
+// Ping records a ping for name. Period and grace are required the first
+// time; later pings keep the stored values unless new ones are given.
+func (s *Store) Ping(name string, period, grace time.Duration, now time.Time) (Heartbeat, error) {
+	var h Heartbeat
+	err := s.update(func(beats map[string]*Heartbeat) error {
+		b := beats[name]
+		if b == nil {
+			if period <= 0 {
+				return fmt.Errorf("%w %q, pass --period on its first ping", ErrUnknown, name)
+			}
+			b = &Heartbeat{Name: name}
+			beats[name] = b
+		}
+		if period > 0 {
+			b.Period = period
+		}
+		if grace > 0 {
+			b.Grace = grace
+		}
+		b.LastPing = now
+		h = *b
+		return nil
+	})
+	return h, err
+}
+
+// List returns every heartbeat, sorted by name.
+func (s *Store) List() ([]Heartbeat, error) {
+	beats := map[string]*Heartbeat{}
+	if err := state.Load(s.path, &beats); err != nil {
+		return nil, err
+	}
+	list := make([]Heartbeat, 0, len(beats))
+	for _, b := range beats {
+		list = append(list, *b)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list, nil
+}
+
+func (s *Store) Remove(name string) error {
+	return s.update(func(beats map[string]*Heartbeat) error {
+		if beats[name] == nil {
+			return fmt.Errorf("%w %q", ErrUnknown, name)
+		}
+		delete(beats, name)
+		return nil
+	})
+}
+
+// Change is a heartbeat whose state needs reporting.
+type Change struct {
+	Heartbeat
+	// Recovered is true when a heartbeat reported as down has pinged again,
+	// and false when it has just become late.
+	Recovered bool
+}
+
+// Changes returns the heartbeats that became late or recovered since the
+// last call to Mark. It doesn't modify the store, so a notification that
+// fails to send is retried by the next check.
+func (s *Store) Changes(now time.Time) ([]Change, error) {
+	list, err := s.List()
+	if err != nil {
+		return nil, err
+	}
+	var changes []Change
+	for _, h := range list {
+		switch late := h.Late(now); {
+		case late && !h.Down:
+			changes = append(changes, Change{Heartbeat: h})
+		case !late && h.Down:
+			changes = append(changes, Change{Heartbeat: h, Recovered: true})
+		}
+	}
+	return changes, nil
+}
+
+// Mark records that c has been reported.
+func (s *Store) Mark(c Change, now time.Time) error {
+	return s.update(func(beats map[string]*Heartbeat) error {
+		b := beats[c.Name]
+		if b == nil {
+			return nil
+		}
+		b.Down = !c.Recovered
+		if b.Down {
+			b.DownAt = now
+		} else {
+			b.DownAt = time.Time{}
+		}
+		return nil
+	})
+}
@@ -0,0 +1,103 @@
+package heartbeat
+
+import (
+	"encoding/json"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPing_RequiresPeriodFirst(t *testing.T) {
+	s := Open(filepath.Join(t.TempDir(), "heartbeats.json"))
+	now := time.Now()
+
+	if _, err := s.Ping("backup", 0, 0, now); !errors.Is(err, ErrUnknown) {
+		t.Fatalf("expected ErrUnknown, got %v", err)
+	}
+	if _, err := s.Ping("backup", time.Hour, 10*time.Minute, now); err != nil {
+		t.Fatalf("Ping() error: %v", err)
+	}
+
+	h, err := s.Ping("backup", 0, 0, now.Add(time.Minute))
+	if err != nil {
+		t.Fatalf("Ping() error: %v", err)
+	}
+	if h.Period != time.Hour || h.Grace != 10*time.Minute || !h.LastPing.Equal(now.Add(time.Minute)) {
+		t.Errorf("expected stored period and grace to be kept, got %+v", h)
+	}
+}
+
+func TestChanges_LateAndRecovered(t *testing.T) {
+	s := Open(filepath.Join(t.TempDir(), "heartbeats.json"))
+	start := time.Now()
+	s.Ping("backup", time.Hour, 10*time.Minute, start)
+	s.Ping("sync", 24*time.Hour, 0, start)
+
+	changes, _ := s.Changes(start.Add(time.Hour + 5*time.Minute))
+	if len(changes) != 0 {
+		t.Fatalf("expected no changes within grace, got %v", changes)
+	}
+
+	late := start.Add(2 * time.Hour)
+	changes, _ = s.Changes(late)
+	if len(changes) != 1 || changes[0].Name != "backup" || changes[0].Recovered {
+		t.Fatalf("expected backup to be late, got %+v", changes)
+	}
+
+	// Until it is marked as reported, the change is returned again.
+	changes, _ = s.Changes(late)
+	if len(changes) != 1 {
+		t.Fatalf("expected the unreported change again, got %v", changes)
+	}
+	if err := s.Mark(changes[0], late); err != nil {
+		t.Fatalf("Mark() error: %v", err)
+	}
+	if changes, _ = s.Changes(late.Add(time.Hour)); len(changes) != 0 {
+		t.Fatalf("expected no repeat once reported, got %v", changes)
+	}
+
+	s.Ping("backup", 0, 0, late.Add(2*time.Hour))
+	changes, _ = s.Changes(late.Add(2 * time.Hour))
+	if len(changes) != 1 || !changes[0].Recovered {
+		t.Fatalf("expected backup to recover, got %+v", changes)
+	}
+	s.Mark(changes[0], late.Add(2*time.Hour))
+
+	list, _ := s.List()
+	if len(list) != 2 || list[0].Name != "backup" || list[0].Down {
+		t.Errorf("unexpected heartbeats: %+v", list)
+	}
+}
+
+func TestRemove(t *testing.T) {
+	s := Open(filepath.Join(t.TempDir(), "heartbeats.json"))
+	s.Ping("backup", time.Hour, 0, time.Now())
+
+	if err := s.Remove("backup"); err != nil {
+		t.Fatalf("Remove() error: %v", err)
+	}
+	if err := s.Remove("backup"); !errors.Is(err, ErrUnknown) {
+		t.Errorf("expected ErrUnknown, got %v", err)
+	}
+}
+
+func TestHeartbeat_JSON(t *testing.T) {
+	h := Heartbeat{Name: "backup", Period: 24 * time.Hour, Grace: 30 * time.Minute, LastPing: time.Date(2024, 5, 1, 3, 0, 0, 0, time.UTC)}
+	data, err := json.Marshal(h)
+	if err != nil {
+		t.Fatalf("Marshal() error: %v", err)
+	}
+	if !strings.Contains(string(data), `"period":"24h0m0s"`) || !strings.Contains(string(data), `"grace":"30m0s"`) {
+		t.Errorf("expected readable durations, got %s", data)
+	}
+
+	var decoded Heartbeat
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("Unmarshal() error: %v", err)
+	}
+	if decoded != h {
+		t.Errorf("expected %+v after round trip, got %+v", h, decoded)
+	}
+}
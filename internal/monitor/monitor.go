@@ -0,0 +1,321 @@
+package monitor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	defaultInterval  = 30 * time.Second
+	defaultTimeout   = 10 * time.Second
+	defaultThreshold = 3
+	maxBodyRead      = 1 << 20
+)
+
+// Check is one HTTP or TCP check. Exactly one of URL and TCP is set.
+type Check struct {
+	Name         string        `yaml:"name"`
+	URL          string        `yaml:"url"`
+	TCP          string        `yaml:"tcp"`
+	Interval     time.Duration `yaml:"interval"`
+	Timeout      time.Duration `yaml:"timeout"`
+	ExpectStatus int           `yaml:"expect_status"`
+	ExpectBody   string        `yaml:"expect_body"`
+	// Threshold is how many checks in a row must fail before the target is
+	// reported down.
+	Threshold int `yaml:"threshold"`
+
+	bodyRe *regexp.Regexp
+}
+
+// File is the YAML format accepted by Load. Defaults fill in any field a
+// check leaves unset.
+type File struct {
+	Defaults Check   `yaml:"defaults"`
+	Checks   []Check `yaml:"checks"`
+}
+
+func Load(path string) ([]*Check, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var f File
+	dec := yaml.NewDecoder(file)
+	dec.KnownFields(true)
+	if err := dec.Decode(&f); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("parsing %s: %w", path, err)
+	}
+	if len(f.Checks) == 0 {
+		return nil, fmt.Errorf("%s: no checks defined", path)
+	}
+
+	checks := make([]*Check, len(f.Checks))
+	names := map[string]bool{}
+	for i := range f.Checks {
+		c := &f.Checks[i]
+		checks[i] = c
+		c.applyDefaults(f.Defaults)
+		if err := c.Prepare(); err != nil {
+			return nil, fmt.Errorf("%s: check %d: %w", path, i+1, err)
+		}
+		if names[c.Name] {
+			return nil, fmt.Errorf("%s: duplicate check name %q", path, c.Name)
+		}
+		names[c.Name] = true
+	}
+	return checks, nil
+}
+
+func (c *Check) applyDefaults(d Check) {
+	if c.Interval == 0 {
+		c.Interval = d.Interval
+	}
+	if c.Timeout == 0 {
+		c.Timeout = d.Timeout
+	}
+	if c.ExpectStatus == 0 {
+		c.ExpectStatus = d.ExpectStatus
+	}
+	if c.Threshold == 0 {
+		c.Threshold = d.Threshold
+	}
+}
+
+// Prepare validates c and fills in defaults. It must be called before Run.
+func (c *Check) Prepare() error {
+	if (c.URL == "") == (c.TCP == "") {
+		return errors.New("set exactly one of url and tcp")
+	}
+	if c.URL != "" {
+		if !strings.HasPrefix(c.URL, "http://") && !strings.HasPrefix(c.URL, "https://") {
+			return fmt.Errorf("url %q must start with http:// or https://", c.URL)
+		}
+	} else {
+		if c.ExpectStatus != 0 || c.ExpectBody != "" {
+			return errors.New("expect_status and expect_body only apply to url checks")
+		}
+		if _, _, err := net.SplitHostPort(c.TCP); err != nil {
+			return fmt.Errorf("invalid tcp address %q: %w", c.TCP, err)
+		}
+	}
+	if c.ExpectBody != "" {
+		re, err := regexp.Compile(c.ExpectBody)
+		if err != nil {
+			return fmt.Errorf("invalid expect_body: %w", err)
+		}
+		c.bodyRe = re
+	}
+
+	if c.Name == "" {
+		c.Name = c.URL + c.TCP
+	}
+	if c.Interval <= 0 {
+		c.Interval = defaultInterval
+	}
+	if c.Timeout <= 0 {
+		c.Timeout = defaultTimeout
+	}
+	if c.Threshold <= 0 {
+		c.Threshold = defaultThreshold
+	}
+	return nil
+}
+
+// Result is the outcome of running a check once.
+type Result struct {
+	Time    time.Time
+	Latency time.Duration
+	Status  int
+	Err     error
+}
+
+func (r Result) Up() bool {
+	return r.Err == nil
+}
+
+// Run performs c once.
+func (c *Check) Run(ctx context.Context, client *http.Client) Result {
+	ctx, cancel := context.WithTimeout(ctx, c.Timeout)
+	defer cancel()
+
+	start := time.Now()
+	var r Result
+	if c.URL != "" {
+		r = c.runHTTP(ctx, client)
+	} else {
+		var d net.Dialer
+		conn, err := d.DialContext(ctx, "tcp", c.TCP)
+		if err == nil {
+			conn.Close()
+		}
+		r.Err = err
+	}
+	r.Time = start
+	r.Latency = time.Since(start)
+	return r
+}
+
+func (c *Check) runHTTP(ctx context.Context, client *http.Client) Result {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.URL, nil)
+	if err != nil {
+		return Result{Err: err}
+	}
+	req.Header.Set("User-Agent", "push-cli-monitor")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return Result{Err: err}
+	}
+	defer resp.Body.Close()
+
+	r := Result{Status: resp.StatusCode}
+	if c.ExpectStatus != 0 && resp.StatusCode != c.ExpectStatus {
+		r.Err = fmt.Errorf("status %d, expected %d", resp.StatusCode, c.ExpectStatus)
+	} else if c.ExpectStatus == 0 && (resp.StatusCode < 200 || resp.StatusCode > 299) {
+		r.Err = fmt.Errorf("status %d", resp.StatusCode)
+	}
+	if r.Err != nil || c.bodyRe == nil {
+		return r
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxBodyRead))
+	if err != nil {
+		r.Err = fmt.Errorf("reading body: %w", err)
+	} else if !c.bodyRe.Match(body) {
+		r.Err = fmt.Errorf("body does not match %q", c.ExpectBody)
+	}
+	return r
+}
+
+// Transition is a change of a check's reported state.
+type Transition int
+
+const (
+	NoChange Transition = iota
+	WentDown
+	WentUp
+)
+
+// Tracker turns results into transitions. A target is assumed up at startup,
+// so the first success is not reported but failing Threshold times is.
+// A transition is returned by every Observe until it is passed to Mark, so a
+// notification that fails to send is retried on the next check.
+type Tracker struct {
+	threshold int
+	down      bool
+	reported  bool
+	failures  int
+	// DownSince is when the first failed check of the current or latest
+	// outage ran.
+	DownSince time.Time
+}
+
+func NewTracker(threshold int) *Tracker {
+	if threshold < 1 {
+		threshold = 1
+	}
+	return &Tracker{threshold: threshold}
+}
+
+func (t *Tracker) Observe(r Result) Transition {
+	if r.Up() {
+		t.failures = 0
+		t.down = false
+	} else if !t.down {
+		t.failures++
+		if t.failures == 1 {
+			t.DownSince = r.Time
+		}
+		t.down = t.failures >= t.threshold
+	}
+
+	switch {
+	case t.down == t.reported:
+		return NoChange
+	case t.down:
+		return WentDown
+	}
+	return WentUp
+}
+
+// Mark records that tr has been reported.
+func (t *Tracker) Mark(tr Transition) {
+	if tr != NoChange {
+		t.reported = tr == WentDown
+	}
+}
+
+// Failures returns the number of failed checks in a row.
+func (t *Tracker) Failures() int {
+	return t.failures
+}
+
+// Event describes a transition for notification.
+type Event struct {
+	Check      *Check
+	Transition Transition
+	Result     Result
+	// Failures is the number of failed checks in a row when going down.
+	Failures int
+	// Downtime is how long the target was down when coming back up.
+	Downtime time.Duration
+}
+
+// Run checks every target on its interval until ctx is done. onResult, if
+// set, sees every result; onEvent sees only state transitions, and sees the
+// same one again after the next check if it returns an error. Both may be
+// called from several goroutines at once.
+func Run(ctx context.Context, checks []*Check, client *http.Client, onResult func(*Check, Result), onEvent func(Event) error) {
+	var wg sync.WaitGroup
+	for _, c := range checks {
+		wg.Add(1)
+		go func(c *Check) {
+			defer wg.Done()
+			tracker := NewTracker(c.Threshold)
+			ticker := time.NewTicker(c.Interval)
+			defer ticker.Stop()
+			for {
+				r := c.Run(ctx, client)
+				if ctx.Err() != nil {
+					return
+				}
+				if onResult != nil {
+					onResult(c, r)
+				}
+
+				var err error
+				tr := tracker.Observe(r)
+				switch tr {
+				case WentDown:
+					err = onEvent(Event{Check: c, Transition: WentDown, Result: r, Failures: tracker.Failures()})
+				case WentUp:
+					err = onEvent(Event{Check: c, Transition: WentUp, Result: r, Downtime: r.Time.Sub(tracker.DownSince)})
+				}
+				if err == nil {
+					tracker.Mark(tr)
+				}
+
+				select {
+				case <-ctx.Done():
+					return
+				case <-ticker.C:
+				}
+			}
+		}(c)
+	}
+	wg.Wait()
+}
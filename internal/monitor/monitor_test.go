@@ -0,0 +1,230 @@
+package monitor
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func prepared(t *testing.T, c Check) *Check {
+	t.Helper()
+	if err := c.Prepare(); err != nil {
+		t.Fatalf("Prepare() error: %v", err)
+	}
+	return &c
+}
+
+func TestCheck_HTTP(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/ok":
+			w.Write([]byte(`{"status":"ok"}`))
+		case "/degraded":
+			w.Write([]byte(`{"status":"degraded"}`))
+		default:
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name  string
+		check Check
+		up    bool
+	}{
+		{"2xx by default", Check{URL: server.URL + "/ok"}, true},
+		{"5xx", Check{URL: server.URL + "/down"}, false},
+		{"expected status", Check{URL: server.URL + "/down", ExpectStatus: 503}, true},
+		{"unexpected status", Check{URL: server.URL + "/ok", ExpectStatus: 204}, false},
+		{"body matches", Check{URL: server.URL + "/ok", ExpectBody: `"status":"ok"`}, true},
+		{"body mismatch", Check{URL: server.URL + "/degraded", ExpectBody: `"status":"ok"`}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := prepared(t, tt.check).Run(context.Background(), server.Client())
+			if r.Up() != tt.up {
+				t.Errorf("expected up=%v, got error %v", tt.up, r.Err)
+			}
+			if r.Latency <= 0 {
+				t.Error("expected latency to be measured")
+			}
+		})
+	}
+}
+
+func TestCheck_TCP(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	addr := ln.Addr().String()
+
+	c := prepared(t, Check{TCP: addr, Timeout: time.Second})
+	if r := c.Run(context.Background(), nil); !r.Up() {
+		t.Errorf("expected open port to be up, got %v", r.Err)
+	}
+
+	ln.Close()
+	if r := c.Run(context.Background(), nil); r.Up() {
+		t.Error("expected closed port to be down")
+	}
+}
+
+func TestCheck_PrepareErrors(t *testing.T) {
+	tests := []Check{
+		{},
+		{URL: "https://a", TCP: "b:1"},
+		{URL: "ftp://example.com"},
+		{TCP: "no-port"},
+		{TCP: "db:5432", ExpectStatus: 200},
+		{URL: "https://example.com", ExpectBody: "("},
+	}
+	for _, c := range tests {
+		if err := c.Prepare(); err == nil {
+			t.Errorf("expected Prepare(%+v) to fail", c)
+		}
+	}
+}
+
+func TestTracker(t *testing.T) {
+	tracker := NewTracker(2)
+	start := time.Now()
+	up := Result{Time: start}
+	down := func(d time.Duration) Result { return Result{Time: start.Add(d), Err: errors.New("down")} }
+
+	steps := []struct {
+		result Result
+		want   Transition
+	}{
+		{up, NoChange},
+		{down(time.Minute), NoChange},
+		{Result{Time: start.Add(2 * time.Minute)}, NoChange},
+		{down(3 * time.Minute), NoChange},
+		{down(4 * time.Minute), WentDown},
+		{down(5 * time.Minute), NoChange},
+		{Result{Time: start.Add(10 * time.Minute)}, WentUp},
+		{Result{Time: start.Add(11 * time.Minute)}, NoChange},
+	}
+	for i, s := range steps {
+		got := tracker.Observe(s.result)
+		if got != s.want {
+			t.Fatalf("step %d: expected transition %d, got %d", i, s.want, got)
+		}
+		tracker.Mark(got)
+		if s.want == WentDown && tracker.Failures() != 2 {
+			t.Errorf("expected 2 failures, got %d", tracker.Failures())
+		}
+	}
+	if !tracker.DownSince.Equal(start.Add(3 * time.Minute)) {
+		t.Errorf("expected outage to start at the first failure, got %v", tracker.DownSince)
+	}
+
+	// A transition that isn't marked as reported is returned again.
+	tracker.Observe(down(20 * time.Minute))
+	tracker.Observe(down(21 * time.Minute))
+	if got := tracker.Observe(down(22 * time.Minute)); got != WentDown {
+		t.Errorf("expected an unreported outage to be returned again, got %d", got)
+	}
+}
+
+func TestLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "checks.yaml")
+	os.WriteFile(path, []byte(`
+defaults:
+  interval: 1m
+  threshold: 5
+checks:
+  - name: api
+    url: https://api.example.com/health
+    expect_status: 200
+    expect_body: ok
+  - tcp: db.internal:5432
+    interval: 10s
+`), 0644)
+
+	checks, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load() error: %v", err)
+	}
+	if len(checks) != 2 {
+		t.Fatalf("expected 2 checks, got %d", len(checks))
+	}
+	if checks[0].Name != "api" || checks[0].Interval != time.Minute || checks[0].Threshold != 5 || checks[0].ExpectStatus != 200 {
+		t.Errorf("unexpected first check: %+v", checks[0])
+	}
+	if checks[1].Name != "db.internal:5432" || checks[1].Interval != 10*time.Second || checks[1].Timeout != defaultTimeout {
+		t.Errorf("unexpected second check: %+v", checks[1])
+	}
+}
+
+func TestLoad_Errors(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"empty.yaml":     ``,
+		"unknown.yaml":   "checks:\n  - url: https://a\n    retries: 3\n",
+		"duplicate.yaml": "checks:\n  - name: a\n    url: https://a\n  - name: a\n    tcp: b:1\n",
+		"invalid.yaml":   "checks:\n  - url: https://a\n    tcp: b:1\n",
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		os.WriteFile(path, []byte(content), 0644)
+		if _, err := Load(path); err == nil {
+			t.Errorf("expected Load(%s) to fail", name)
+		}
+	}
+}
+
+func TestRun_ReportsTransitions(t *testing.T) {
+	var mu sync.Mutex
+	healthy := false
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		if !healthy {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer server.Close()
+
+	c := prepared(t, Check{Name: "api", URL: server.URL, Interval: 10 * time.Millisecond, Threshold: 2})
+	events := make(chan Event, 10)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		failed := false
+		Run(ctx, []*Check{c}, server.Client(), nil, func(e Event) error {
+			// Fail the first down notification, which must then be repeated.
+			if e.Transition == WentDown && !failed {
+				failed = true
+				return errors.New("send failed")
+			}
+			events <- e
+			return nil
+		})
+		close(done)
+	}()
+
+	e := <-events
+	if e.Transition != WentDown || e.Failures != 2 || !strings.Contains(e.Result.Err.Error(), "500") {
+		t.Errorf("unexpected down event: %+v", e)
+	}
+
+	mu.Lock()
+	healthy = true
+	mu.Unlock()
+	e = <-events
+	if e.Transition != WentUp || e.Downtime <= 0 {
+		t.Errorf("unexpected up event: %+v", e)
+	}
+
+	cancel()
+	<-done
+}
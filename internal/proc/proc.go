@@ -0,0 +1,47 @@
+package proc
+
+import (
+	"errors"
+	"time"
+)
+
+var ErrNotFound = errors.New("process not found")
+
+// Process identifies a running process. Started is for display; a later
+// process that reuses the PID is told apart by start, the start time as the
+// platform reports it, which doesn't move when the wall clock is adjusted.
+type Process struct {
+	PID     int
+	Name    string
+	Started time.Time
+
+	start int64
+}
+
+// State is what polling a process found.
+type State struct {
+	Running bool
+	// ExitCode is only known when the process was observed after exiting
+	// but before its parent reaped it.
+	ExitCode  int
+	ExitKnown bool
+}
+
+// Get returns the running process with pid, or ErrNotFound.
+func Get(pid int) (Process, error) {
+	return get(pid)
+}
+
+// FindByName returns the running processes whose executable name is name.
+func FindByName(name string) ([]Process, error) {
+	return findByName(name)
+}
+
+// Poll reports whether p is still running.
+func Poll(p Process) (State, error) {
+	current, state, err := lookup(p.PID)
+	if errors.Is(err, ErrNotFound) || (err == nil && current.start != p.start) {
+		return State{}, nil
+	}
+	return state, err
+}
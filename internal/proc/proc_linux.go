@@ -0,0 +1,165 @@
+//go:build linux
+
+package proc
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+var procRoot = "/proc"
+
+// clockTicks is USER_HZ, the unit of start times in /proc/<pid>/stat. It is
+// 100 on every Linux architecture Go supports. Start times count from boot,
+// so they only become wall-clock times through btime, which NTP can shift.
+const clockTicks = 100
+
+func get(pid int) (Process, error) {
+	p, state, err := lookup(pid)
+	if err != nil {
+		return p, err
+	}
+	if !state.Running {
+		return p, fmt.Errorf("process %d has already exited: %w", pid, ErrNotFound)
+	}
+	return p, nil
+}
+
+func lookup(pid int) (Process, State, error) {
+	dir := filepath.Join(procRoot, strconv.Itoa(pid))
+	raw, err := os.ReadFile(filepath.Join(dir, "stat"))
+	if errors.Is(err, os.ErrNotExist) {
+		return Process{}, State{}, ErrNotFound
+	}
+	if err != nil {
+		return Process{}, State{}, err
+	}
+
+	// The command name is in parentheses and may itself contain spaces and
+	// parentheses, so split the remaining fields after the last ')'.
+	open, end := bytes.IndexByte(raw, '('), bytes.LastIndexByte(raw, ')')
+	if open < 0 || end < open {
+		return Process{}, State{}, fmt.Errorf("parsing %s/stat: malformed", dir)
+	}
+	comm := string(raw[open+1 : end])
+	fields := strings.Fields(string(raw[end+1:]))
+	// fields[0] is field 3 (state) in proc(5); field n is fields[n-3].
+	if len(fields) < 20 {
+		return Process{}, State{}, fmt.Errorf("parsing %s/stat: too few fields", dir)
+	}
+
+	ticks, err := strconv.ParseInt(fields[19], 10, 64)
+	if err != nil {
+		return Process{}, State{}, fmt.Errorf("parsing %s/stat start time: %w", dir, err)
+	}
+	boot, err := bootTime()
+	if err != nil {
+		return Process{}, State{}, err
+	}
+	started := boot.Add(time.Duration(ticks) * time.Second / clockTicks)
+
+	p := Process{PID: pid, Name: commandName(dir, comm), Started: started, start: ticks}
+	if fields[0] != "Z" && fields[0] != "X" {
+		return p, State{Running: true}, nil
+	}
+
+	// A zombie's wait status is in field 52. The kernel writes 0 there when
+	// we may not ptrace the process, so it only means something for our own.
+	var state State
+	if len(fields) >= 50 && ownProcess(dir) {
+		if status, err := strconv.Atoi(fields[49]); err == nil {
+			state.ExitCode, state.ExitKnown = decodeWaitStatus(status), true
+		}
+	}
+	return p, state, nil
+}
+
+var geteuid = os.Geteuid
+
+// ownProcess reports whether the process in dir runs as our user, or we are
+// root, which is when the kernel lets us read its exit code.
+func ownProcess(dir string) bool {
+	euid := geteuid()
+	if euid == 0 {
+		return true
+	}
+	info, err := os.Stat(dir)
+	if err != nil {
+		return false
+	}
+	st, ok := info.Sys().(*syscall.Stat_t)
+	return ok && int(st.Uid) == euid
+}
+
+// decodeWaitStatus converts a wait(2) status to a shell-style exit code.
+func decodeWaitStatus(status int) int {
+	if sig := status & 0x7f; sig != 0 {
+		return 128 + sig
+	}
+	return (status >> 8) & 0xff
+}
+
+// commandName prefers the base name of argv[0], since comm is truncated to
+// 15 characters.
+func commandName(dir, comm string) string {
+	raw, err := os.ReadFile(filepath.Join(dir, "cmdline"))
+	if err != nil || len(raw) == 0 {
+		return comm
+	}
+	argv0, _, _ := bytes.Cut(raw, []byte{0})
+	name := filepath.Base(string(argv0))
+	// Processes that rewrite their title (e.g. "nginx: worker process")
+	// don't have a usable argv[0].
+	if name == "" || name == "." || strings.ContainsAny(name, " :") {
+		return comm
+	}
+	return name
+}
+
+func bootTime() (time.Time, error) {
+	raw, err := os.ReadFile(filepath.Join(procRoot, "stat"))
+	if err != nil {
+		return time.Time{}, err
+	}
+	for _, line := range strings.Split(string(raw), "\n") {
+		if value, ok := strings.CutPrefix(line, "btime "); ok {
+			sec, err := strconv.ParseInt(strings.TrimSpace(value), 10, 64)
+			if err != nil {
+				return time.Time{}, fmt.Errorf("parsing boot time: %w", err)
+			}
+			return time.Unix(sec, 0), nil
+		}
+	}
+	return time.Time{}, errors.New("boot time not found in /proc/stat")
+}
+
+func findByName(name string) ([]Process, error) {
+	entries, err := os.ReadDir(procRoot)
+	if err != nil {
+		return nil, err
+	}
+
+	var procs []Process
+	for _, e := range entries {
+		pid, err := strconv.Atoi(e.Name())
+		if err != nil || pid == os.Getpid() {
+			continue
+		}
+		p, state, err := lookup(pid)
+		// Processes come and go while scanning; skip any that vanished.
+		if err != nil || !state.Running {
+			continue
+		}
+		if p.Name == name {
+			procs = append(procs, p)
+		}
+	}
+	return procs, nil
+}
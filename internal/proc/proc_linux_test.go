@@ -0,0 +1,184 @@
+//go:build linux
+
+package proc
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func fakeProc(t *testing.T, pid, stat, cmdline string) {
+	t.Helper()
+	root := t.TempDir()
+	procRoot = root
+	t.Cleanup(func() { procRoot = "/proc" })
+
+	os.WriteFile(filepath.Join(root, "stat"), []byte("cpu  1 2 3\nbtime 1700000000\n"), 0644)
+	dir := filepath.Join(root, pid)
+	os.Mkdir(dir, 0755)
+	os.WriteFile(filepath.Join(dir, "stat"), []byte(stat), 0644)
+	os.WriteFile(filepath.Join(dir, "cmdline"), []byte(cmdline), 0644)
+}
+
+// statLine builds a /proc/<pid>/stat line with the given state, start time
+// (field 22) and exit code (field 52).
+func statLine(pid, comm, state, start, exit string) string {
+	fields := make([]string, 50)
+	for i := range fields {
+		fields[i] = "0"
+	}
+	fields[0] = state
+	fields[19] = start
+	fields[49] = exit
+	return pid + " (" + comm + ") " + strings.Join(fields, " ")
+}
+
+func TestLookup_ParsesStat(t *testing.T) {
+	fakeProc(t, "42", statLine("42", "my (odd) job", "S", "12345", "0"), "")
+
+	p, state, err := lookup(42)
+	if err != nil {
+		t.Fatalf("lookup() error: %v", err)
+	}
+	if p.Name != "my (odd) job" {
+		t.Errorf("expected name from comm, got %q", p.Name)
+	}
+	want := time.Unix(1700000000, 0).Add(123450 * time.Millisecond)
+	if !p.Started.Equal(want) || p.start != 12345 {
+		t.Errorf("expected start %v (12345 ticks), got %v (%d)", want, p.Started, p.start)
+	}
+	if !state.Running {
+		t.Error("expected process to be running")
+	}
+}
+
+func TestLookup_ZombieExitCode(t *testing.T) {
+	fakeProc(t, "42", statLine("42", "backup", "Z", "100", "768"), "/usr/local/bin/backup.sh\x00--full\x00")
+
+	p, state, err := lookup(42)
+	if err != nil {
+		t.Fatalf("lookup() error: %v", err)
+	}
+	if p.Name != "backup.sh" {
+		t.Errorf("expected name from cmdline, got %q", p.Name)
+	}
+	if state.Running || !state.ExitKnown || state.ExitCode != 3 {
+		t.Errorf("expected exited with code 3, got %+v", state)
+	}
+}
+
+func TestLookup_ZombieOfOtherUser(t *testing.T) {
+	fakeProc(t, "42", statLine("42", "backup", "Z", "100", "0"), "")
+	geteuid = func() int { return os.Geteuid() + 1 }
+	t.Cleanup(func() { geteuid = os.Geteuid })
+
+	_, state, err := lookup(42)
+	if err != nil {
+		t.Fatalf("lookup() error: %v", err)
+	}
+	if state.Running || state.ExitKnown {
+		t.Errorf("expected an exit code hidden by the kernel to be unknown, got %+v", state)
+	}
+}
+
+func TestPoll_PIDReused(t *testing.T) {
+	fakeProc(t, "42", statLine("42", "other", "S", "999", "0"), "")
+
+	state, err := Poll(Process{PID: 42, Started: time.Unix(1, 0), start: 1})
+	if err != nil {
+		t.Fatalf("Poll() error: %v", err)
+	}
+	if state.Running {
+		t.Error("expected a reused PID to count as exited")
+	}
+}
+
+func TestPoll_BootTimeShifted(t *testing.T) {
+	fakeProc(t, "42", statLine("42", "job", "S", "999", "0"), "")
+	p, err := Get(42)
+	if err != nil {
+		t.Fatalf("Get() error: %v", err)
+	}
+
+	// NTP stepping the clock moves btime, but not the process's start ticks.
+	os.WriteFile(filepath.Join(procRoot, "stat"), []byte("cpu  1 2 3\nbtime 1700000003\n"), 0644)
+	state, err := Poll(p)
+	if err != nil {
+		t.Fatalf("Poll() error: %v", err)
+	}
+	if !state.Running {
+		t.Error("expected the same process to still be running")
+	}
+}
+
+func TestDecodeWaitStatus(t *testing.T) {
+	tests := []struct{ status, want int }{
+		{0, 0},
+		{1 << 8, 1},
+		{9, 137},
+		{15, 143},
+	}
+	for _, tt := range tests {
+		if got := decodeWaitStatus(tt.status); got != tt.want {
+			t.Errorf("decodeWaitStatus(%d) = %d, want %d", tt.status, got, tt.want)
+		}
+	}
+}
+
+func TestGetAndPoll_RealProcess(t *testing.T) {
+	cmd := exec.Command("sleep", "30")
+	if err := cmd.Start(); err != nil {
+		t.Skipf("cannot start sleep: %v", err)
+	}
+	defer cmd.Process.Kill()
+
+	p, err := Get(cmd.Process.Pid)
+	if err != nil {
+		t.Fatalf("Get() error: %v", err)
+	}
+	if p.Name != "sleep" {
+		t.Errorf("expected name sleep, got %q", p.Name)
+	}
+	if state, _ := Poll(p); !state.Running {
+		t.Error("expected process to be running")
+	}
+
+	procs, err := FindByName("sleep")
+	if err != nil {
+		t.Fatalf("FindByName() error: %v", err)
+	}
+	found := false
+	for _, fp := range procs {
+		found = found || fp.PID == p.PID
+	}
+	if !found {
+		t.Errorf("expected FindByName to include PID %d, got %v", p.PID, procs)
+	}
+
+	// Until it is reaped, the killed child is a zombie with a readable status.
+	cmd.Process.Kill()
+	deadline := time.Now().Add(2 * time.Second)
+	var state State
+	for time.Now().Before(deadline) {
+		if state, _ = Poll(p); !state.Running {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if state.Running {
+		t.Fatal("expected process to have exited")
+	}
+	if state.ExitKnown && state.ExitCode != 137 {
+		t.Errorf("expected exit code 137, got %d", state.ExitCode)
+	}
+
+	cmd.Wait()
+	if _, err := Get(p.PID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound after exit, got %v", err)
+	}
+}
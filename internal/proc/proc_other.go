@@ -0,0 +1,92 @@
+//go:build !linux
+
+package proc
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Without /proc, process details come from ps(1), which every Unix has. Its
+// output doesn't include exit statuses, so State.ExitKnown is always false.
+
+const lstartLayout = "Mon Jan _2 15:04:05 2006"
+
+func ps(args ...string) ([]byte, error) {
+	cmd := exec.Command("ps", append([]string{"-o", "pid=,stat=,lstart=,comm="}, args...)...)
+	cmd.Env = append(os.Environ(), "LC_ALL=C")
+	out, err := cmd.Output()
+	var exitErr *exec.ExitError
+	// ps exits 1 when no process matched.
+	if errors.As(err, &exitErr) && len(bytes.TrimSpace(out)) == 0 {
+		return nil, ErrNotFound
+	}
+	return out, err
+}
+
+// parsePS parses a line of "pid stat lstart comm", where lstart is five
+// words and comm may contain spaces.
+func parsePS(line string) (Process, State, error) {
+	fields := strings.Fields(line)
+	if len(fields) < 8 {
+		return Process{}, State{}, fmt.Errorf("parsing ps output %q", line)
+	}
+	pid, err := strconv.Atoi(fields[0])
+	if err != nil {
+		return Process{}, State{}, fmt.Errorf("parsing ps output %q: %w", line, err)
+	}
+	started, err := time.ParseInLocation(lstartLayout, strings.Join(fields[2:7], " "), time.Local)
+	if err != nil {
+		return Process{}, State{}, fmt.Errorf("parsing ps start time: %w", err)
+	}
+	name := filepath.Base(strings.Join(fields[7:], " "))
+	return Process{PID: pid, Name: name, Started: started, start: started.Unix()}, State{Running: !strings.HasPrefix(fields[1], "Z")}, nil
+}
+
+func lookup(pid int) (Process, State, error) {
+	out, err := ps("-p", strconv.Itoa(pid))
+	if err != nil {
+		return Process{}, State{}, err
+	}
+	return parsePS(strings.TrimSpace(string(out)))
+}
+
+func get(pid int) (Process, error) {
+	p, state, err := lookup(pid)
+	if err != nil {
+		return p, err
+	}
+	if !state.Running {
+		return p, fmt.Errorf("process %d has already exited: %w", pid, ErrNotFound)
+	}
+	return p, nil
+}
+
+func findByName(name string) ([]Process, error) {
+	out, err := ps("-A")
+	if err != nil {
+		return nil, err
+	}
+
+	var procs []Process
+	for _, line := range strings.Split(string(out), "\n") {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		p, state, err := parsePS(line)
+		if err != nil || !state.Running || p.PID == os.Getpid() {
+			continue
+		}
+		if p.Name == name {
+			procs = append(procs, p)
+		}
+	}
+	return procs, nil
+}
@@ -0,0 +1,179 @@
+package quiethours
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Action is what happens to a notification sent during quiet hours.
+type Action string
+
+const (
+	// Hold queues the notification until the quiet hours end.
+	Hold Action = "hold"
+	// Downgrade sends it right away without a sound or time-sensitive flag.
+	Downgrade Action = "downgrade"
+	// Drop discards it.
+	Drop Action = "drop"
+)
+
+var dayNames = map[string][]time.Weekday{
+	"sun": {time.Sunday}, "mon": {time.Monday}, "tue": {time.Tuesday}, "wed": {time.Wednesday},
+	"thu": {time.Thursday}, "fri": {time.Friday}, "sat": {time.Saturday},
+	"weekdays": {time.Monday, time.Tuesday, time.Wednesday, time.Thursday, time.Friday},
+	"weekend":  {time.Saturday, time.Sunday},
+}
+
+// Window is a daily quiet period. A window whose end is not after its start
+// runs past midnight and belongs to the day it starts on.
+type Window struct {
+	// Days lists the days the window starts on, such as "mon", "weekdays"
+	// or "weekend". Empty means every day.
+	Days  []string `mapstructure:"days"`
+	Start string   `mapstructure:"start"`
+	End   string   `mapstructure:"end"`
+
+	days       [7]bool
+	start, end int // minutes after midnight
+}
+
+// Policy is the quiet_hours section of the config file.
+type Policy struct {
+	Timezone string   `mapstructure:"timezone"`
+	Action   Action   `mapstructure:"action"`
+	Windows  []Window `mapstructure:"windows"`
+	// IncludeTimeSensitive applies the policy to time-sensitive
+	// notifications too; by default they are always sent.
+	IncludeTimeSensitive bool `mapstructure:"include_time_sensitive"`
+
+	loc *time.Location
+}
+
+// Prepare validates p and fills in defaults. It must be called before Until.
+func (p *Policy) Prepare() error {
+	switch p.Action {
+	case "":
+		p.Action = Hold
+	case Hold, Downgrade, Drop:
+	default:
+		return fmt.Errorf("invalid quiet hours action %q, valid actions: hold, downgrade, drop", p.Action)
+	}
+
+	p.loc = time.Local
+	if p.Timezone != "" {
+		loc, err := time.LoadLocation(p.Timezone)
+		if err != nil {
+			return fmt.Errorf("invalid quiet hours timezone %q: %w", p.Timezone, err)
+		}
+		p.loc = loc
+	}
+
+	if len(p.Windows) == 0 {
+		return fmt.Errorf("quiet hours need at least one window")
+	}
+	for i := range p.Windows {
+		if err := p.Windows[i].prepare(); err != nil {
+			return fmt.Errorf("quiet hours window %d: %w", i+1, err)
+		}
+	}
+	return nil
+}
+
+func (w *Window) prepare() error {
+	var err error
+	if w.start, err = parseClock(w.Start); err != nil {
+		return fmt.Errorf("invalid start: %w", err)
+	}
+	if w.end, err = parseClock(w.End); err != nil {
+		return fmt.Errorf("invalid end: %w", err)
+	}
+	if w.start == 24*60 {
+		return fmt.Errorf("invalid start %q", w.Start)
+	}
+
+	if len(w.Days) == 0 {
+		w.days = [7]bool{true, true, true, true, true, true, true}
+	}
+	for _, name := range w.Days {
+		days, ok := parseDay(name)
+		if !ok {
+			return fmt.Errorf("invalid day %q", name)
+		}
+		for _, d := range days {
+			w.days[d] = true
+		}
+	}
+	return nil
+}
+
+// parseDay accepts short and full day names, "weekdays" and "weekend".
+func parseDay(name string) ([]time.Weekday, bool) {
+	n := strings.ToLower(name)
+	if days, ok := dayNames[n]; ok {
+		return days, true
+	}
+	for d := time.Sunday; d <= time.Saturday; d++ {
+		if n == strings.ToLower(d.String()) {
+			return []time.Weekday{d}, true
+		}
+	}
+	return nil, false
+}
+
+// parseClock parses "HH:MM" into minutes after midnight. "24:00" is allowed
+// as the end of the day.
+func parseClock(s string) (int, error) {
+	h, m, ok := strings.Cut(s, ":")
+	hours, err1 := strconv.Atoi(h)
+	minutes, err2 := strconv.Atoi(m)
+	if !ok || err1 != nil || err2 != nil || hours < 0 || minutes < 0 || minutes > 59 || hours > 24 || (hours == 24 && minutes != 0) {
+		return 0, fmt.Errorf("%q is not a time like 22:30", s)
+	}
+	return hours*60 + minutes, nil
+}
+
+// contains returns the end of the occurrence of w that contains t.
+func (w *Window) contains(t time.Time, loc *time.Location) (time.Time, bool) {
+	local := t.In(loc)
+	for _, offset := range []int{-1, 0} {
+		day := time.Date(local.Year(), local.Month(), local.Day()+offset, 0, 0, 0, 0, loc)
+		if !w.days[day.Weekday()] {
+			continue
+		}
+		start := time.Date(day.Year(), day.Month(), day.Day(), 0, w.start, 0, 0, loc)
+		end := time.Date(day.Year(), day.Month(), day.Day(), 0, w.end, 0, 0, loc)
+		if w.end <= w.start {
+			end = time.Date(day.Year(), day.Month(), day.Day()+1, 0, w.end, 0, 0, loc)
+		}
+		if !t.Before(start) && t.Before(end) {
+			return end, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// Until reports whether now falls in quiet hours and, if so, when they end.
+// Windows that touch or overlap are treated as one.
+func (p *Policy) Until(now time.Time) (time.Time, bool) {
+	t, quiet := now, false
+	for i := 0; i <= 2*len(p.Windows); i++ {
+		extended := false
+		for j := range p.Windows {
+			if end, ok := p.Windows[j].contains(t, p.loc); ok {
+				t, quiet, extended = end, true, true
+				break
+			}
+		}
+		if !extended {
+			break
+		}
+	}
+	return t, quiet
+}
+
+// Applies reports whether the policy covers a notification.
+func (p *Policy) Applies(timeSensitive bool) bool {
+	return !timeSensitive || p.IncludeTimeSensitive
+}
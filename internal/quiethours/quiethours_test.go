@@ -0,0 +1,101 @@
+package quiethours
+
+import (
+	"testing"
+	"time"
+)
+
+func prepared(t *testing.T, p Policy) *Policy {
+	t.Helper()
+	if err := p.Prepare(); err != nil {
+		t.Fatalf("Prepare() error: %v", err)
+	}
+	return &p
+}
+
+func TestUntil(t *testing.T) {
+	p := prepared(t, Policy{
+		Timezone: "Europe/Berlin",
+		Windows: []Window{
+			{Days: []string{"weekdays"}, Start: "22:00", End: "07:00"},
+			{Days: []string{"sat", "Sunday"}, Start: "23:00", End: "10:00"},
+		},
+	})
+	berlin, _ := time.LoadLocation("Europe/Berlin")
+	at := func(day, hour, minute int) time.Time {
+		// March 2024: the 4th is a Monday.
+		return time.Date(2024, 3, day, hour, minute, 0, 0, berlin)
+	}
+
+	tests := []struct {
+		name  string
+		now   time.Time
+		quiet bool
+		until time.Time
+	}{
+		{"monday evening", at(4, 21, 59), false, time.Time{}},
+		{"monday night", at(4, 22, 0), true, at(5, 7, 0)},
+		{"tuesday early morning", at(5, 6, 59), true, at(5, 7, 0)},
+		{"tuesday morning", at(5, 7, 0), false, time.Time{}},
+		{"friday night runs into saturday", at(9, 3, 0), true, at(9, 7, 0)},
+		{"saturday evening", at(9, 22, 30), false, time.Time{}},
+		{"saturday night", at(9, 23, 30), true, at(10, 10, 0)},
+		{"sunday night uses the weekend window", at(11, 8, 0), true, at(11, 10, 0)},
+		{"monday early morning after sunday night", at(11, 9, 0), true, at(11, 10, 0)},
+		{"in another timezone", time.Date(2024, 3, 4, 22, 30, 0, 0, time.UTC), true, at(5, 7, 0)},
+	}
+	for _, tt := range tests {
+		until, quiet := p.Until(tt.now)
+		if quiet != tt.quiet || (quiet && !until.Equal(tt.until)) {
+			t.Errorf("%s: Until() = %v, %v, want %v, %v", tt.name, until, quiet, tt.until, tt.quiet)
+		}
+	}
+}
+
+func TestUntil_MergesAdjacentWindows(t *testing.T) {
+	p := prepared(t, Policy{
+		Timezone: "UTC",
+		Windows: []Window{
+			{Start: "22:00", End: "24:00"},
+			{Start: "00:00", End: "06:00"},
+		},
+	})
+	until, quiet := p.Until(time.Date(2024, 3, 4, 23, 0, 0, 0, time.UTC))
+	if !quiet || !until.Equal(time.Date(2024, 3, 5, 6, 0, 0, 0, time.UTC)) {
+		t.Errorf("Until() = %v, %v, want the end of the second window", until, quiet)
+	}
+}
+
+func TestPrepare_Invalid(t *testing.T) {
+	window := []Window{{Start: "22:00", End: "07:00"}}
+	tests := []Policy{
+		{},
+		{Action: "mute", Windows: window},
+		{Timezone: "Mars/Olympus", Windows: window},
+		{Windows: []Window{{Start: "25:00", End: "07:00"}}},
+		{Windows: []Window{{Start: "22:00", End: "7"}}},
+		{Windows: []Window{{Start: "24:00", End: "07:00"}}},
+		{Windows: []Window{{Days: []string{"someday"}, Start: "22:00", End: "07:00"}}},
+	}
+	for _, p := range tests {
+		if err := p.Prepare(); err == nil {
+			t.Errorf("Prepare(%+v) expected an error", p)
+		}
+	}
+
+	p := prepared(t, Policy{Windows: window})
+	if p.Action != Hold {
+		t.Errorf("expected the default action to be hold, got %q", p.Action)
+	}
+}
+
+func TestApplies(t *testing.T) {
+	p := Policy{}
+	if !p.Applies(false) || p.Applies(true) {
+		t.Error("expected time-sensitive notifications to bypass the policy by default")
+	}
+	p.IncludeTimeSensitive = true
+	if !p.Applies(true) {
+		t.Error("expected include_time_sensitive to apply the policy")
+	}
+}
@@ -0,0 +1,55 @@
+package secret
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// The macOS keychain is used through the security tool. The key is stored
+// by feeding the command to "security -i" on stdin, since arguments are
+// visible to other processes.
+
+func keyringGet(profile string) (string, error) {
+	value, err := runTool(nil, "security", "find-generic-password", "-s", service, "-a", profile, "-w")
+	var toolErr *toolError
+	if errors.As(err, &toolErr) && toolErr.exitCode() == 44 {
+		return "", ErrNotFound
+	}
+	return value, err
+}
+
+func keyringSet(profile, value string) error {
+	if strings.ContainsAny(value, "\r\n") {
+		return errors.New("the key can't contain line breaks")
+	}
+	command := fmt.Sprintf("add-generic-password -U -s %s -a %s -l %s -w %s\n",
+		quoteArg(service), quoteArg(profile), quoteArg("Push CLI ("+profile+")"), quoteArg(value))
+	c := exec.Command("security", "-i")
+	c.Stdin = strings.NewReader(command)
+	var stderr bytes.Buffer
+	c.Stderr = &stderr
+	err := c.Run()
+	msg := strings.TrimSpace(stderr.String())
+	if err != nil {
+		return &toolError{name: "security", err: err, stderr: msg}
+	}
+	// In interactive mode a failed command doesn't change the exit status,
+	// so its error message is the only sign of it.
+	if msg != "" {
+		return fmt.Errorf("security: %s", msg)
+	}
+	return nil
+}
+
+func keyringDelete(profile string) error {
+	_, err := runTool(nil, "security", "delete-generic-password", "-s", service, "-a", profile)
+	return err
+}
+
+// quoteArg quotes s as one argument on a command line read by security -i.
+func quoteArg(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
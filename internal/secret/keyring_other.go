@@ -0,0 +1,32 @@
+//go:build !darwin
+
+package secret
+
+import (
+	"errors"
+	"strings"
+)
+
+// Other systems use the Secret Service (GNOME Keyring, KWallet) through
+// secret-tool from libsecret, which reads the secret from stdin.
+
+func keyringGet(profile string) (string, error) {
+	value, err := runTool(nil, "secret-tool", "lookup", "service", service, "profile", profile)
+	// A missing entry exits with 1 and no message; an unreachable keyring
+	// also exits with 1 but explains why on stderr.
+	var toolErr *toolError
+	if errors.As(err, &toolErr) && toolErr.exitCode() == 1 && toolErr.stderr == "" {
+		return "", ErrNotFound
+	}
+	return value, err
+}
+
+func keyringSet(profile, value string) error {
+	_, err := runTool(strings.NewReader(value), "secret-tool", "store", "--label", "Push CLI ("+profile+")", "service", service, "profile", profile)
+	return err
+}
+
+func keyringDelete(profile string) error {
+	_, err := runTool(nil, "secret-tool", "clear", "service", service, "profile", profile)
+	return err
+}
@@ -0,0 +1,205 @@
+package secret
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+
+	"filippo.io/age"
+	"golang.org/x/term"
+)
+
+// PassphraseEnv holds the passphrase for the encrypted file backend, for
+// use where no terminal is available.
+const PassphraseEnv = "PUSH_KEY_PASSPHRASE"
+
+// service names push's entries in the OS keyring.
+const service = "push-cli"
+
+const commandTimeout = 30 * time.Second
+
+var ErrNotFound = errors.New("not found")
+
+// RunCommand runs command through the shell and returns its trimmed
+// standard output, such as the key printed by "pass show push".
+func RunCommand(command string) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), commandTimeout)
+	defer cancel()
+
+	var c *exec.Cmd
+	if runtime.GOOS == "windows" {
+		c = exec.CommandContext(ctx, "cmd", "/C", command)
+	} else {
+		c = exec.CommandContext(ctx, "sh", "-c", command)
+	}
+	var stdout bytes.Buffer
+	c.Stdout = &stdout
+	c.Stderr = os.Stderr
+	c.Stdin = os.Stdin
+	if err := c.Run(); err != nil {
+		return "", fmt.Errorf("api_key_command %q: %w", command, err)
+	}
+	// Tools like pass print the secret on the first line and extra fields
+	// after it.
+	value, _, _ := strings.Cut(stdout.String(), "\n")
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return "", fmt.Errorf("api_key_command %q printed nothing", command)
+	}
+	return value, nil
+}
+
+// KeyringGet reads the secret stored for profile in the OS keyring.
+func KeyringGet(profile string) (string, error) {
+	value, err := keyringGet(profile)
+	if err != nil {
+		return "", fmt.Errorf("reading API key from keyring: %w", err)
+	}
+	return value, nil
+}
+
+// KeyringSet stores value for profile in the OS keyring.
+func KeyringSet(profile, value string) error {
+	if err := keyringSet(profile, value); err != nil {
+		return fmt.Errorf("saving API key to keyring: %w", err)
+	}
+	return nil
+}
+
+func KeyringDelete(profile string) error {
+	return keyringDelete(profile)
+}
+
+// toolError is a keyring helper that failed, with what it printed to stderr.
+type toolError struct {
+	name   string
+	err    error
+	stderr string
+}
+
+func (e *toolError) Error() string {
+	if e.stderr != "" {
+		return fmt.Sprintf("%s: %v: %s", e.name, e.err, e.stderr)
+	}
+	return fmt.Sprintf("%s: %v", e.name, e.err)
+}
+
+func (e *toolError) Unwrap() error { return e.err }
+
+func (e *toolError) exitCode() int {
+	var exitErr *exec.ExitError
+	if errors.As(e.err, &exitErr) {
+		return exitErr.ExitCode()
+	}
+	return -1
+}
+
+// runTool runs a keyring helper, returning its trimmed standard output.
+func runTool(stdin io.Reader, name string, args ...string) (string, error) {
+	if _, err := exec.LookPath(name); err != nil {
+		return "", fmt.Errorf("%s not found in PATH", name)
+	}
+	c := exec.Command(name, args...)
+	c.Stdin = stdin
+	var stdout, stderr bytes.Buffer
+	c.Stdout, c.Stderr = &stdout, &stderr
+	if err := c.Run(); err != nil {
+		return "", &toolError{name: name, err: err, stderr: strings.TrimSpace(stderr.String())}
+	}
+	return strings.TrimSpace(stdout.String()), nil
+}
+
+// ReadPassphrase returns the passphrase from PUSH_KEY_PASSPHRASE, or asks
+// for it on the terminal. With confirm it is asked for twice.
+func ReadPassphrase(confirm bool) (string, error) {
+	if p := os.Getenv(PassphraseEnv); p != "" {
+		return p, nil
+	}
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) {
+		return "", fmt.Errorf("no terminal to ask for the key passphrase; set %s", PassphraseEnv)
+	}
+
+	ask := func(prompt string) (string, error) {
+		fmt.Fprint(os.Stderr, prompt)
+		p, err := term.ReadPassword(fd)
+		fmt.Fprintln(os.Stderr)
+		return string(p), err
+	}
+	p, err := ask("Key passphrase: ")
+	if err != nil {
+		return "", err
+	}
+	if p == "" {
+		return "", errors.New("passphrase cannot be empty")
+	}
+	if confirm {
+		again, err := ask("Repeat passphrase: ")
+		if err != nil {
+			return "", err
+		}
+		if again != p {
+			return "", errors.New("passphrases don't match")
+		}
+	}
+	return p, nil
+}
+
+// ReadEncrypted decrypts the age file at path with passphrase.
+func ReadEncrypted(path, passphrase string) (string, error) {
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return "", fmt.Errorf("encrypted key file %s: %w", path, ErrNotFound)
+	}
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	identity, err := age.NewScryptIdentity(passphrase)
+	if err != nil {
+		return "", err
+	}
+	r, err := age.Decrypt(f, identity)
+	if err != nil {
+		return "", fmt.Errorf("decrypting %s: %w", path, err)
+	}
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return "", fmt.Errorf("decrypting %s: %w", path, err)
+	}
+	return string(data), nil
+}
+
+// WriteEncrypted writes value to path as an age file encrypted with
+// passphrase, readable by the age command line tool.
+func WriteEncrypted(path, value, passphrase string) error {
+	recipient, err := age.NewScryptRecipient(passphrase)
+	if err != nil {
+		return err
+	}
+	var buf bytes.Buffer
+	w, err := age.Encrypt(&buf, recipient)
+	if err != nil {
+		return err
+	}
+	if _, err := io.WriteString(w, value); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	return os.WriteFile(path, buf.Bytes(), 0600)
+}
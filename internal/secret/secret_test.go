@@ -0,0 +1,76 @@
+package secret
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestEncrypted_RoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "keys", "default.age")
+	if err := WriteEncrypted(path, "my-key", "passphrase"); err != nil {
+		t.Fatalf("WriteEncrypted() error: %v", err)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if perm := info.Mode().Perm(); perm != 0600 {
+		t.Errorf("key file permissions = %04o, want 0600", perm)
+	}
+
+	if got, err := ReadEncrypted(path, "passphrase"); err != nil || got != "my-key" {
+		t.Errorf("ReadEncrypted() = %q, %v, want my-key", got, err)
+	}
+	if _, err := ReadEncrypted(path, "other"); err == nil {
+		t.Error("expected an error with the wrong passphrase")
+	}
+}
+
+func TestRunCommand_FirstLine(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses sh")
+	}
+	got, err := RunCommand("printf '  my-key  \\nlogin: me\\n'")
+	if err != nil || got != "my-key" {
+		t.Errorf("RunCommand() = %q, %v, want my-key", got, err)
+	}
+	if _, err := RunCommand("true"); err == nil {
+		t.Error("expected an error when the command prints nothing")
+	}
+}
+
+func TestKeyring_SecretTool(t *testing.T) {
+	if runtime.GOOS == "darwin" || runtime.GOOS == "windows" {
+		t.Skip("uses a fake secret-tool")
+	}
+	// The fake keeps one secret per profile in a file next to itself.
+	dir := t.TempDir()
+	script := `#!/bin/sh
+dir="$(dirname "$0")"
+case "$1" in
+store) cat > "$dir/$7.secret" ;;
+lookup) [ -f "$dir/$5.secret" ] || exit 1; cat "$dir/$5.secret" ;;
+clear) rm -f "$dir/$5.secret" ;;
+esac
+`
+	if err := os.WriteFile(filepath.Join(dir, "secret-tool"), []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	if _, err := KeyringGet("work"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("KeyringGet() before storing = %v, want ErrNotFound", err)
+	}
+	if err := KeyringSet("work", "work-key"); err != nil {
+		t.Fatalf("KeyringSet() error: %v", err)
+	}
+	if got, err := KeyringGet("work"); err != nil || got != "work-key" {
+		t.Errorf("KeyringGet() = %q, %v, want work-key", got, err)
+	}
+	if err := KeyringDelete("work"); err != nil {
+		t.Fatalf("KeyringDelete() error: %v", err)
+	}
+}
@@ -0,0 +1,367 @@
+package spool
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/techulus/push-cli/internal/api"
+	"github.com/techulus/push-cli/internal/state"
+)
+
+const (
+	TargetNotify = "notify"
+	TargetAsync  = "notify-async"
+	TargetGroup  = "notify-group"
+
+	// KindHeld marks an entry held for quiet hours and KindScheduled one
+	// scheduled with --at or --in. Entries queued after a failed send have
+	// no kind.
+	KindHeld      = "held"
+	KindScheduled = "scheduled"
+
+	lockFile      = ".lock"
+	flushLockFile = ".flush.lock"
+	entryExt      = ".json"
+)
+
+type Entry struct {
+	ID      string            `json:"id"`
+	Target  string            `json:"target"`
+	GroupID string            `json:"groupId,omitempty"`
+	Request api.NotifyRequest `json:"request"`
+	// Profile and BaseURL record where the entry was queued, so it is sent
+	// to the same account whichever profile the flushing process uses.
+	Profile   string    `json:"profile,omitempty"`
+	BaseURL   string    `json:"baseUrl,omitempty"`
+	CreatedAt time.Time `json:"createdAt"`
+	Attempts  int       `json:"attempts"`
+	LastError string    `json:"lastError,omitempty"`
+	// NotBefore holds the entry back until the given time.
+	NotBefore time.Time `json:"notBefore"`
+	// Kind says why the entry was queued.
+	Kind string `json:"kind,omitempty"`
+	// Failed marks an entry the API rejected. Flush skips it unless asked
+	// to retry failed entries.
+	Failed bool `json:"failed,omitempty"`
+	// IgnoreQuietHours sends the entry even during quiet hours.
+	IgnoreQuietHours bool `json:"ignoreQuietHours,omitempty"`
+}
+
+// Result tells Flush what to do with an entry after trying to send it.
+type Result int
+
+const (
+	// Sent removes the entry.
+	Sent Result = iota
+	// Retry keeps the entry for the next flush and goes on with the rest.
+	Retry
+	// Stop keeps the entry and everything after it, e.g. while offline.
+	Stop
+	// Reject keeps the entry but marks it failed, since sending it again
+	// would fail the same way.
+	Reject
+	// Drop removes the entry without sending it.
+	Drop
+	// Postpone keeps the entry, with any change send made to NotBefore,
+	// without counting an attempt.
+	Postpone
+)
+
+// Held reports whether e must not be sent yet.
+func (e Entry) Held(now time.Time) bool {
+	return now.Before(e.NotBefore)
+}
+
+// Queue is a directory of pending notifications, one JSON file per entry.
+// Every operation holds an exclusive lock on the directory so concurrent
+// invocations never corrupt an entry, and Flush holds a second lock so an
+// entry is never sent twice.
+type Queue struct {
+	dir string
+}
+
+func Open(dir string) (*Queue, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, fmt.Errorf("creating spool directory: %w", err)
+	}
+	return &Queue{dir: dir}, nil
+}
+
+func (q *Queue) Dir() string {
+	return q.dir
+}
+
+// idPattern matches the IDs newID generates.
+var idPattern = regexp.MustCompile(`^\d{8}T\d{6}-[0-9a-f]{8}$`)
+
+func newID() string {
+	b := make([]byte, 4)
+	rand.Read(b)
+	return time.Now().UTC().Format("20060102T150405") + "-" + hex.EncodeToString(b)
+}
+
+func (q *Queue) Add(e Entry) (Entry, error) {
+	switch e.Target {
+	case TargetNotify, TargetAsync:
+	case TargetGroup:
+		if e.GroupID == "" {
+			return Entry{}, errors.New("group entries need a group ID")
+		}
+	default:
+		return Entry{}, fmt.Errorf("unknown target %q", e.Target)
+	}
+
+	unlock, err := q.lock()
+	if err != nil {
+		return Entry{}, err
+	}
+	defer unlock()
+
+	e.ID = newID()
+	if e.CreatedAt.IsZero() {
+		e.CreatedAt = time.Now()
+	}
+	return e, q.write(e)
+}
+
+func (q *Queue) List() ([]Entry, error) {
+	unlock, err := q.lock()
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+	return q.list()
+}
+
+// Flush hands each entry to send, oldest first, skipping entries that are
+// still held and, unless retryFailed is set, entries marked failed. The
+// Result send returns decides what happens to the entry; failures are
+// recorded on it. send may also postpone the entry by moving its NotBefore.
+// pending lists every entry left in the queue.
+//
+// send is called without the directory lock, which is only taken to read and
+// update each entry, so Add never waits for the network. A separate flush
+// lock keeps two flushes from sending the same entry.
+func (q *Queue) Flush(retryFailed bool, send func(*Entry) (Result, error)) (sent int, pending []Entry, err error) {
+	unlockFlush, err := q.lockFile(flushLockFile)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer unlockFlush()
+
+	entries, err := q.List()
+	if err != nil {
+		return 0, nil, err
+	}
+
+	now := time.Now()
+	for i, e := range entries {
+		if e.Held(now) || (e.Failed && !retryFailed) {
+			pending = append(pending, e)
+			continue
+		}
+		// It may have been dropped since the list was taken.
+		e, ok, err := q.get(e.ID)
+		if err != nil {
+			return sent, pending, err
+		}
+		if !ok {
+			continue
+		}
+
+		result, sendErr := send(&e)
+		switch result {
+		case Sent, Drop:
+			if err := q.locked(func() error { return q.remove(e.ID) }); err != nil && !errors.Is(err, fs.ErrNotExist) {
+				return sent, pending, err
+			}
+			if result == Sent {
+				sent++
+			}
+			continue
+		case Postpone:
+			if err := q.update(e); err != nil {
+				return sent, pending, err
+			}
+			pending = append(pending, e)
+			continue
+		}
+
+		e.Attempts++
+		if sendErr != nil {
+			e.LastError = sendErr.Error()
+		}
+		e.Failed = result == Reject
+		if err := q.update(e); err != nil {
+			return sent, pending, err
+		}
+		pending = append(pending, e)
+		if result == Stop {
+			pending = append(pending, entries[i+1:]...)
+			break
+		}
+	}
+	return sent, pending, nil
+}
+
+// get reads one entry. It reports false if the entry no longer exists.
+func (q *Queue) get(id string) (Entry, bool, error) {
+	var e Entry
+	var ok bool
+	err := q.locked(func() error {
+		data, err := os.ReadFile(q.path(id))
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("reading queued notification: %w", err)
+		}
+		ok = true
+		return json.Unmarshal(data, &e)
+	})
+	return e, ok, err
+}
+
+// update rewrites e unless it was dropped in the meantime.
+func (q *Queue) update(e Entry) error {
+	return q.locked(func() error {
+		if _, err := os.Stat(q.path(e.ID)); errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+		return q.write(e)
+	})
+}
+
+func (q *Queue) locked(fn func() error) error {
+	unlock, err := q.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	return fn()
+}
+
+// Drop deletes the entries with the given IDs and reports how many it
+// deleted. Every ID is checked before anything is deleted.
+func (q *Queue) Drop(ids ...string) (int, error) {
+	unlock, err := q.lock()
+	if err != nil {
+		return 0, err
+	}
+	defer unlock()
+
+	seen := map[string]bool{}
+	for _, id := range ids {
+		if !idPattern.MatchString(id) {
+			return 0, fmt.Errorf("invalid queued notification ID %q", id)
+		}
+		if _, err := os.Stat(q.path(id)); errors.Is(err, fs.ErrNotExist) {
+			return 0, fmt.Errorf("no queued notification with ID %q", id)
+		}
+		seen[id] = true
+	}
+
+	dropped := 0
+	for id := range seen {
+		if err := q.remove(id); err != nil {
+			return dropped, err
+		}
+		dropped++
+	}
+	return dropped, nil
+}
+
+func (q *Queue) DropAll() (int, error) {
+	unlock, err := q.lock()
+	if err != nil {
+		return 0, err
+	}
+	defer unlock()
+
+	entries, err := q.list()
+	if err != nil {
+		return 0, err
+	}
+	for _, e := range entries {
+		if err := q.remove(e.ID); err != nil {
+			return 0, err
+		}
+	}
+	return len(entries), nil
+}
+
+func (q *Queue) path(id string) string {
+	return filepath.Join(q.dir, id+entryExt)
+}
+
+func (q *Queue) list() ([]Entry, error) {
+	files, err := os.ReadDir(q.dir)
+	if err != nil {
+		return nil, fmt.Errorf("reading spool directory: %w", err)
+	}
+
+	var entries []Entry
+	for _, f := range files {
+		name := f.Name()
+		if f.IsDir() || !strings.HasSuffix(name, entryExt) {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(q.dir, name))
+		if err != nil {
+			return nil, fmt.Errorf("reading queued notification: %w", err)
+		}
+		var e Entry
+		if err := json.Unmarshal(data, &e); err != nil {
+			return nil, fmt.Errorf("reading queued notification %s: %w", name, err)
+		}
+		entries = append(entries, e)
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].CreatedAt.Equal(entries[j].CreatedAt) {
+			return entries[i].ID < entries[j].ID
+		}
+		return entries[i].CreatedAt.Before(entries[j].CreatedAt)
+	})
+	return entries, nil
+}
+
+func (q *Queue) write(e Entry) error {
+	data, err := json.MarshalIndent(e, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := state.WriteFile(q.path(e.ID), data); err != nil {
+		return fmt.Errorf("writing queued notification: %w", err)
+	}
+	return nil
+}
+
+func (q *Queue) remove(id string) error {
+	return os.Remove(q.path(id))
+}
+
+func (q *Queue) lock() (func(), error) {
+	return q.lockFile(lockFile)
+}
+
+func (q *Queue) lockFile(name string) (func(), error) {
+	unlock, err := state.Lock(filepath.Join(q.dir, name))
+	if errors.Is(err, state.ErrLocked) {
+		return nil, fmt.Errorf("spool is %w", err)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("locking spool: %w", err)
+	}
+	return unlock, nil
+}
@@ -0,0 +1,300 @@
+package spool
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/techulus/push-cli/internal/api"
+)
+
+func newTestQueue(t *testing.T) *Queue {
+	t.Helper()
+	q, err := Open(filepath.Join(t.TempDir(), "spool"))
+	if err != nil {
+		t.Fatalf("Open() error: %v", err)
+	}
+	return q
+}
+
+func TestAddAndList(t *testing.T) {
+	q := newTestQueue(t)
+
+	first, err := q.Add(Entry{Target: TargetNotify, Request: api.NotifyRequest{Title: "A", Body: "one"}, CreatedAt: time.Now().Add(-time.Minute)})
+	if err != nil {
+		t.Fatalf("Add() error: %v", err)
+	}
+	if _, err := q.Add(Entry{Target: TargetGroup, GroupID: "ops", Request: api.NotifyRequest{Title: "B", Body: "two"}}); err != nil {
+		t.Fatalf("Add() error: %v", err)
+	}
+
+	entries, err := q.List()
+	if err != nil {
+		t.Fatalf("List() error: %v", err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("expected 2 entries, got %d", len(entries))
+	}
+	if entries[0].ID != first.ID || entries[1].GroupID != "ops" {
+		t.Errorf("unexpected order or content: %+v", entries)
+	}
+
+	info, err := os.Stat(q.path(first.ID))
+	if err != nil {
+		t.Fatalf("Stat() error: %v", err)
+	}
+	if perm := info.Mode().Perm(); perm != 0600 {
+		t.Errorf("entry permissions = %04o, want 0600", perm)
+	}
+}
+
+func TestAdd_InvalidTarget(t *testing.T) {
+	q := newTestQueue(t)
+	if _, err := q.Add(Entry{Target: "nope"}); err == nil {
+		t.Error("expected error for unknown target")
+	}
+	if _, err := q.Add(Entry{Target: TargetGroup}); err == nil {
+		t.Error("expected error for group entry without group ID")
+	}
+}
+
+func TestFlush(t *testing.T) {
+	q := newTestQueue(t)
+	for _, body := range []string{"ok", "bad", "ok-too"} {
+		if _, err := q.Add(Entry{Target: TargetNotify, Request: api.NotifyRequest{Title: "T", Body: body}}); err != nil {
+			t.Fatalf("Add() error: %v", err)
+		}
+	}
+
+	sent, failed, err := q.Flush(false, func(e *Entry) (Result, error) {
+		if e.Request.Body == "bad" {
+			return Retry, errors.New("rejected")
+		}
+		return Sent, nil
+	})
+	if err != nil {
+		t.Fatalf("Flush() error: %v", err)
+	}
+	if sent != 2 || len(failed) != 1 {
+		t.Fatalf("expected 2 sent and 1 failed, got %d and %d", sent, len(failed))
+	}
+
+	entries, _ := q.List()
+	if len(entries) != 1 || entries[0].Request.Body != "bad" {
+		t.Fatalf("expected only the rejected entry to remain, got %+v", entries)
+	}
+	if entries[0].Attempts != 1 || entries[0].LastError != "rejected" {
+		t.Errorf("expected attempt and error to be recorded, got %+v", entries[0])
+	}
+}
+
+func TestFlush_StopKeepsRemaining(t *testing.T) {
+	q := newTestQueue(t)
+	for i := 0; i < 3; i++ {
+		q.Add(Entry{Target: TargetNotify, Request: api.NotifyRequest{Title: "T", Body: "b"}})
+	}
+
+	calls := 0
+	sent, failed, err := q.Flush(false, func(e *Entry) (Result, error) {
+		calls++
+		return Stop, errors.New("offline")
+	})
+	if err != nil {
+		t.Fatalf("Flush() error: %v", err)
+	}
+	if calls != 1 || sent != 0 || len(failed) != 3 {
+		t.Errorf("expected to stop after first failure, got calls=%d sent=%d failed=%d", calls, sent, len(failed))
+	}
+}
+
+func TestFlush_RejectParks(t *testing.T) {
+	q := newTestQueue(t)
+	q.Add(Entry{Target: TargetNotify, Request: api.NotifyRequest{Title: "T", Body: "bad"}})
+
+	calls := 0
+	send := func(e *Entry) (Result, error) {
+		calls++
+		return Reject, errors.New("unauthorized")
+	}
+	if _, _, err := q.Flush(false, send); err != nil {
+		t.Fatalf("Flush() error: %v", err)
+	}
+	entries, _ := q.List()
+	if len(entries) != 1 || !entries[0].Failed {
+		t.Fatalf("expected the rejected entry to be kept as failed, got %+v", entries)
+	}
+
+	// Later flushes leave it alone unless asked to retry it.
+	_, pending, _ := q.Flush(false, send)
+	if calls != 1 || len(pending) != 1 {
+		t.Errorf("expected the failed entry to be skipped, got calls=%d pending=%d", calls, len(pending))
+	}
+	sent, _, _ := q.Flush(true, func(e *Entry) (Result, error) { return Sent, nil })
+	if sent != 1 {
+		t.Errorf("expected the failed entry to be sent when retried, got %d", sent)
+	}
+}
+
+func TestFlush_DropAndPostpone(t *testing.T) {
+	q := newTestQueue(t)
+	q.Add(Entry{Target: TargetNotify, Request: api.NotifyRequest{Title: "T", Body: "drop"}})
+	q.Add(Entry{Target: TargetNotify, Request: api.NotifyRequest{Title: "T", Body: "later"}})
+
+	later := time.Now().Add(time.Hour).Truncate(time.Second)
+	sent, pending, err := q.Flush(false, func(e *Entry) (Result, error) {
+		if e.Request.Body == "drop" {
+			return Drop, nil
+		}
+		e.NotBefore = later
+		return Postpone, nil
+	})
+	if err != nil || sent != 0 {
+		t.Fatalf("Flush() = %d, %v", sent, err)
+	}
+	entries, _ := q.List()
+	if len(pending) != 1 || len(entries) != 1 || !entries[0].NotBefore.Equal(later) || entries[0].Attempts != 0 {
+		t.Errorf("expected only the postponed entry to remain, held until %v, got %+v", later, entries)
+	}
+}
+
+func TestFlush_SkipsHeld(t *testing.T) {
+	q := newTestQueue(t)
+	q.Add(Entry{Target: TargetNotify, Request: api.NotifyRequest{Title: "T", Body: "later"}, NotBefore: time.Now().Add(time.Hour)})
+	q.Add(Entry{Target: TargetNotify, Request: api.NotifyRequest{Title: "T", Body: "now"}})
+
+	var bodies []string
+	sent, pending, err := q.Flush(false, func(e *Entry) (Result, error) {
+		bodies = append(bodies, e.Request.Body)
+		return Sent, nil
+	})
+	if err != nil {
+		t.Fatalf("Flush() error: %v", err)
+	}
+	if sent != 1 || len(bodies) != 1 || bodies[0] != "now" {
+		t.Errorf("expected only the due entry to be sent, got %v", bodies)
+	}
+	if len(pending) != 1 || pending[0].Request.Body != "later" || pending[0].Attempts != 0 {
+		t.Errorf("expected the held entry to stay untouched, got %+v", pending)
+	}
+}
+
+func TestDrop(t *testing.T) {
+	q := newTestQueue(t)
+	e, _ := q.Add(Entry{Target: TargetNotify, Request: api.NotifyRequest{Title: "T", Body: "b"}})
+	q.Add(Entry{Target: TargetAsync, Request: api.NotifyRequest{Title: "T", Body: "b"}})
+
+	if n, err := q.Drop(e.ID, e.ID); err != nil || n != 1 {
+		t.Fatalf("Drop() = %d, %v, want 1", n, err)
+	}
+	if _, err := q.Drop(e.ID); err == nil {
+		t.Error("expected error dropping a missing entry")
+	}
+	os.WriteFile(filepath.Join(filepath.Dir(q.Dir()), "heartbeats.json"), []byte("{}"), 0600)
+	if _, err := q.Drop("../heartbeats"); err == nil {
+		t.Error("expected error dropping an invalid ID")
+	}
+	if _, err := os.Stat(filepath.Join(filepath.Dir(q.Dir()), "heartbeats.json")); err != nil {
+		t.Errorf("expected a file outside the queue to be left alone, got %v", err)
+	}
+
+	n, err := q.DropAll()
+	if err != nil || n != 1 {
+		t.Fatalf("DropAll() = %d, %v, want 1", n, err)
+	}
+	if entries, _ := q.List(); len(entries) != 0 {
+		t.Errorf("expected empty queue, got %d entries", len(entries))
+	}
+}
+
+func TestConcurrentFlushSendsOnce(t *testing.T) {
+	q := newTestQueue(t)
+	for i := 0; i < 5; i++ {
+		q.Add(Entry{Target: TargetNotify, Request: api.NotifyRequest{Title: "T", Body: "b"}})
+	}
+
+	var mu sync.Mutex
+	sends := map[string]int{}
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			other, _ := Open(q.Dir())
+			other.Flush(false, func(e *Entry) (Result, error) {
+				mu.Lock()
+				sends[e.ID]++
+				mu.Unlock()
+				time.Sleep(5 * time.Millisecond)
+				return Sent, nil
+			})
+		}()
+	}
+	wg.Wait()
+
+	if len(sends) != 5 {
+		t.Errorf("expected 5 entries sent, got %d", len(sends))
+	}
+	for id, n := range sends {
+		if n != 1 {
+			t.Errorf("entry %s sent %d times", id, n)
+		}
+	}
+}
+
+func TestFlush_AddWhileSending(t *testing.T) {
+	q := newTestQueue(t)
+	if _, err := q.Add(Entry{Target: TargetNotify, Request: api.NotifyRequest{Title: "T", Body: "slow"}}); err != nil {
+		t.Fatalf("Add() error: %v", err)
+	}
+
+	// Add must not wait for a send in progress, however long it takes.
+	added := make(chan error)
+	_, _, err := q.Flush(false, func(e *Entry) (Result, error) {
+		go func() {
+			_, err := q.Add(Entry{Target: TargetNotify, Request: api.NotifyRequest{Title: "T", Body: "new"}})
+			added <- err
+		}()
+		select {
+		case err := <-added:
+			if err != nil {
+				return Retry, err
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("Add() blocked while Flush was sending")
+		}
+		return Sent, nil
+	})
+	if err != nil {
+		t.Fatalf("Flush() error: %v", err)
+	}
+	entries, _ := q.List()
+	if len(entries) != 1 || entries[0].Request.Body != "new" {
+		t.Errorf("expected only the new entry to remain, got %+v", entries)
+	}
+}
+
+func TestFlush_SkipsDropped(t *testing.T) {
+	q := newTestQueue(t)
+	var ids []string
+	for _, body := range []string{"a", "b"} {
+		e, err := q.Add(Entry{Target: TargetNotify, Request: api.NotifyRequest{Title: "T", Body: body}})
+		if err != nil {
+			t.Fatalf("Add() error: %v", err)
+		}
+		ids = append(ids, e.ID)
+	}
+
+	var bodies []string
+	sent, _, err := q.Flush(false, func(e *Entry) (Result, error) {
+		bodies = append(bodies, e.Request.Body)
+		// Dropped by another process while the first one was sending.
+		_, err := q.Drop(ids[1])
+		return Sent, err
+	})
+	if err != nil || sent != 1 || len(bodies) != 1 {
+		t.Errorf("Flush() = %d, %v, sent %v, want only the first entry", sent, err, bodies)
+	}
+}
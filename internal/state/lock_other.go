@@ -0,0 +1,51 @@
+//go:build !unix
+
+package state
+
+import (
+	"errors"
+	"os"
+	"time"
+)
+
+var errHeld = errors.New("lock is held")
+
+// staleLock is how old a lock file may get before it is assumed to belong to
+// a process that died without removing it. A live holder touches the file
+// every staleLock/4, however long it keeps the lock.
+const staleLock = time.Minute
+
+func tryLock(path string) (func(), error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_RDWR, 0600)
+	if err != nil {
+		if !errors.Is(err, os.ErrExist) {
+			return nil, err
+		}
+		if info, statErr := os.Stat(path); statErr == nil && time.Since(info.ModTime()) > staleLock {
+			os.Remove(path)
+		}
+		return nil, errHeld
+	}
+	f.Close()
+
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		ticker := time.NewTicker(staleLock / 4)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case now := <-ticker.C:
+				os.Chtimes(path, now, now)
+			}
+		}
+	}()
+	return func() {
+		close(done)
+		<-stopped
+		os.Remove(path)
+	}, nil
+}
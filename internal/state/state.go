@@ -0,0 +1,97 @@
+package state
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const (
+	lockWait  = 10 * time.Second
+	lockRetry = 50 * time.Millisecond
+)
+
+// ErrLocked is returned by Lock when another process held the lock for the
+// whole wait.
+var ErrLocked = errors.New("locked by another push process")
+
+// Lock takes an exclusive lock on the file at path, creating it if needed,
+// and waits for other processes to release it.
+func Lock(path string) (func(), error) {
+	deadline := time.Now().Add(lockWait)
+	for {
+		unlock, err := tryLock(path)
+		if err == nil {
+			return unlock, nil
+		}
+		if !errors.Is(err, errHeld) {
+			return nil, err
+		}
+		if time.Now().After(deadline) {
+			return nil, ErrLocked
+		}
+		time.Sleep(lockRetry)
+	}
+}
+
+// WriteFile replaces path atomically so a crash never leaves half a file.
+func WriteFile(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+"-*")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// Load decodes the JSON file at path into v. A missing file leaves v as is.
+func Load(path string, v interface{}) error {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("reading %s: %w", path, err)
+	}
+	return nil
+}
+
+// Update locks the JSON file at path, loads it into v, calls fn and writes v
+// back if fn succeeds. Concurrent push processes updating the same file take
+// turns, so no update is lost.
+func Update(path string, v interface{}, fn func() error) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	unlock, err := Lock(path + ".lock")
+	if err != nil {
+		return fmt.Errorf("locking %s: %w", filepath.Base(path), err)
+	}
+	defer unlock()
+
+	if err := Load(path, v); err != nil {
+		return err
+	}
+	if err := fn(); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	return WriteFile(path, data)
+}
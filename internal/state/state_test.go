@@ -0,0 +1,59 @@
+package state
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+func TestLoad_MissingFile(t *testing.T) {
+	v := map[string]int{"kept": 1}
+	if err := Load(filepath.Join(t.TempDir(), "missing.json"), &v); err != nil {
+		t.Fatalf("Load() error: %v", err)
+	}
+	if v["kept"] != 1 {
+		t.Errorf("expected value to be left alone, got %v", v)
+	}
+}
+
+func TestUpdate_Concurrent(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "nested", "counter.json")
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			var counter struct{ N int }
+			err := Update(path, &counter, func() error {
+				counter.N++
+				return nil
+			})
+			if err != nil {
+				t.Errorf("Update() error: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	var counter struct{ N int }
+	if err := Load(path, &counter); err != nil {
+		t.Fatalf("Load() error: %v", err)
+	}
+	if counter.N != 20 {
+		t.Errorf("expected 20 updates, got %d", counter.N)
+	}
+}
+
+func TestUpdate_ErrorSkipsWrite(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+	var v struct{ N int }
+	Update(path, &v, func() error {
+		v.N = 1
+		return os.ErrInvalid
+	})
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("expected no file after a failed update, got %v", err)
+	}
+}
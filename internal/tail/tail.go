@@ -0,0 +1,126 @@
+package tail
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"time"
+)
+
+const defaultPollInterval = time.Second
+
+type Options struct {
+	// PollInterval is how often the file is checked for new data, rotation
+	// and truncation.
+	PollInterval time.Duration
+	// FromStart reads the existing contents first instead of only lines
+	// appended after Follow starts.
+	FromStart bool
+}
+
+// Follow sends each complete line appended to path on lines until ctx is
+// done. It keeps following when the file is truncated or replaced, as log
+// rotation does: the old file is read to the end before switching to the new
+// one. A trailing line without a newline is held until it is completed.
+func Follow(ctx context.Context, path string, opts Options, lines chan<- string) error {
+	if opts.PollInterval <= 0 {
+		opts.PollInterval = defaultPollInterval
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer func() { f.Close() }()
+
+	var offset int64
+	if !opts.FromStart {
+		if offset, err = f.Seek(0, io.SeekEnd); err != nil {
+			return err
+		}
+	}
+
+	var partial []byte
+	buf := make([]byte, 32*1024)
+
+	// drain reads f to EOF, sending every complete line.
+	drain := func() error {
+		for {
+			n, err := f.Read(buf)
+			offset += int64(n)
+			partial = append(partial, buf[:n]...)
+			for {
+				i := bytes.IndexByte(partial, '\n')
+				if i < 0 {
+					break
+				}
+				line := string(bytes.TrimSuffix(partial[:i], []byte("\r")))
+				partial = partial[i+1:]
+				select {
+				case lines <- line:
+				case <-ctx.Done():
+					return ctx.Err()
+				}
+			}
+			if errors.Is(err, io.EOF) || (err == nil && n == 0) {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	for {
+		if err := drain(); err != nil {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(opts.PollInterval):
+		}
+
+		current, err := f.Stat()
+		if err != nil {
+			return err
+		}
+		latest, err := os.Stat(path)
+		if err != nil {
+			// Mid-rotation the path may briefly not exist; keep reading the
+			// old file until a new one appears.
+			continue
+		}
+
+		switch {
+		case !os.SameFile(current, latest):
+			next, err := os.Open(path)
+			if err != nil {
+				continue
+			}
+			if err := drain(); err != nil {
+				next.Close()
+				return err
+			}
+			// Nothing more will be written to the old file.
+			if len(partial) > 0 {
+				select {
+				case lines <- string(partial):
+				case <-ctx.Done():
+					next.Close()
+					return ctx.Err()
+				}
+			}
+			f.Close()
+			f, offset, partial = next, 0, nil
+		case latest.Size() < offset:
+			if _, err := f.Seek(0, io.SeekStart); err != nil {
+				return err
+			}
+			offset, partial = 0, nil
+		}
+	}
+}
@@ -0,0 +1,109 @@
+package tail
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func startFollow(t *testing.T, path string, opts Options) (<-chan string, func()) {
+	t.Helper()
+	opts.PollInterval = 10 * time.Millisecond
+	ctx, cancel := context.WithCancel(context.Background())
+	lines := make(chan string, 100)
+	done := make(chan error, 1)
+	go func() { done <- Follow(ctx, path, opts, lines) }()
+	// Give Follow time to open the file and seek before the test writes.
+	time.Sleep(30 * time.Millisecond)
+	return lines, func() {
+		cancel()
+		<-done
+	}
+}
+
+func expectLines(t *testing.T, lines <-chan string, want ...string) {
+	t.Helper()
+	for _, w := range want {
+		select {
+		case got := <-lines:
+			if got != w {
+				t.Fatalf("expected line %q, got %q", w, got)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatalf("timed out waiting for line %q", w)
+		}
+	}
+}
+
+func appendFile(t *testing.T, path, text string) {
+	t.Helper()
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY|os.O_CREATE, 0644)
+	if err != nil {
+		t.Fatalf("opening file: %v", err)
+	}
+	defer f.Close()
+	if _, err := f.WriteString(text); err != nil {
+		t.Fatalf("writing file: %v", err)
+	}
+}
+
+func TestFollow_NewLinesOnly(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+	appendFile(t, path, "old line\n")
+
+	lines, stop := startFollow(t, path, Options{})
+	defer stop()
+
+	appendFile(t, path, "first\r\nsec")
+	appendFile(t, path, "ond\n")
+	expectLines(t, lines, "first", "second")
+}
+
+func TestFollow_FromStart(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+	appendFile(t, path, "old line\n")
+
+	lines, stop := startFollow(t, path, Options{FromStart: true})
+	defer stop()
+	expectLines(t, lines, "old line")
+}
+
+func TestFollow_Truncation(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+	appendFile(t, path, "a long line before truncation\n")
+
+	lines, stop := startFollow(t, path, Options{})
+	defer stop()
+
+	if err := os.Truncate(path, 0); err != nil {
+		t.Fatalf("truncating: %v", err)
+	}
+	time.Sleep(30 * time.Millisecond)
+	appendFile(t, path, "after\n")
+	expectLines(t, lines, "after")
+}
+
+func TestFollow_Rotation(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app.log")
+	appendFile(t, path, "")
+
+	lines, stop := startFollow(t, path, Options{})
+	defer stop()
+
+	appendFile(t, path, "before\nunterminated")
+	if err := os.Rename(path, filepath.Join(dir, "app.log.1")); err != nil {
+		t.Fatalf("rotating: %v", err)
+	}
+	appendFile(t, path, "after\n")
+	expectLines(t, lines, "before", "unterminated", "after")
+}
+
+func TestFollow_MissingFile(t *testing.T) {
+	err := Follow(context.Background(), filepath.Join(t.TempDir(), "missing.log"), Options{}, make(chan string))
+	if !os.IsNotExist(err) {
+		t.Errorf("expected not-exist error, got %v", err)
+	}
+}
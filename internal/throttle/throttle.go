@@ -0,0 +1,232 @@
+package throttle
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/techulus/push-cli/internal/state"
+)
+
+// keepSuppressed is how long suppressed repeats wait to be reported by the
+// next notification with the same key.
+const keepSuppressed = 7 * 24 * time.Hour
+
+// Rate allows Count notifications every Per, with bursts of up to Count.
+type Rate struct {
+	Count int
+	Per   time.Duration
+}
+
+func (r Rate) String() string {
+	switch r.Per {
+	case time.Second:
+		return fmt.Sprintf("%d/s", r.Count)
+	case time.Minute:
+		return fmt.Sprintf("%d/m", r.Count)
+	case time.Hour:
+		return fmt.Sprintf("%d/h", r.Count)
+	}
+	return fmt.Sprintf("%d/%s", r.Count, r.Per)
+}
+
+// ParseRate parses limits like "30/m", "500/h" or "10/5m".
+func ParseRate(s string) (Rate, error) {
+	count, per, ok := strings.Cut(strings.TrimSpace(s), "/")
+	if !ok {
+		return Rate{}, fmt.Errorf("invalid rate limit %q, use a form like 30/m", s)
+	}
+	n, err := strconv.Atoi(count)
+	if err != nil || n <= 0 {
+		return Rate{}, fmt.Errorf("invalid rate limit %q: count must be a positive number", s)
+	}
+
+	r := Rate{Count: n}
+	switch per {
+	case "s":
+		r.Per = time.Second
+	case "m":
+		r.Per = time.Minute
+	case "h":
+		r.Per = time.Hour
+	case "d":
+		r.Per = 24 * time.Hour
+	default:
+		r.Per, err = time.ParseDuration(per)
+		if err != nil || r.Per <= 0 {
+			return Rate{}, fmt.Errorf("invalid rate limit %q: period must be s, m, h, d or a duration", s)
+		}
+	}
+	return r, nil
+}
+
+// Key returns the default dedup key for a notification.
+func Key(title, body, channel string) string {
+	sum := sha256.Sum256([]byte(title + "\x00" + body + "\x00" + channel))
+	return hex.EncodeToString(sum[:8])
+}
+
+type dedupEntry struct {
+	LastSent   time.Time     `json:"lastSent"`
+	Window     time.Duration `json:"window"`
+	Suppressed int           `json:"suppressed,omitempty"`
+}
+
+type bucket struct {
+	Tokens     float64   `json:"tokens"`
+	Updated    time.Time `json:"updated"`
+	Suppressed int       `json:"suppressed,omitempty"`
+}
+
+type file struct {
+	Dedup   map[string]*dedupEntry `json:"dedup"`
+	Buckets map[string]*bucket     `json:"buckets"`
+}
+
+// Store keeps dedup keys and rate limit buckets in a JSON file shared by
+// every push process.
+type Store struct {
+	path string
+}
+
+func Open(path string) *Store {
+	return &Store{path: path}
+}
+
+func (s *Store) update(fn func(f *file)) error {
+	var f file
+	return state.Update(s.path, &f, func() error {
+		if f.Dedup == nil {
+			f.Dedup = map[string]*dedupEntry{}
+		}
+		if f.Buckets == nil {
+			f.Buckets = map[string]*bucket{}
+		}
+		fn(&f)
+		return nil
+	})
+}
+
+// Request describes a notification about to be sent. Key is only checked
+// when Window is positive, and the bucket only when Rate is set.
+type Request struct {
+	Profile string
+	Key     string
+	Window  time.Duration
+	Rate    *Rate
+}
+
+// Reason says why a notification was suppressed.
+type Reason string
+
+const (
+	Duplicate   Reason = "duplicate"
+	RateLimited Reason = "rate_limited"
+)
+
+// Decision is the outcome of Allow. When a notification is allowed,
+// Duplicates and RateLimited count what was suppressed since the last one
+// went out: repeats of the same key, and anything dropped by the profile's
+// rate limit.
+type Decision struct {
+	Allowed     bool
+	Reason      Reason
+	Duplicates  int
+	RateLimited int
+
+	req  Request
+	prev *dedupEntry
+	now  time.Time
+}
+
+// Allow decides whether the notification described by r may be sent and
+// records it as sent, or counts it as suppressed. Call Release if it then
+// fails to send, so retrying isn't mistaken for a repeat.
+func (s *Store) Allow(r Request, now time.Time) (Decision, error) {
+	d := Decision{req: r, now: now}
+	key := r.Profile + "/" + r.Key
+	err := s.update(func(f *file) {
+		prune(f, now)
+
+		entry := f.Dedup[key]
+		if r.Window > 0 && entry != nil && now.Sub(entry.LastSent) < entry.Window {
+			entry.Suppressed++
+			d.Reason = Duplicate
+			return
+		}
+
+		if r.Rate != nil {
+			b := f.Buckets[r.Profile]
+			if b == nil {
+				b = &bucket{Tokens: float64(r.Rate.Count), Updated: now}
+				f.Buckets[r.Profile] = b
+			}
+			b.refill(*r.Rate, now)
+			if b.Tokens < 1 {
+				b.Suppressed++
+				d.Reason = RateLimited
+				return
+			}
+			b.Tokens--
+			d.RateLimited, b.Suppressed = b.Suppressed, 0
+		}
+
+		d.Allowed = true
+		if r.Window > 0 {
+			if entry != nil {
+				prev := *entry
+				d.prev = &prev
+				d.Duplicates = entry.Suppressed
+			}
+			f.Dedup[key] = &dedupEntry{LastSent: now, Window: r.Window}
+		}
+	})
+	return d, err
+}
+
+// Release undoes an allowed decision after the notification failed to send,
+// returning its token and putting back the suppressed counts it reported.
+func (s *Store) Release(d Decision) error {
+	if !d.Allowed {
+		return nil
+	}
+	r := d.req
+	key := r.Profile + "/" + r.Key
+	return s.update(func(f *file) {
+		if r.Window > 0 {
+			if e := f.Dedup[key]; e != nil && e.LastSent.Equal(d.now) {
+				if d.prev != nil {
+					f.Dedup[key] = d.prev
+				} else {
+					delete(f.Dedup, key)
+				}
+			}
+		}
+		if b := f.Buckets[r.Profile]; b != nil && r.Rate != nil {
+			b.Tokens = min(b.Tokens+1, float64(r.Rate.Count))
+			b.Suppressed += d.RateLimited
+		}
+	})
+}
+
+func (b *bucket) refill(r Rate, now time.Time) {
+	if elapsed := now.Sub(b.Updated); elapsed > 0 {
+		b.Tokens += float64(r.Count) * elapsed.Seconds() / r.Per.Seconds()
+		b.Updated = now
+	}
+	b.Tokens = min(b.Tokens, float64(r.Count))
+}
+
+// prune drops dedup keys whose window has passed, unless they still have
+// suppressed repeats to report and were sent within the last week.
+func prune(f *file, now time.Time) {
+	for key, e := range f.Dedup {
+		age := now.Sub(e.LastSent)
+		if age >= e.Window && (e.Suppressed == 0 || age >= e.Window+keepSuppressed) {
+			delete(f.Dedup, key)
+		}
+	}
+}
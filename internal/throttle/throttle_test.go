@@ -0,0 +1,132 @@
+package throttle
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestParseRate(t *testing.T) {
+	tests := []struct {
+		in   string
+		want Rate
+	}{
+		{"30/m", Rate{30, time.Minute}},
+		{"1/s", Rate{1, time.Second}},
+		{"500/h", Rate{500, time.Hour}},
+		{"100/d", Rate{100, 24 * time.Hour}},
+		{"10/5m", Rate{10, 5 * time.Minute}},
+	}
+	for _, tt := range tests {
+		got, err := ParseRate(tt.in)
+		if err != nil {
+			t.Errorf("ParseRate(%q) error: %v", tt.in, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ParseRate(%q) = %v, want %v", tt.in, got, tt.want)
+		}
+	}
+
+	for _, in := range []string{"", "30", "0/m", "-1/m", "x/m", "30/week", "30/-1m"} {
+		if _, err := ParseRate(in); err == nil {
+			t.Errorf("ParseRate(%q) expected an error", in)
+		}
+	}
+}
+
+func TestKey(t *testing.T) {
+	if Key("a", "b", "") != Key("a", "b", "") {
+		t.Error("expected the same key for the same notification")
+	}
+	if Key("a", "b", "") == Key("a", "b", "ops") {
+		t.Error("expected the channel to change the key")
+	}
+	if Key("ab", "", "") == Key("a", "b", "") {
+		t.Error("expected title and body to be kept apart")
+	}
+}
+
+func allow(t *testing.T, s *Store, r Request, now time.Time) Decision {
+	t.Helper()
+	d, err := s.Allow(r, now)
+	if err != nil {
+		t.Fatalf("Allow() error: %v", err)
+	}
+	return d
+}
+
+func TestAllow_Dedup(t *testing.T) {
+	s := Open(filepath.Join(t.TempDir(), "throttle.json"))
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	r := Request{Profile: "default", Key: "disk-full", Window: 5 * time.Minute}
+
+	if d := allow(t, s, r, now); !d.Allowed {
+		t.Fatal("expected the first notification to be allowed")
+	}
+	for i := 1; i <= 3; i++ {
+		d := allow(t, s, r, now.Add(time.Duration(i)*time.Minute))
+		if d.Allowed || d.Reason != Duplicate {
+			t.Fatalf("repeat %d: expected a duplicate, got %+v", i, d)
+		}
+	}
+
+	other := r
+	other.Profile = "work"
+	if d := allow(t, s, other, now.Add(time.Minute)); !d.Allowed {
+		t.Error("expected the same key in another profile to be allowed")
+	}
+
+	d := allow(t, s, r, now.Add(5*time.Minute))
+	if !d.Allowed || d.Duplicates != 3 {
+		t.Fatalf("after the window: expected allowed with 3 duplicates, got %+v", d)
+	}
+	if d := allow(t, s, r, now.Add(11*time.Minute)); !d.Allowed || d.Duplicates != 0 {
+		t.Errorf("expected the count to reset once reported, got %+v", d)
+	}
+}
+
+func TestAllow_RateLimit(t *testing.T) {
+	s := Open(filepath.Join(t.TempDir(), "throttle.json"))
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	rate := Rate{Count: 2, Per: time.Minute}
+	r := Request{Profile: "default", Rate: &rate}
+
+	for i := 0; i < 2; i++ {
+		if d := allow(t, s, r, now); !d.Allowed {
+			t.Fatalf("send %d: expected the burst to be allowed", i)
+		}
+	}
+	for i := 0; i < 4; i++ {
+		if d := allow(t, s, r, now.Add(time.Second)); d.Allowed || d.Reason != RateLimited {
+			t.Fatalf("expected rate limiting, got %+v", d)
+		}
+	}
+
+	// One token comes back every 30 seconds.
+	d := allow(t, s, r, now.Add(31*time.Second))
+	if !d.Allowed || d.RateLimited != 4 {
+		t.Fatalf("expected allowed with 4 rate limited, got %+v", d)
+	}
+	if d := allow(t, s, r, now.Add(32*time.Second)); d.Allowed {
+		t.Errorf("expected the refilled token to be used up, got %+v", d)
+	}
+}
+
+func TestRelease(t *testing.T) {
+	s := Open(filepath.Join(t.TempDir(), "throttle.json"))
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	rate := Rate{Count: 1, Per: time.Hour}
+	r := Request{Profile: "default", Key: "k", Window: time.Hour, Rate: &rate}
+
+	d := allow(t, s, r, now)
+	if err := s.Release(d); err != nil {
+		t.Fatalf("Release() error: %v", err)
+	}
+	if d := allow(t, s, r, now.Add(time.Second)); !d.Allowed {
+		t.Fatalf("expected a retry after a failed send to be allowed, got %+v", d)
+	}
+	if d := allow(t, s, r, now.Add(2*time.Second)); d.Allowed {
+		t.Errorf("expected the repeat to be suppressed once sent, got %+v", d)
+	}
+}
@@ -0,0 +1,369 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"sort"
+	"strings"
+	"text/template"
+
+	"github.com/techulus/push-cli/internal/api"
+)
+
+const maxRequestBody = 10 << 20
+
+// EventTemplate holds the template text used for one event. Filter must
+// render "true" for the event to be sent; an empty filter sends every event.
+type EventTemplate struct {
+	Filter string
+	Title  string
+	Body   string
+	Link   string
+	Sound  string
+}
+
+// Provider describes how a webhook source signs its requests and names its
+// events, along with the templates used for each supported event.
+type Provider struct {
+	Name   string
+	Events map[string]EventTemplate
+
+	verify func(header http.Header, body []byte, secret string) error
+	event  func(header http.Header, payload map[string]interface{}) string
+	// ping names an event that only checks the endpoint works.
+	ping string
+}
+
+var errSignature = errors.New("signature mismatch")
+
+var GitHub = &Provider{
+	Name: "github",
+	ping: "ping",
+	verify: func(header http.Header, body []byte, secret string) error {
+		sig, ok := strings.CutPrefix(header.Get("X-Hub-Signature-256"), "sha256=")
+		if !ok {
+			return errors.New("missing X-Hub-Signature-256 header")
+		}
+		got, err := hex.DecodeString(sig)
+		if err != nil {
+			return errSignature
+		}
+		mac := hmac.New(sha256.New, []byte(secret))
+		mac.Write(body)
+		if !hmac.Equal(got, mac.Sum(nil)) {
+			return errSignature
+		}
+		return nil
+	},
+	event: func(header http.Header, payload map[string]interface{}) string {
+		return header.Get("X-GitHub-Event")
+	},
+	Events: map[string]EventTemplate{
+		"push": {
+			Filter: `{{not .deleted}}`,
+			Title:  `{{.repository.full_name}}: {{len .commits}} commit(s) to {{trimPrefix "refs/heads/" .ref}}`,
+			Body: `{{range .commits}}{{printf "%.7s" .id}} {{firstLine .message}}
+{{end}}`,
+			Link: `{{.compare}}`,
+		},
+		"pull_request": {
+			Filter: `{{or (eq .action "opened") (eq .action "closed") (eq .action "reopened")}}`,
+			Title:  `{{.repository.full_name}}: PR #{{.number}} {{if .pull_request.merged}}merged{{else}}{{.action}}{{end}}`,
+			Body:   `{{.pull_request.title}} by {{.pull_request.user.login}}`,
+			Link:   `{{.pull_request.html_url}}`,
+		},
+		"workflow_run": {
+			Filter: `{{eq .action "completed"}}`,
+			Title:  `{{.repository.full_name}}: {{.workflow_run.name}} {{.workflow_run.conclusion}}`,
+			Body:   `{{.workflow_run.head_branch}}: {{firstLine .workflow_run.display_title}}`,
+			Link:   `{{.workflow_run.html_url}}`,
+			Sound:  `{{if eq .workflow_run.conclusion "failure"}}fail{{end}}`,
+		},
+		"release": {
+			Filter: `{{eq .action "published"}}`,
+			Title:  `{{.repository.full_name}}: released {{.release.tag_name}}`,
+			Body:   `{{or .release.name .release.tag_name}}`,
+			Link:   `{{.release.html_url}}`,
+		},
+	},
+}
+
+var GitLab = &Provider{
+	Name: "gitlab",
+	verify: func(header http.Header, body []byte, secret string) error {
+		token := header.Get("X-Gitlab-Token")
+		if token == "" {
+			return errors.New("missing X-Gitlab-Token header")
+		}
+		if subtle.ConstantTimeCompare([]byte(token), []byte(secret)) != 1 {
+			return errSignature
+		}
+		return nil
+	},
+	event: func(header http.Header, payload map[string]interface{}) string {
+		kind, _ := payload["object_kind"].(string)
+		return kind
+	},
+	Events: map[string]EventTemplate{
+		"push": {
+			Filter: `{{gt (len .commits) 0}}`,
+			Title:  `{{.project.path_with_namespace}}: {{.total_commits_count}} commit(s) to {{trimPrefix "refs/heads/" .ref}}`,
+			Body: `{{range .commits}}{{printf "%.7s" .id}} {{firstLine .message}}
+{{end}}`,
+			Link: `{{.project.web_url}}/-/commits/{{trimPrefix "refs/heads/" .ref}}`,
+		},
+		"tag_push": {
+			Filter: `{{ne .after "0000000000000000000000000000000000000000"}}`,
+			Title:  `{{.project.path_with_namespace}}: tagged {{trimPrefix "refs/tags/" .ref}}`,
+			Body:   `Pushed by {{.user_name}}`,
+			Link:   `{{.project.web_url}}/-/tags/{{trimPrefix "refs/tags/" .ref}}`,
+		},
+		"merge_request": {
+			Filter: `{{or (eq .object_attributes.action "open") (eq .object_attributes.action "reopen") (eq .object_attributes.action "merge") (eq .object_attributes.action "close")}}`,
+			Title:  `{{.project.path_with_namespace}}: MR !{{.object_attributes.iid}} {{.object_attributes.action}}`,
+			Body:   `{{.object_attributes.title}} by {{.user.username}}`,
+			Link:   `{{.object_attributes.url}}`,
+		},
+		"pipeline": {
+			Filter: `{{or (eq .object_attributes.status "success") (eq .object_attributes.status "failed")}}`,
+			Title:  `{{.project.path_with_namespace}}: pipeline #{{.object_attributes.id}} {{.object_attributes.status}}`,
+			Body:   `{{.object_attributes.ref}}: {{firstLine .commit.message}}`,
+			Link:   `{{.project.web_url}}/-/pipelines/{{.object_attributes.id}}`,
+			Sound:  `{{if eq .object_attributes.status "failed"}}fail{{end}}`,
+		},
+	},
+}
+
+func ProviderByName(name string) (*Provider, error) {
+	for _, p := range []*Provider{GitHub, GitLab} {
+		if p.Name == name {
+			return p, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown provider %q, valid providers: github, gitlab", name)
+}
+
+// EventNames returns the provider's supported events in sorted order.
+func (p *Provider) EventNames() []string {
+	names := make([]string, 0, len(p.Events))
+	for name := range p.Events {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+var baseFuncs = template.FuncMap{
+	"trimPrefix": func(prefix, s string) string {
+		return strings.TrimPrefix(s, prefix)
+	},
+	"firstLine": func(s string) string {
+		line, _, _ := strings.Cut(s, "\n")
+		return strings.TrimSpace(line)
+	},
+}
+
+// Rule is the compiled form of an EventTemplate.
+type Rule struct {
+	filter, title, body, link, sound *template.Template
+}
+
+// Compile builds rules for events, starting from the provider's templates and
+// replacing any field set in overrides. funcs are added to the template
+// functions available to every field.
+func Compile(p *Provider, events []string, overrides map[string]EventTemplate, funcs template.FuncMap) (map[string]Rule, error) {
+	rules := map[string]Rule{}
+	for _, event := range events {
+		tmpl, ok := p.Events[event]
+		if !ok {
+			return nil, fmt.Errorf("unsupported %s event %q, supported events: %s", p.Name, event, strings.Join(p.EventNames(), ", "))
+		}
+		if o, ok := overrides[event]; ok {
+			tmpl = merge(tmpl, o)
+		}
+
+		var rule Rule
+		fields := []struct {
+			name string
+			text string
+			dst  **template.Template
+		}{
+			{"filter", tmpl.Filter, &rule.filter},
+			{"title", tmpl.Title, &rule.title},
+			{"body", tmpl.Body, &rule.body},
+			{"link", tmpl.Link, &rule.link},
+			{"sound", tmpl.Sound, &rule.sound},
+		}
+		for _, f := range fields {
+			if f.text == "" {
+				continue
+			}
+			t, err := template.New(event + " " + f.name).Funcs(baseFuncs).Funcs(funcs).Option("missingkey=zero").Parse(f.text)
+			if err != nil {
+				return nil, fmt.Errorf("parsing %s %s template: %w", event, f.name, err)
+			}
+			*f.dst = t
+		}
+		rules[event] = rule
+	}
+
+	for event := range overrides {
+		if _, ok := rules[event]; !ok {
+			return nil, fmt.Errorf("template given for %s event %q, which is not enabled", p.Name, event)
+		}
+	}
+	return rules, nil
+}
+
+func merge(base, o EventTemplate) EventTemplate {
+	for _, f := range []struct{ dst, src *string }{
+		{&base.Filter, &o.Filter},
+		{&base.Title, &o.Title},
+		{&base.Body, &o.Body},
+		{&base.Link, &o.Link},
+		{&base.Sound, &o.Sound},
+	} {
+		if *f.src != "" {
+			*f.dst = *f.src
+		}
+	}
+	return base
+}
+
+// Build renders rule against payload. ok is false when the filter rejects
+// the event.
+func (r Rule) Build(payload map[string]interface{}) (req api.NotifyRequest, ok bool, err error) {
+	if r.filter != nil {
+		pass, err := render(r.filter, payload)
+		if err != nil {
+			return req, false, err
+		}
+		if pass != "true" {
+			return req, false, nil
+		}
+	}
+
+	for _, f := range []struct {
+		tmpl *template.Template
+		dst  *string
+	}{
+		{r.title, &req.Title},
+		{r.body, &req.Body},
+		{r.link, &req.Link},
+		{r.sound, &req.Sound},
+	} {
+		if f.tmpl == nil {
+			continue
+		}
+		if *f.dst, err = render(f.tmpl, payload); err != nil {
+			return req, false, err
+		}
+	}
+	return req, true, nil
+}
+
+func render(tmpl *template.Template, data interface{}) (string, error) {
+	var b strings.Builder
+	if err := tmpl.Execute(&b, data); err != nil {
+		return "", fmt.Errorf("rendering %s template: %w", tmpl.Name(), err)
+	}
+	return strings.TrimSpace(b.String()), nil
+}
+
+type Options struct {
+	Secret   string
+	Rules    map[string]Rule
+	Validate func(api.NotifyRequest) error
+	Logger   *slog.Logger
+}
+
+type SendFunc func(ctx context.Context, req api.NotifyRequest) error
+
+// Handler verifies each delivery against opts.Secret, then sends it if its
+// event has a rule and the rule's filter passes. Deliveries for other events
+// are acknowledged and ignored so the provider doesn't report failures.
+func Handler(p *Provider, send SendFunc, opts Options) http.Handler {
+	logger := opts.Logger
+	if logger == nil {
+		logger = slog.Default()
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			http.Error(w, "use POST", http.StatusMethodNotAllowed)
+			return
+		}
+
+		body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxRequestBody))
+		if err != nil {
+			http.Error(w, "reading body: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := p.verify(r.Header, body, opts.Secret); err != nil {
+			logger.Warn("rejected delivery", "remote", r.RemoteAddr, "error", err)
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+
+		var payload map[string]interface{}
+		dec := json.NewDecoder(bytes.NewReader(body))
+		dec.UseNumber()
+		if err := dec.Decode(&payload); err != nil {
+			http.Error(w, "invalid JSON: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		event := p.event(r.Header, payload)
+		log := logger.With("event", event)
+		rule, ok := opts.Rules[event]
+		if !ok {
+			if event != p.ping {
+				log.Debug("event ignored")
+			}
+			io.WriteString(w, "ignored\n")
+			return
+		}
+
+		req, ok, err := rule.Build(payload)
+		if err != nil {
+			log.Error("building notification failed", "error", err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if !ok {
+			log.Debug("event filtered")
+			io.WriteString(w, "filtered\n")
+			return
+		}
+		if req.Title == "" || req.Body == "" {
+			log.Error("notification has an empty title or body")
+			http.Error(w, "template rendered an empty title or body", http.StatusInternalServerError)
+			return
+		}
+		if opts.Validate != nil {
+			if err := opts.Validate(req); err != nil {
+				log.Error("invalid notification", "error", err)
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+		}
+
+		if err := send(r.Context(), req); err != nil {
+			log.Error("notification failed", "title", req.Title, "error", err)
+			http.Error(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+		log.Info("notification sent", "title", req.Title)
+		io.WriteString(w, "sent\n")
+	})
+}
@@ -0,0 +1,226 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/techulus/push-cli/internal/api"
+)
+
+const testSecret = "s3cret"
+
+func githubSignature(body []byte) string {
+	mac := hmac.New(sha256.New, []byte(testSecret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func newDelivery(t *testing.T, p *Provider, event string, body []byte) *http.Request {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(body))
+	switch p {
+	case GitHub:
+		req.Header.Set("X-GitHub-Event", event)
+		req.Header.Set("X-Hub-Signature-256", githubSignature(body))
+	case GitLab:
+		req.Header.Set("X-Gitlab-Token", testSecret)
+	}
+	return req
+}
+
+func fixture(t *testing.T, p *Provider, name string) []byte {
+	t.Helper()
+	raw, err := os.ReadFile(filepath.Join("testdata", p.Name, name+".json"))
+	if err != nil {
+		t.Fatalf("reading fixture: %v", err)
+	}
+	return raw
+}
+
+func serve(t *testing.T, p *Provider, overrides map[string]EventTemplate, req *http.Request) (*httptest.ResponseRecorder, []api.NotifyRequest) {
+	t.Helper()
+	rules, err := Compile(p, p.EventNames(), overrides, nil)
+	if err != nil {
+		t.Fatalf("Compile() error: %v", err)
+	}
+
+	var sent []api.NotifyRequest
+	handler := Handler(p, func(ctx context.Context, req api.NotifyRequest) error {
+		sent = append(sent, req)
+		return nil
+	}, Options{Secret: testSecret, Rules: rules, Logger: slog.New(slog.NewTextHandler(io.Discard, nil))})
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	return rec, sent
+}
+
+func TestHandler_Events(t *testing.T) {
+	tests := []struct {
+		provider *Provider
+		event    string
+		want     api.NotifyRequest
+	}{
+		{GitHub, "push", api.NotifyRequest{
+			Title: "techulus/push-cli: 2 commit(s) to main",
+			Body:  "9a2b1c3 Fix config path on Windows\n0d1a26e Bump version",
+			Link:  "https://github.com/techulus/push-cli/compare/6113728f27ae...0d1a26e67d8f",
+		}},
+		{GitHub, "pull_request", api.NotifyRequest{
+			Title: "techulus/push-cli: PR #42 merged",
+			Body:  "Add batch sending by jane",
+			Link:  "https://github.com/techulus/push-cli/pull/42",
+		}},
+		{GitHub, "workflow_run", api.NotifyRequest{
+			Title: "techulus/push-cli: CI failure",
+			Body:  "main: Bump version",
+			Link:  "https://github.com/techulus/push-cli/actions/runs/987654321",
+			Sound: "fail",
+		}},
+		{GitHub, "release", api.NotifyRequest{
+			Title: "techulus/push-cli: released v1.2.0",
+			Body:  "v1.2.0",
+			Link:  "https://github.com/techulus/push-cli/releases/tag/v1.2.0",
+		}},
+		{GitLab, "push", api.NotifyRequest{
+			Title: "acme/api: 1 commit(s) to main",
+			Body:  "da15608 Add healthcheck endpoint",
+			Link:  "https://gitlab.example.com/acme/api/-/commits/main",
+		}},
+		{GitLab, "tag_push", api.NotifyRequest{
+			Title: "acme/api: tagged v2.0.0",
+			Body:  "Pushed by Jane Doe",
+			Link:  "https://gitlab.example.com/acme/api/-/tags/v2.0.0",
+		}},
+		{GitLab, "merge_request", api.NotifyRequest{
+			Title: "acme/api: MR !7 open",
+			Body:  "Add rate limiting by jane",
+			Link:  "https://gitlab.example.com/acme/api/-/merge_requests/7",
+		}},
+		{GitLab, "pipeline", api.NotifyRequest{
+			Title: "acme/api: pipeline #31 failed",
+			Body:  "main: Update dependencies",
+			Link:  "https://gitlab.example.com/acme/api/-/pipelines/31",
+			Sound: "fail",
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.provider.Name+"/"+tt.event, func(t *testing.T) {
+			req := newDelivery(t, tt.provider, tt.event, fixture(t, tt.provider, tt.event))
+			rec, sent := serve(t, tt.provider, nil, req)
+
+			if rec.Code != http.StatusOK {
+				t.Fatalf("expected status 200, got %d: %s", rec.Code, rec.Body.String())
+			}
+			if len(sent) != 1 {
+				t.Fatalf("expected 1 notification, got %d", len(sent))
+			}
+			if sent[0] != tt.want {
+				t.Errorf("expected %+v, got %+v", tt.want, sent[0])
+			}
+		})
+	}
+}
+
+func TestHandler_Signatures(t *testing.T) {
+	body := fixture(t, GitHub, "push")
+
+	bad := newDelivery(t, GitHub, "push", body)
+	bad.Header.Set("X-Hub-Signature-256", "sha256=00ff")
+	if rec, sent := serve(t, GitHub, nil, bad); rec.Code != http.StatusUnauthorized || len(sent) != 0 {
+		t.Errorf("expected bad signature to be rejected, got %d", rec.Code)
+	}
+
+	missing := newDelivery(t, GitHub, "push", body)
+	missing.Header.Del("X-Hub-Signature-256")
+	if rec, _ := serve(t, GitHub, nil, missing); rec.Code != http.StatusUnauthorized {
+		t.Errorf("expected missing signature to be rejected, got %d", rec.Code)
+	}
+
+	// The signature covers the exact bytes, so any change invalidates it.
+	tampered := newDelivery(t, GitHub, "push", body)
+	tampered.Body = io.NopCloser(bytes.NewReader(append(body, ' ')))
+	if rec, _ := serve(t, GitHub, nil, tampered); rec.Code != http.StatusUnauthorized {
+		t.Errorf("expected tampered body to be rejected, got %d", rec.Code)
+	}
+
+	wrongToken := newDelivery(t, GitLab, "push", fixture(t, GitLab, "push"))
+	wrongToken.Header.Set("X-Gitlab-Token", "nope")
+	if rec, _ := serve(t, GitLab, nil, wrongToken); rec.Code != http.StatusUnauthorized {
+		t.Errorf("expected wrong GitLab token to be rejected, got %d", rec.Code)
+	}
+}
+
+func TestHandler_IgnoredAndFiltered(t *testing.T) {
+	ping := newDelivery(t, GitHub, "ping", fixture(t, GitHub, "ping"))
+	if rec, sent := serve(t, GitHub, nil, ping); rec.Code != http.StatusOK || len(sent) != 0 {
+		t.Errorf("expected ping to be acknowledged without sending, got %d and %d sent", rec.Code, len(sent))
+	}
+
+	overrides := map[string]EventTemplate{"workflow_run": {Filter: `{{eq .workflow_run.conclusion "success"}}`}}
+	run := newDelivery(t, GitHub, "workflow_run", fixture(t, GitHub, "workflow_run"))
+	rec, sent := serve(t, GitHub, overrides, run)
+	if rec.Code != http.StatusOK || len(sent) != 0 {
+		t.Errorf("expected filtered event to be acknowledged without sending, got %d and %d sent", rec.Code, len(sent))
+	}
+}
+
+func TestHandler_TemplateOverride(t *testing.T) {
+	overrides := map[string]EventTemplate{"pipeline": {Title: `{{.object_attributes.status | upper}} {{.project.name}}`}}
+	req := newDelivery(t, GitLab, "pipeline", fixture(t, GitLab, "pipeline"))
+
+	rules, err := Compile(GitLab, []string{"pipeline"}, overrides, map[string]interface{}{"upper": func(s string) string { return "!" + s }})
+	if err != nil {
+		t.Fatalf("Compile() error: %v", err)
+	}
+	var sent api.NotifyRequest
+	handler := Handler(GitLab, func(ctx context.Context, req api.NotifyRequest) error {
+		sent = req
+		return nil
+	}, Options{Secret: testSecret, Rules: rules, Logger: slog.New(slog.NewTextHandler(io.Discard, nil))})
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	if sent.Title != "!failed api" {
+		t.Errorf("expected overridden title, got %q", sent.Title)
+	}
+	if sent.Body != "main: Update dependencies" {
+		t.Errorf("expected default body to be kept, got %q", sent.Body)
+	}
+}
+
+func TestHandler_SendFailure(t *testing.T) {
+	rules, _ := Compile(GitHub, []string{"release"}, nil, nil)
+	handler := Handler(GitHub, func(ctx context.Context, req api.NotifyRequest) error {
+		return errors.New("boom")
+	}, Options{Secret: testSecret, Rules: rules, Logger: slog.New(slog.NewTextHandler(io.Discard, nil))})
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, newDelivery(t, GitHub, "release", fixture(t, GitHub, "release")))
+	if rec.Code != http.StatusBadGateway {
+		t.Errorf("expected 502 on send failure, got %d", rec.Code)
+	}
+}
+
+func TestCompile_Errors(t *testing.T) {
+	if _, err := Compile(GitHub, []string{"pipeline"}, nil, nil); err == nil {
+		t.Error("expected error for unsupported event")
+	}
+	if _, err := Compile(GitHub, []string{"push"}, map[string]EventTemplate{"release": {Title: "x"}}, nil); err == nil {
+		t.Error("expected error for template on a disabled event")
+	}
+	if _, err := Compile(GitHub, []string{"push"}, map[string]EventTemplate{"push": {Title: "{{"}}, nil); err == nil {
+		t.Error("expected error for invalid template")
+	}
+}